@@ -0,0 +1,542 @@
+// Command sanitizer sanitizes files using the sanitization rules, without a browser.
+// It can also desanitize files, using the vault recorded while sanitizing them.
+//
+// Usage:
+//
+//	sanitizer [flags] [file ...]
+//
+// When no files (or "-") are specified, the content is read from stdin and the -extension flag is used to pick the rule set.
+package main
+
+//goland:noinspection GoUnsortedImport
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/padaiyal/sanitizer/rules"
+	"github.com/padaiyal/sanitizer/sanitizer"
+	"github.com/padaiyal/sanitizer/script"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	exitCodeSuccess = 0
+	exitCodeFailure = 1
+	exitCodeUsage   = 2
+	// exitCodePartial is returned when some rules couldn't be applied. The partially sanitized outputs are written.
+	exitCodePartial = 3
+)
+
+const stdinFileName = "-"
+
+type options struct {
+	configPath     string
+	rulesPath      string
+	rulesDir       string
+	extension      string
+	outputDir      string
+	diffPath       string
+	secretKeyPath  string
+	vaultPath      string
+	passphrasePath string
+	desanitize     bool
+	contextPerFile bool
+	outputFormat   string
+	outputIndent   string
+	reportPath     string
+	reportFormat   string
+	dryRun         bool
+	stream         bool
+	concurrency    int
+	timeout        time.Duration
+	verbose        bool
+	inputFilePaths []string
+}
+
+type inputFile struct {
+	path      string
+	name      string
+	extension string
+	// content isn't read when streaming.
+	content []byte
+}
+
+// fileResult is the result of sanitizing (or desanitizing) an input file.
+type fileResult struct {
+	sanitizedFileName string
+	result            sanitizer.SanitizeResult
+}
+
+func parseOptions(args []string, stderr io.Writer) (options, error) {
+	opts := options{}
+	flagSet := flag.NewFlagSet("sanitizer", flag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	flagSet.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: sanitizer [flags] [file ...]")
+		_, _ = fmt.Fprintln(stderr, "Sanitizes the specified files (or stdin if no files or '-' is specified).")
+		flagSet.PrintDefaults()
+	}
+	flagSet.StringVar(&opts.configPath, "config", "", "Path of the config file. By default, the built-in script/config.json is used.")
+	flagSet.StringVar(&opts.rulesPath, "rules", "", "Path of the rule file to use for all inputs. By default, the <file_extension>.yaml rule file in the rules directory is used.")
+	flagSet.StringVar(&opts.rulesDir, "rules-dir", "", "Directory containing the <file_extension>.yaml rule files. By default, the built-in rule files are used.")
+	flagSet.StringVar(&opts.extension, "extension", "", "File extension (Ex: har) of the content read from stdin.")
+	flagSet.StringVar(&opts.outputDir, "output-dir", "", "Directory to write the sanitized files to. By default, the sanitized content is written to stdout.")
+	flagSet.StringVar(&opts.diffPath, "diff", "", "File to write the unified diff to ('-' for stdout). By default, no diff is written.")
+	flagSet.StringVar(&opts.vaultPath, "vault", "", "Vault file to record the original values behind the contextual replacements in (created or updated). With -desanitize, the vault file to restore the original values from.")
+	flagSet.StringVar(&opts.passphrasePath, "vault-passphrase-file", "", "File containing the passphrase the vault is encrypted with.")
+	flagSet.BoolVar(&opts.desanitize, "desanitize", false, "Restore the original values in sanitized files using the -vault, instead of sanitizing them.")
+	flagSet.BoolVar(&opts.contextPerFile, "context-per-file", false, "Use a separate replacement context for each file. By default, identical secrets across the files get the same replacement.")
+	flagSet.StringVar(&opts.secretKeyPath, "secret-key-file", "", "File containing the secret key used to derive the contextual replacements. Files sanitized with the same key get the same replacements.")
+	flagSet.StringVar(&opts.outputFormat, "format", "", "Formatting of the output: preserve (the original formatting), minify or pretty. Defaults to the config's OutputFormat.")
+	flagSet.StringVar(&opts.outputIndent, "indent", "", "Indent used to pretty print the output. Defaults to the config's OutputIndent.")
+	flagSet.StringVar(&opts.reportPath, "report", "", "File to write the report of the sanitized values to ('-' for stdout). By default, no report is written.")
+	flagSet.StringVar(&opts.reportFormat, "report-format", sanitizer.ReportFormatJson, "Format of the report: json or sarif.")
+	flagSet.BoolVar(&opts.dryRun, "dry-run", false, "Only detect the values the rules would sanitize, without writing the sanitized files. The proposed diff is written to stdout (unless -diff is specified), and the rules that didn't match any value are printed.")
+	flagSet.BoolVar(&opts.stream, "stream", false, "Sanitize the content while it's read, writing the sanitized content as it's sanitized, so large files are sanitized in bounded memory. No diff is produced.")
+	flagSet.IntVar(&opts.concurrency, "concurrency", 0, "Maximum number of files (and rules per file) processed at a time. Defaults to the config's MaxConcurrency, or the number of CPUs.")
+	flagSet.DurationVar(&opts.timeout, "timeout", 0, "Maximum duration (Ex: 30s) of the whole run. By default, there's no timeout.")
+	flagSet.BoolVar(&opts.verbose, "v", false, "Log the diagnostics of the sanitization (the rules applied, the errors etc.) to stderr. The values of the files aren't logged.")
+	if err := flagSet.Parse(args); err != nil {
+		return opts, err
+	}
+	opts.inputFilePaths = flagSet.Args()
+	if len(opts.inputFilePaths) == 0 {
+		opts.inputFilePaths = []string{stdinFileName}
+	}
+	opts.extension = strings.TrimPrefix(opts.extension, ".")
+
+	if opts.dryRun {
+		if opts.desanitize {
+			return opts, errors.New("-dry-run cannot be used with -desanitize")
+		}
+		if opts.diffPath == "" && opts.reportPath != stdinFileName {
+			opts.diffPath = stdinFileName
+		}
+	}
+	if opts.stream && (opts.dryRun || opts.desanitize || opts.diffPath != "") {
+		return opts, errors.New("-stream cannot be used with -dry-run, -desanitize or -diff")
+	}
+	// The sanitized content isn't written in a dry run.
+	isContentToStdout := opts.outputDir == "" && !opts.dryRun
+	if isContentToStdout && len(opts.inputFilePaths) > 1 {
+		return opts, errors.New("-output-dir is required when sanitizing multiple files")
+	}
+	if isContentToStdout && opts.diffPath == stdinFileName {
+		return opts, errors.New("the sanitized content and the diff cannot both be written to stdout, specify -output-dir or a -diff file")
+	}
+	if isContentToStdout && opts.reportPath == stdinFileName {
+		return opts, errors.New("the sanitized content and the report cannot both be written to stdout, specify -output-dir or a -report file")
+	}
+	if opts.diffPath == stdinFileName && opts.reportPath == stdinFileName {
+		return opts, errors.New("the diff and the report cannot both be written to stdout")
+	}
+	if !slices.Contains([]string{sanitizer.ReportFormatJson, sanitizer.ReportFormatSarif}, opts.reportFormat) {
+		return opts, fmt.Errorf("unsupported -report-format (%s)", opts.reportFormat)
+	}
+	if opts.reportPath != "" && opts.desanitize {
+		return opts, errors.New("-report cannot be used with -desanitize")
+	}
+	if opts.vaultPath != "" && opts.passphrasePath == "" {
+		return opts, errors.New("-vault-passphrase-file is required with -vault")
+	}
+	if opts.desanitize && opts.vaultPath == "" {
+		return opts, errors.New("-vault is required with -desanitize")
+	}
+	if opts.concurrency < 0 || opts.timeout < 0 {
+		return opts, errors.New("-concurrency and -timeout cannot be negative")
+	}
+	if opts.outputFormat != "" && !slices.Contains([]string{sanitizer.OutputFormatPreserve, sanitizer.OutputFormatMinify, sanitizer.OutputFormatPretty}, opts.outputFormat) {
+		return opts, fmt.Errorf("unsupported -format (%s)", opts.outputFormat)
+	}
+	if slices.Contains(opts.inputFilePaths, stdinFileName) {
+		if opts.extension == "" {
+			return opts, errors.New("-extension is required when reading from stdin")
+		}
+		if len(opts.inputFilePaths) > 1 {
+			return opts, errors.New("stdin cannot be combined with other input files")
+		}
+	}
+	return opts, nil
+}
+
+// loadConfig loads the config file, or the built-in config if the path is empty.
+func loadConfig(configPath string) (sanitizer.Config, error) {
+	config := sanitizer.Config{}
+	configBytes := script.Config
+	if configPath != "" {
+		var err error
+		if configBytes, err = os.ReadFile(configPath); err != nil {
+			return config, err
+		}
+	}
+	err := json.Unmarshal(configBytes, &config)
+	return config, err
+}
+
+// loadRuleSet loads the rule file from the file system, or from the built-in rule files if isBuiltIn is set.
+func loadRuleSet(ruleFilePath string, isBuiltIn bool) (sanitizer.RuleSet, error) {
+	ruleSet := sanitizer.RuleSet{}
+	readFile := os.ReadFile
+	if isBuiltIn {
+		readFile = func(name string) ([]byte, error) { return fs.ReadFile(rules.FS, name) }
+	}
+	ruleSetBytes, err := readFile(ruleFilePath)
+	if err != nil {
+		return ruleSet, err
+	}
+	err = yaml.Unmarshal(ruleSetBytes, &ruleSet)
+	return ruleSet, err
+}
+
+func readInputFiles(opts options, stdin io.Reader) ([]inputFile, error) {
+	inputFiles := make([]inputFile, 0, len(opts.inputFilePaths))
+	for _, inputFilePath := range opts.inputFilePaths {
+		if inputFilePath == stdinFileName {
+			var content []byte = nil
+			if !opts.stream {
+				var err error
+				if content, err = io.ReadAll(stdin); err != nil {
+					return nil, fmt.Errorf("error reading stdin: %w", err)
+				}
+			}
+			inputFiles = append(inputFiles, inputFile{
+				path:      inputFilePath,
+				name:      "stdin." + opts.extension,
+				extension: opts.extension,
+				content:   content,
+			})
+			continue
+		}
+		extension := strings.TrimPrefix(filepath.Ext(inputFilePath), ".")
+		if extension == "" {
+			return nil, fmt.Errorf("cannot determine the file extension of %s", inputFilePath)
+		}
+		name := filepath.Base(inputFilePath)
+		if slices.ContainsFunc(inputFiles, func(file inputFile) bool { return file.name == name }) {
+			return nil, fmt.Errorf("multiple files with the same name (%s) isn't supported", name)
+		}
+		var content []byte = nil
+		var err error
+		if opts.stream {
+			_, err = os.Stat(inputFilePath)
+		} else {
+			content, err = os.ReadFile(inputFilePath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		inputFiles = append(inputFiles, inputFile{
+			path:      inputFilePath,
+			name:      name,
+			extension: extension,
+			content:   content,
+		})
+	}
+	return inputFiles, nil
+}
+
+// loadRuleSets loads and compiles the rule set for each of the input files' extensions, so invalid rules are reported
+// before any file is sanitized.
+// If a rule file is explicitly specified, it is used for all the extensions.
+func loadRuleSets(opts options, config *sanitizer.Config, inputFiles []inputFile) (map[string]*sanitizer.CompiledRuleSet, error) {
+	compiledRuleSets := map[string]*sanitizer.CompiledRuleSet{}
+	for _, file := range inputFiles {
+		if _, isPresent := compiledRuleSets[file.extension]; isPresent {
+			continue
+		}
+		ruleFilePath := opts.rulesPath
+		if ruleFilePath == "" {
+			if !slices.Contains(config.SupportedFileExtensions, file.extension) {
+				return nil, fmt.Errorf("unsupported file extension (%s), supported file extensions are %s", file.extension, strings.Join(config.SupportedFileExtensions, ","))
+			}
+			ruleFilePath = filepath.Join(opts.rulesDir, file.extension+".yaml")
+		} else if !slices.Contains(config.SupportedFileExtensions, file.extension) {
+			config.SupportedFileExtensions = append(config.SupportedFileExtensions, file.extension)
+		}
+		ruleSet, err := loadRuleSet(ruleFilePath, opts.rulesPath == "" && opts.rulesDir == "")
+		if err != nil {
+			return nil, fmt.Errorf("error loading rule set %s: %w", ruleFilePath, err)
+		}
+		compiledRuleSet, err := sanitizer.CompileRuleSet(ruleSet, *config)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling rule set %s: %w", ruleFilePath, err)
+		}
+		compiledRuleSets[file.extension] = compiledRuleSet
+	}
+	return compiledRuleSets, nil
+}
+
+// loadVault opens the vault file, or creates an empty vault if the file doesn't exist and it isn't required.
+func loadVault(vaultPath string, passphrase []byte, isRequired bool) (*sanitizer.Vault, error) {
+	vaultBytes, err := os.ReadFile(vaultPath)
+	if errors.Is(err, os.ErrNotExist) && !isRequired {
+		return sanitizer.NewVault(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return sanitizer.OpenVault(vaultBytes, passphrase)
+}
+
+// getOutputPath returns the path of the sanitized file, or stdinFileName if it's written to stdout.
+func getOutputPath(opts options, sanitizedFileName string) string {
+	if opts.outputDir == "" {
+		return stdinFileName
+	}
+	return filepath.Join(opts.outputDir, sanitizedFileName)
+}
+
+// streamFile sanitizes the content of the file while streaming it to the output path.
+// The findings are only retained if they're reported, so the memory used doesn't grow with them.
+func streamFile(ctx context.Context, fileSanitizer *sanitizer.Sanitizer, replacementContext *sanitizer.ReplacementContext, file inputFile, outputPath string, isReported bool, stdin io.Reader, stdout io.Writer) (report sanitizer.Report, err error) {
+	reader := stdin
+	if file.path != stdinFileName {
+		inputFile, err := os.Open(file.path)
+		if err != nil {
+			return report, err
+		}
+		defer func() { _ = inputFile.Close() }()
+		reader = inputFile
+	}
+	writer := stdout
+	if outputPath != stdinFileName {
+		// The output is streamed into a temporary file, which only replaces the output file once the input is
+		// sanitized, so a failure (Ex: invalid content or a timeout) doesn't leave a partial output.
+		outputFile, createErr := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*")
+		if createErr != nil {
+			return report, createErr
+		}
+		defer func() {
+			if closeErr := outputFile.Close(); err == nil {
+				err = closeErr
+			}
+			partialSanitizationErr := &sanitizer.PartialSanitizationError{}
+			if err == nil || errors.As(err, &partialSanitizationErr) {
+				if chmodErr := os.Chmod(outputFile.Name(), 0644); chmodErr != nil {
+					err = chmodErr
+				} else if renameErr := os.Rename(outputFile.Name(), outputPath); renameErr != nil {
+					err = renameErr
+				}
+			}
+			if err != nil && !errors.As(err, &partialSanitizationErr) {
+				_ = os.Remove(outputFile.Name())
+			}
+		}()
+		writer = outputFile
+	}
+	var findingHandler func(sanitizer.Finding) = nil
+	if !isReported {
+		findingHandler = func(sanitizer.Finding) {}
+	}
+	return fileSanitizer.SanitizeStream(ctx, replacementContext, reader, writer, file.extension, file.name, findingHandler)
+}
+
+func writeOutput(path string, content string, stdout io.Writer) error {
+	if path == stdinFileName {
+		_, err := io.WriteString(stdout, content)
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	opts, err := parseOptions(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitCodeSuccess
+	} else if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return exitCodeUsage
+	}
+
+	config, err := loadConfig(opts.configPath)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error loading config:", err)
+		return exitCodeFailure
+	}
+	inputFiles, err := readInputFiles(opts, stdin)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return exitCodeFailure
+	}
+	if opts.outputFormat != "" {
+		config.OutputFormat = opts.outputFormat
+	}
+	if opts.outputIndent != "" {
+		config.OutputIndent = opts.outputIndent
+	}
+	if opts.concurrency > 0 {
+		config.MaxConcurrency = opts.concurrency
+	}
+	compiledRuleSets, err := loadRuleSets(opts, &config, inputFiles)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "Error:", err)
+		return exitCodeFailure
+	}
+	if opts.outputDir != "" && !opts.dryRun {
+		if err = os.MkdirAll(opts.outputDir, 0755); err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error creating output directory:", err)
+			return exitCodeFailure
+		}
+	}
+
+	fileSanitizer := sanitizer.NewWithCompiledRuleSets(config, compiledRuleSets)
+	if opts.verbose {
+		fileSanitizer.SetLogger(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	}
+	if opts.secretKeyPath != "" {
+		secretKey, err := os.ReadFile(opts.secretKeyPath)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error reading secret key:", err)
+			return exitCodeFailure
+		}
+		fileSanitizer.SetSecretKey(bytes.TrimSpace(secretKey))
+	}
+	var vault *sanitizer.Vault = nil
+	var passphrase []byte = nil
+	if opts.vaultPath != "" {
+		passphrase, err = os.ReadFile(opts.passphrasePath)
+		passphrase = bytes.TrimSpace(passphrase)
+		if err == nil && len(passphrase) < sanitizer.MinimumVaultPassphraseLength {
+			err = fmt.Errorf("the vault passphrase must have at least %d characters", sanitizer.MinimumVaultPassphraseLength)
+		}
+		if err == nil {
+			vault, err = loadVault(opts.vaultPath, passphrase, opts.desanitize)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error loading vault:", err)
+			return exitCodeFailure
+		}
+		if !opts.desanitize {
+			fileSanitizer.SetVault(vault)
+		}
+	}
+
+	ctx := context.Background()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	// Unless requested otherwise, a single replacement context is used, so identical secrets across the files get the same replacement.
+	replacementContext := fileSanitizer.NewReplacementContext()
+	processFile := func(ctx context.Context, file inputFile) (fileResult, error) {
+		if opts.desanitize {
+			desanitizedFileName := sanitizer.GenerateDesanitizedFileName(file.name)
+			desanitizedContent, diffPatchText, isDiffEmpty, err := fileSanitizer.Desanitize(string(file.content), file.extension, file.name, desanitizedFileName, vault)
+			return fileResult{
+				sanitizedFileName: desanitizedFileName,
+				result:            sanitizer.SanitizeResult{Content: desanitizedContent, DiffPatchText: diffPatchText, IsDiffEmpty: isDiffEmpty},
+			}, err
+		}
+		fileReplacementContext := replacementContext
+		if opts.contextPerFile {
+			fileReplacementContext = fileSanitizer.NewReplacementContext()
+		}
+		sanitizedFileName := sanitizer.GenerateSanitizedFileName(file.name)
+		result := sanitizer.SanitizeResult{IsDiffEmpty: true}
+		var err error
+		if opts.stream {
+			result.Report, err = streamFile(ctx, fileSanitizer, fileReplacementContext, file, getOutputPath(opts, sanitizedFileName), opts.reportPath != "", stdin, stdout)
+		} else if opts.dryRun {
+			result, err = fileSanitizer.DryRunContext(ctx, fileReplacementContext, string(file.content), file.extension, file.name, sanitizedFileName)
+		} else {
+			result, err = fileSanitizer.SanitizeContext(ctx, fileReplacementContext, string(file.content), file.extension, file.name, sanitizedFileName)
+		}
+		return fileResult{sanitizedFileName: sanitizedFileName, result: result}, err
+	}
+	// The files are processed in parallel, and their results are handled in the order of the input files.
+	fileTaskResults := sanitizer.RunTasks(ctx, processFile, inputFiles, sanitizer.TaskOptions{Concurrency: opts.concurrency})
+
+	diffs := make([]string, 0, len(inputFiles))
+	exitCode := exitCodeSuccess
+	report := sanitizer.Report{}
+	for index, file := range inputFiles {
+		fileTaskResult := fileTaskResults[index]
+		sanitizedFileName, result, err := fileTaskResult.Output.sanitizedFileName, fileTaskResult.Output.result, fileTaskResult.Err
+		if opts.dryRun {
+			for _, unmatchedRule := range result.Report.UnmatchedRules {
+				_, _ = fmt.Fprintf(stderr, "Rule %s didn't match any value in '%s'\n", unmatchedRule.RuleKey, file.path)
+			}
+		}
+		for _, conflict := range result.Report.Conflicts {
+			location := conflict.JsonPath
+			if conflict.Line > 0 {
+				location += fmt.Sprintf(" (line %d)", conflict.Line)
+			}
+			_, _ = fmt.Fprintf(stderr, "Warning: rule %s (%s) is overridden by rule %s (%s) at %s in '%s'\n", conflict.OverriddenRuleKey, conflict.OverriddenAction, conflict.RuleKey, conflict.Action, location, file.path)
+		}
+		report.Merge(result.Report)
+		partialSanitizationErr := &sanitizer.PartialSanitizationError{}
+		if errors.As(err, &partialSanitizationErr) {
+			for _, ruleErr := range partialSanitizationErr.Errors {
+				_, _ = fmt.Fprintf(stderr, "Warning: '%s' is partially processed: %s\n", file.path, ruleErr)
+			}
+			exitCode = exitCodePartial
+		} else if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error processing '%s': %s\n", file.path, err)
+			exitCode = exitCodeFailure
+			break
+		}
+		if !result.IsDiffEmpty {
+			diffs = append(diffs, result.DiffPatchText)
+		}
+
+		// The sanitized content is already written while streaming.
+		if opts.dryRun || opts.stream {
+			continue
+		}
+		if err = writeOutput(getOutputPath(opts, sanitizedFileName), result.Content, stdout); err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error writing output of '%s': %s\n", file.path, err)
+			exitCode = exitCodeFailure
+			break
+		}
+	}
+
+	// The vault is written even if a file fails, as the outputs of the files before it (and of every streamed file)
+	// are already written, and can't be desanitized without it.
+	if vault != nil && !opts.desanitize && !opts.dryRun {
+		vaultBytes, err := vault.Export(passphrase)
+		if err == nil {
+			err = os.WriteFile(opts.vaultPath, vaultBytes, 0600)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error writing vault:", err)
+			return exitCodeFailure
+		}
+	}
+	if exitCode == exitCodeFailure {
+		return exitCodeFailure
+	}
+
+	if opts.diffPath != "" {
+		if err = writeOutput(opts.diffPath, strings.Join(diffs, "\n"), stdout); err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error writing diff:", err)
+			return exitCodeFailure
+		}
+	}
+	if opts.reportPath != "" {
+		reportBytes, err := report.Export(opts.reportFormat)
+		if err == nil {
+			err = writeOutput(opts.reportPath, string(reportBytes), stdout)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "Error writing report:", err)
+			return exitCodeFailure
+		}
+	}
+	return exitCode
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	configPath    = filepath.Join("..", "..", "script", "config.json")
+	rulesDir      = filepath.Join("..", "..", "rules")
+	harsPath      = filepath.Join("..", "..", "tests", "e2e", "resources", "hars")
+	secretKeyPath = filepath.Join("..", "..", "tests", "e2e", "resources", "secret_key.txt")
+)
+
+func runCommand(stdin string, args ...string) (int, string, string) {
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	// The expected sanitized files are sanitized with the test secret key.
+	args = append([]string{"-config", configPath, "-rules-dir", rulesDir, "-secret-key-file", secretKeyPath}, args...)
+	exitCode := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return exitCode, stdout.String(), stderr.String()
+}
+
+func TestRunWritesSanitizedFilesAndDiff(t *testing.T) {
+	outputDir := t.TempDir()
+	exitCode, stdout, _ := runCommand("", "-output-dir", outputDir, "-diff", "-",
+		filepath.Join(harsPath, "contextual_replacement.har"),
+		filepath.Join(harsPath, "already_sanitized.har"),
+	)
+	require.Equal(t, exitCodeSuccess, exitCode)
+	assert.True(t, strings.HasPrefix(stdout, "--- contextual_replacement.har\n+++ contextual_replacement_sanitized.har\n"))
+	assert.NotContains(t, stdout, "already_sanitized.har")
+
+	expectedContent, err := os.ReadFile(filepath.Join(harsPath, "expected_sanitized_files", "contextual_replacement_sanitized.har"))
+	require.NoError(t, err)
+	actualContent, err := os.ReadFile(filepath.Join(outputDir, "contextual_replacement_sanitized.har"))
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContent), string(actualContent))
+	assert.FileExists(t, filepath.Join(outputDir, "already_sanitized_sanitized.har"))
+}
+
+func TestRunProcessesFilesInParallel(t *testing.T) {
+	fileNames := []string{"contextual_replacement.har", "remove_and_contextual_replacement.har", "already_sanitized.har"}
+	args := []string{"-dry-run", "-concurrency", "2", "-timeout", "1m"}
+	for _, fileName := range fileNames {
+		args = append(args, filepath.Join(harsPath, fileName))
+	}
+	exitCode, stdout, stderr := runCommand("", args...)
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	// The diffs are in the order of the input files.
+	firstDiffIndex := strings.Index(stdout, "--- contextual_replacement.har\n")
+	secondDiffIndex := strings.Index(stdout, "--- remove_and_contextual_replacement.har\n")
+	assert.Zero(t, firstDiffIndex)
+	assert.Greater(t, secondDiffIndex, firstDiffIndex)
+
+	exitCode, _, stderr = runCommand("", "-timeout", "1ns", filepath.Join(harsPath, "contextual_replacement.har"))
+	assert.Equal(t, exitCodeFailure, exitCode)
+	assert.Contains(t, stderr, "context deadline exceeded")
+}
+
+func TestRunSanitizesStdin(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join(harsPath, "remove_and_contextual_replacement.har"))
+	require.NoError(t, err)
+	exitCode, stdout, _ := runCommand(string(content), "-extension", "har")
+	require.Equal(t, exitCodeSuccess, exitCode)
+
+	expectedContent, err := os.ReadFile(filepath.Join(harsPath, "expected_sanitized_files", "remove_and_contextual_replacement_sanitized.har"))
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContent), stdout)
+}
+
+func TestRunUsesBuiltInConfigAndRules(t *testing.T) {
+	content := `{"log": {"entries": [{"request": {"headers": [{"name": "Cookie", "value": "SUPERSECRET"}]}}]}}`
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	// The test runs in cmd/sanitizer, where there's no script/config.json or rules directory.
+	exitCode := run([]string{"-extension", "har"}, strings.NewReader(content), &stdout, &stderr)
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+	assert.NotContains(t, stdout.String(), "SUPERSECRET")
+	assert.Contains(t, stdout.String(), "secret_")
+}
+
+func TestRunLogsOnlyWhenVerbose(t *testing.T) {
+	content := `{"log": {"entries": [{"request": {"headers": [{"name": "Cookie", "value": "SUPERSECRET"}]}}]}}`
+	exitCode, stdout, stderr := runCommand(content, "-extension", "har")
+	require.Equal(t, exitCodeSuccess, exitCode)
+	assert.NotContains(t, stdout, "SUPERSECRET")
+	assert.Empty(t, stderr)
+
+	// The diagnostics don't include the values of the content.
+	exitCode, _, stderr = runCommand(content, "-extension", "har", "-v")
+	require.Equal(t, exitCodeSuccess, exitCode)
+	assert.Contains(t, stderr, "level=DEBUG")
+	assert.NotContains(t, stderr, "SUPERSECRET")
+}
+
+func TestRunStreamsFiles(t *testing.T) {
+	outputDir := t.TempDir()
+	exitCode, _, stderr := runCommand("", "-stream", "-output-dir", outputDir, "-report", filepath.Join(outputDir, "report.json"),
+		filepath.Join(harsPath, "contextual_replacement.har"),
+		filepath.Join(harsPath, "remove_and_contextual_replacement.har"),
+	)
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	for _, fileName := range []string{"contextual_replacement_sanitized.har", "remove_and_contextual_replacement_sanitized.har"} {
+		expectedContent, err := os.ReadFile(filepath.Join(harsPath, "expected_sanitized_files", fileName))
+		require.NoError(t, err)
+		actualContent, err := os.ReadFile(filepath.Join(outputDir, fileName))
+		require.NoError(t, err)
+		assert.Equal(t, string(expectedContent), string(actualContent))
+	}
+	assert.FileExists(t, filepath.Join(outputDir, "report.json"))
+
+	content, err := os.ReadFile(filepath.Join(harsPath, "remove_and_contextual_replacement.har"))
+	require.NoError(t, err)
+	exitCode, stdout, stderr := runCommand(string(content), "-stream", "-extension", "har")
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	expectedContent, err := os.ReadFile(filepath.Join(harsPath, "expected_sanitized_files", "remove_and_contextual_replacement_sanitized.har"))
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContent), stdout)
+
+	exitCode, _, _ = runCommand(`{"log": `, "-stream", "-extension", "har")
+	assert.Equal(t, exitCodeFailure, exitCode)
+}
+
+func TestRunStreamDiscardsOutputOnFailure(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	inputFilePath := filepath.Join(inputDir, "bad.har")
+	require.NoError(t, os.WriteFile(inputFilePath, []byte(`{"log": {"entries": [`), 0600))
+	exitCode, _, stderr := runCommand("", "-stream", "-output-dir", outputDir, inputFilePath)
+	assert.Equal(t, exitCodeFailure, exitCode, stderr)
+	// Neither the partial output nor its temporary file are left in the output directory.
+	outputEntries, err := os.ReadDir(outputDir)
+	require.NoError(t, err)
+	assert.Empty(t, outputEntries)
+}
+
+func TestRunOutputFormats(t *testing.T) {
+	content := `{"log": {"entries": [{"request": {"cookies": [{"name": "OTZ", "value": "b"}]}}]}}`
+	exitCode, stdout, stderr := runCommand(content, "-extension", "har", "-format", "pretty", "-indent", "\t")
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "{\n\t\"log\": {\n\t\t\"entries\": ["), stdout)
+
+	exitCode, stdout, stderr = runCommand(content, "-extension", "har", "-format", "minify")
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	assert.True(t, strings.HasPrefix(stdout, `{"log":{"entries":[{"request":{"cookies":[{"name":"OTZ","value":"<REMOVED>"}]}}]}}`), stdout)
+
+	exitCode, _, _ = runCommand(content, "-extension", "har", "-format", "unknown")
+	assert.Equal(t, exitCodeUsage, exitCode)
+}
+
+func TestRunPartialSanitization(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(rulesPath, []byte(`
+format: json
+rules:
+  "$[\"a\"]":
+    action: remove
+  "$[\"b\"]":
+    action: mask
+    keep_type: true
+`), 0644))
+	exitCode, stdout, stderr := runCommand(`{"a": "x", "b": true}`, "-rules", rulesPath, "-extension", "json")
+	assert.Equal(t, exitCodePartial, exitCode)
+	assert.Equal(t, `{"a": "<REMOVED>", "b": true}`, stdout)
+	assert.Contains(t, stderr, `Warning: '-' is partially processed: error applying rule $["b"] at $["b"]`)
+}
+
+func TestRunRejectsInvalidRules(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(rulesPath, []byte(`
+format: json
+rules:
+  "$[\"a\"]":
+    action: remove
+  invalid_regex:
+    action: remove
+    pattern: "("
+`), 0644))
+	exitCode, stdout, stderr := runCommand(`{"a": "x"}`, "-rules", rulesPath, "-extension", "json")
+	assert.Equal(t, exitCodeFailure, exitCode)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "Error: error compiling rule set "+rulesPath+": error compiling rule invalid_regex")
+}
+
+func TestRunReportsConflictingRules(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(rulesPath, []byte(`
+format: json
+rules:
+  "$[\"a\"]":
+    action: remove
+  secret_a:
+    action: "null"
+    pattern: "x"
+`), 0644))
+	exitCode, stdout, stderr := runCommand(`{"a": "x"}`, "-rules", rulesPath, "-extension", "json")
+	assert.Equal(t, exitCodeSuccess, exitCode)
+	assert.Equal(t, `{"a": "<REMOVED>"}`, stdout)
+	assert.Contains(t, stderr, `Warning: rule secret_a (null) is overridden by rule $["a"] (remove) at $["a"] in '-'`)
+}
+
+func TestRunWritesReport(t *testing.T) {
+	outputDir := t.TempDir()
+	exitCode, stdout, stderr := runCommand("", "-output-dir", outputDir, "-report", "-", "-report-format", "sarif",
+		filepath.Join(harsPath, "contextual_replacement.har"),
+		filepath.Join(harsPath, "already_sanitized.har"),
+	)
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	sarif := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &sarif))
+	assert.Equal(t, "2.1.0", sarif["version"])
+	assert.Contains(t, stdout, `"uri": "contextual_replacement.har"`)
+	assert.NotContains(t, stdout, `"uri": "already_sanitized.har"`)
+}
+
+func TestRunDryRun(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "sanitized")
+	exitCode, stdout, stderr := runCommand("", "-dry-run", "-output-dir", outputDir,
+		filepath.Join(harsPath, "contextual_replacement.har"),
+		filepath.Join(harsPath, "already_sanitized.har"),
+	)
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "--- contextual_replacement.har\n+++ contextual_replacement_sanitized.har\n"))
+	assert.Contains(t, stderr, "didn't match any value in '"+filepath.Join(harsPath, "already_sanitized.har")+"'")
+	assert.NoDirExists(t, outputDir)
+}
+
+func TestRunExitCodes(t *testing.T) {
+	testCases := []struct {
+		name             string
+		args             []string
+		expectedExitCode int
+	}{
+		{"stdin without extension", []string{}, exitCodeUsage},
+		{"multiple files to stdout", []string{"a.har", "b.har"}, exitCodeUsage},
+		{"diff and content to stdout", []string{"-diff", "-", "a.har"}, exitCodeUsage},
+		{"report and content to stdout", []string{"-report", "-", "a.har"}, exitCodeUsage},
+		{"unsupported report format", []string{"-report-format", "xml", "a.har"}, exitCodeUsage},
+		{"stream with diff", []string{"-stream", "-diff", "diff.txt", "a.har"}, exitCodeUsage},
+		{"negative concurrency", []string{"-concurrency", "-1", "a.har"}, exitCodeUsage},
+		{"unknown flag", []string{"-unknown"}, exitCodeUsage},
+		{"missing file", []string{filepath.Join(harsPath, "missing.har")}, exitCodeFailure},
+		{"unsupported extension", []string{configPath}, exitCodeFailure},
+		{"invalid file", []string{filepath.Join(harsPath, "invalid.har")}, exitCodeFailure},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			exitCode, _, stderr := runCommand("", testCase.args...)
+			assert.Equal(t, testCase.expectedExitCode, exitCode, stderr)
+		})
+	}
+}
+
+func TestRunWritesVaultWhenLaterFileFails(t *testing.T) {
+	outputDir := t.TempDir()
+	vaultPath := filepath.Join(outputDir, "vault.json")
+	passphrasePath := filepath.Join(outputDir, "passphrase.txt")
+	require.NoError(t, os.WriteFile(passphrasePath, []byte("vault passphrase\n"), 0600))
+	invalidFilePath := filepath.Join(t.TempDir(), "invalid.har")
+	require.NoError(t, os.WriteFile(invalidFilePath, []byte("{"), 0600))
+
+	exitCode, _, stderr := runCommand("", "-output-dir", outputDir, "-vault", vaultPath, "-vault-passphrase-file", passphrasePath,
+		filepath.Join(harsPath, "contextual_replacement.har"), invalidFilePath)
+	assert.Equal(t, exitCodeFailure, exitCode, stderr)
+	// The output of the first file is written, so the vault to desanitize it must be written too.
+	assert.FileExists(t, filepath.Join(outputDir, "contextual_replacement_sanitized.har"))
+	assert.FileExists(t, vaultPath)
+}
+
+func TestRunSanitizesAndDesanitizesWithVault(t *testing.T) {
+	outputDir := t.TempDir()
+	vaultPath := filepath.Join(outputDir, "vault.json")
+	passphrasePath := filepath.Join(outputDir, "passphrase.txt")
+	require.NoError(t, os.WriteFile(passphrasePath, []byte("vault passphrase\n"), 0600))
+	inputFilePath := filepath.Join(harsPath, "contextual_replacement.har")
+
+	exitCode, _, stderr := runCommand("", "-output-dir", outputDir, "-vault", vaultPath, "-vault-passphrase-file", passphrasePath, inputFilePath)
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	assert.FileExists(t, vaultPath)
+
+	exitCode, _, stderr = runCommand("", "-output-dir", outputDir, "-desanitize", "-vault", vaultPath, "-vault-passphrase-file", passphrasePath,
+		filepath.Join(outputDir, "contextual_replacement_sanitized.har"))
+	require.Equal(t, exitCodeSuccess, exitCode, stderr)
+	desanitizedContent, err := os.ReadFile(filepath.Join(outputDir, "contextual_replacement_desanitized.har"))
+	require.NoError(t, err)
+	inputContent, err := os.ReadFile(inputFilePath)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(inputContent), string(desanitizedContent))
+
+	exitCode, _, _ = runCommand("", "-desanitize", inputFilePath)
+	assert.Equal(t, exitCodeUsage, exitCode)
+	exitCode, _, _ = runCommand("", "-desanitize", "-vault", filepath.Join(outputDir, "missing.json"), "-vault-passphrase-file", passphrasePath, inputFilePath)
+	assert.Equal(t, exitCodeFailure, exitCode)
+}
@@ -0,0 +1,12 @@
+// Package rules embeds the default rule files, so the binaries built from the module can use them from any directory.
+package rules
+
+//goland:noinspection GoUnsortedImport
+import (
+	"embed"
+)
+
+// FS holds the default <file_extension>.yaml rule files.
+//
+//go:embed *.yaml
+var FS embed.FS
@@ -0,0 +1,62 @@
+package sanitizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// Supported rule actions.
+const (
+	// ActionContextualReplacement replaces identical values with the same replacement, to preserve context.
+	ActionContextualReplacement = "contextual_replacement"
+	// ActionRemove replaces the value with the config's RemovedSecretReplacement.
+	ActionRemove = "remove"
+	// ActionMask replaces all but the last RuleInfo.KeepLast characters of the value with the RuleInfo.MaskCharacter.
+	// Values that aren't longer than RuleInfo.KeepLast are masked completely.
+	ActionMask = "mask"
+	// ActionTruncate retains only the first RuleInfo.MaxLength characters of the value.
+	ActionTruncate = "truncate"
+	// ActionHash replaces the value with its SHA-256 hex digest, without a prefix.
+	ActionHash = "hash"
+	// ActionDelete removes the value along with its key (or the array element) from the document.
+	ActionDelete = "delete"
+	// ActionNull replaces the value with null.
+	ActionNull = "null"
+)
+
+const defaultMaskCharacter = "*"
+
+// isStructuralAction returns true if the action doesn't produce a string replacement, but changes the document structure.
+func isStructuralAction(action string) bool {
+	return action == ActionDelete || action == ActionNull
+}
+
+func mask(value string, keepLast int, maskCharacter string) string {
+	if maskCharacter == "" {
+		maskCharacter = defaultMaskCharacter
+	}
+	valueLength := utf8.RuneCountInString(value)
+	// Keeping all the characters of a short value would leave it unsanitized.
+	if keepLast >= valueLength {
+		keepLast = 0
+	}
+	maskedLength := valueLength - max(keepLast, 0)
+	return strings.Repeat(maskCharacter, maskedLength) + string([]rune(value)[maskedLength:])
+}
+
+func truncate(value string, maxLength int) string {
+	if utf8.RuneCountInString(value) <= maxLength {
+		return value
+	}
+	return string([]rune(value)[:max(maxLength, 0)])
+}
+
+// hashValue returns the SHA-256 hex digest of the value. Values that look like digests (Ex: API keys) are hashed too,
+// as they can't be told apart from the digests of an earlier sanitization.
+func hashValue(value string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(value))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
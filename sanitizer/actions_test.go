@@ -0,0 +1,30 @@
+package sanitizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "******7890", mask("1234567890", 4, ""))
+	assert.Equal(t, "######7890", mask("1234567890", 4, "#"))
+	assert.Equal(t, "***", mask("abc", 0, ""))
+	assert.Equal(t, "***", mask("abc", 5, ""))
+	assert.Equal(t, "****", mask("abcd", 4, ""))
+	assert.Equal(t, "**ü", mask("äöü", 1, ""))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "abc", truncate("abcdef", 3))
+	assert.Equal(t, "abc", truncate("abc", 10))
+	assert.Equal(t, "", truncate("abc", 0))
+	assert.Equal(t, "äö", truncate("äöü", 2))
+}
+
+func TestHash(t *testing.T) {
+	hashedValue := hashValue("abc")
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", hashedValue)
+	// A value that looks like a digest is still hashed, as it may be a secret (Ex: an API key).
+	assert.NotEqual(t, hashedValue, hashValue(hashedValue))
+}
@@ -0,0 +1,154 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PaesslerAG/gval"
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/antchfx/xpath"
+	"regexp"
+	"slices"
+)
+
+// CompiledRule is a rule with its JSON path and pattern compiled.
+type CompiledRule struct {
+	Key  string
+	Info RuleInfo
+	// jsonPath selects the values the rule is applied to (see RuleInfo.GetJsonPath), along with their JSON paths.
+	jsonPath gval.Evaluable
+	// pattern is the compiled RuleInfo.Pattern, or nil if the rule has none.
+	pattern *regexp.Regexp
+	// pathMatcher matches the rule's JSON path in the content, and its pattern while streaming (see
+	// Sanitizer.SanitizeStream).
+	pathMatcher *jsonPathMatcher
+	// xpath selects the nodes the rule is applied to for ContentFormatXml, instead of the jsonPath.
+	xpath *xpath.Expr
+	// lineFilter is the compiled RuleInfo.LineFilter, or nil if the rule has none.
+	lineFilter *regexp.Regexp
+	// keyPattern is the compiled RuleInfo.KeyPattern, or nil if the rule has none.
+	keyPattern *regexp.Regexp
+	// sectionPattern is the compiled RuleInfo.Section, or nil if the rule has none.
+	sectionPattern *regexp.Regexp
+	// columnPattern is the compiled RuleInfo.Column, anchored to match the whole name of the column, or nil if the rule
+	// has none.
+	columnPattern *regexp.Regexp
+}
+
+// CompiledRuleSet is a RuleSet with its rules compiled once, so it can be applied to any number of files. It's safe for
+// concurrent use.
+type CompiledRuleSet struct {
+	// RuleSet is the rule set the compiled rule set is compiled from.
+	RuleSet RuleSet
+	// Rules are the valid rules, in their order of precedence (see RuleSet.GetOrderedRuleKeys).
+	Rules []*CompiledRule
+	// Errors are the RuleCompileErrors of the invalid rules, which aren't in the Rules. They're also returned by
+	// CompileRuleSet, and in the PartialSanitizationError of each file sanitized with the rule set.
+	Errors []error
+}
+
+// collectFullPathsCtx is the context the JSON paths are compiled and evaluated in, so they return the values keyed by
+// their normalized JSON paths like jsonpath.GetWithPaths.
+var collectFullPathsCtx = context.WithValue(context.Background(), jsonpath.CollectFullPathsContextKey{}, true)
+
+func compileJsonPath(jsonPath string) (gval.Evaluable, error) {
+	return jsonpath.Language().NewEvaluableWithContext(collectFullPathsCtx, jsonPath)
+}
+
+// CompileRuleSet compiles the rules of the rule set, checking their actions against the config's supported actions.
+// The invalid rules are left out of the compiled rule set, and their RuleCompileErrors are returned joined, so they can
+// be reported when the rule set is loaded.
+func CompileRuleSet(ruleSet RuleSet, config Config) (*CompiledRuleSet, error) {
+	compiledRuleSet := &CompiledRuleSet{RuleSet: ruleSet}
+	// An unsupported format fails the sanitization of every file, rather than the rules.
+	format, formatErr := ruleSet.getFormat()
+	for _, ruleKey := range ruleSet.GetOrderedRuleKeys() {
+		var compiledRule *CompiledRule
+		var err error
+		switch format {
+		case ContentFormatXml:
+			compiledRule, err = compileXmlRule(ruleKey, ruleSet.Rules[ruleKey], ruleSet.Namespaces, config.SupportedActions)
+		case ContentFormatText:
+			compiledRule, err = compileTextRule(ruleKey, ruleSet.Rules[ruleKey], config.SupportedActions)
+		case ContentFormatDotenv, ContentFormatIni, ContentFormatProperties:
+			compiledRule, err = compileKeyValueRule(ruleKey, ruleSet.Rules[ruleKey], format, config.SupportedActions)
+		case ContentFormatCsv:
+			compiledRule, err = compileCsvRule(ruleKey, ruleSet.Rules[ruleKey], ruleSet.Headerless, config.SupportedActions)
+		default:
+			compiledRule, err = compileRule(ruleKey, ruleSet.Rules[ruleKey], config.SupportedActions)
+		}
+		if err == nil {
+			err = checkRuleFields(ruleSet.Rules[ruleKey], format)
+		}
+		if err != nil {
+			compiledRuleSet.Errors = append(compiledRuleSet.Errors, &RuleCompileError{RuleKey: ruleKey, Err: err})
+			continue
+		}
+		compiledRuleSet.Rules = append(compiledRuleSet.Rules, compiledRule)
+	}
+	return compiledRuleSet, errors.Join(append([]error{formatErr}, compiledRuleSet.Errors...)...)
+}
+
+// compileRule checks that the rule can be applied with the supported actions, and compiles it.
+func compileRule(ruleKey string, ruleInfo RuleInfo, supportedActions []string) (*CompiledRule, error) {
+	if !slices.Contains(supportedActions, ruleInfo.Action) {
+		return nil, fmt.Errorf("unsupported action (%s)", ruleInfo.Action)
+	} else if ruleInfo.Partial && isStructuralAction(ruleInfo.Action) {
+		return nil, fmt.Errorf("action (%s) cannot be partial", ruleInfo.Action)
+	}
+	compiledRule := &CompiledRule{Key: ruleKey, Info: ruleInfo}
+	var err error
+	if compiledRule.jsonPath, err = compileJsonPath(ruleInfo.GetJsonPath(ruleKey)); err != nil {
+		return nil, err
+	}
+	if ruleInfo.Pattern != "" {
+		if compiledRule.pattern, err = regexp.Compile(ruleInfo.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	compiledRule.pathMatcher = compileJsonPathMatcher(ruleInfo.GetJsonPath(ruleKey), compiledRule.jsonPath)
+	return compiledRule, nil
+}
+
+// checkRuleFields returns an error if the rule has fields that only apply to the rules of other formats.
+func checkRuleFields(ruleInfo RuleInfo, format string) error {
+	if ruleInfo.LineFilter != "" && format != ContentFormatText {
+		return errors.New("only the rules of text content can have a line filter")
+	} else if ruleInfo.KeyPattern != "" && !slices.Contains(keyValueFormats, format) {
+		return errors.New("only the rules of dotenv, ini and properties content can have a key pattern")
+	} else if ruleInfo.Section != "" && format != ContentFormatIni {
+		return errors.New("only the rules of ini content can have a section")
+	} else if (ruleInfo.Column != "" || ruleInfo.ColumnIndex != 0) && format != ContentFormatCsv {
+		return errors.New("only the rules of csv content can have a column")
+	}
+	return nil
+}
+
+// findValues returns the values selected by the JSON path in the content's tree, keyed by their JSON paths.
+// Evaluation errors (Ex: a key that isn't present in the content) mean there are no values at the JSON path.
+func findValues(jsonPath gval.Evaluable, tree interface{}) (map[string]interface{}, error) {
+	values, err := jsonPath(collectFullPathsCtx, tree)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	valuesMap, isMap := values.(map[string]interface{})
+	if !isMap {
+		return map[string]interface{}{}, fmt.Errorf("unexpected values of type %T", values)
+	}
+	return valuesMap, nil
+}
+
+// findJsonValues returns the values selected by the rule's JSON path in the tree, keyed by their JSON paths. The tree
+// is the value reached in the state of the rule's path pattern (0 for the whole content), or a value the rule matched
+// if the state is streamStateActive. The evaluation errors are returned joined, along with the values found at the
+// other paths.
+func (rule *CompiledRule) findJsonValues(tree interface{}, state int) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if state == streamStateActive {
+		values["$"] = tree
+		return values, nil
+	}
+	errs := rule.pathMatcher.collectValues("$", tree, []int{state}, values, nil)
+	return values, errors.Join(errs...)
+}
@@ -0,0 +1,230 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"cmp"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Supported values of Config.SecretKeyMode.
+const (
+	// SecretKeyModeSession generates a random secret key for each Sanitizer.
+	SecretKeyModeSession = "session"
+	// SecretKeyModeUser requires the secret key to be set with Sanitizer.SetSecretKey before sanitizing.
+	SecretKeyModeUser = "user"
+)
+
+const sessionKeySize = 32
+
+// Supported values of Config.OutputFormat.
+const (
+	// OutputFormatPreserve retains the original content as is, except for the sanitized values.
+	OutputFormatPreserve = "preserve"
+	// OutputFormatMinify removes the insignificant whitespace from the sanitized content.
+	OutputFormatMinify = "minify"
+	// OutputFormatPretty indents the sanitized content with the Config.OutputIndent.
+	OutputFormatPretty = "pretty"
+)
+
+const defaultOutputIndent = "  "
+
+// Supported values of RuleSet.Format.
+const (
+	// ContentFormatJson is JSON content. It's the default format.
+	ContentFormatJson = "json"
+	// ContentFormatYaml is YAML content, including multi-document streams. The rules' JSON paths are evaluated against each
+	// document.
+	ContentFormatYaml = "yaml"
+	// ContentFormatXml is XML content. The rules' keys (and the scopes of the pattern rules) are XPaths instead of JSON
+	// paths, and the rules are applied to the text of the attributes and elements.
+	ContentFormatXml = "xml"
+	// ContentFormatText is plain text content (Ex: logs). The rules are pattern rules matched against each line, and they
+	// replace the substrings captured by the patterns' named groups (see RuleInfo.Pattern).
+	ContentFormatText = "text"
+	// ContentFormatDotenv is dotenv content (Ex: .env files). The rules select the values by the patterns of their keys
+	// (see RuleInfo.KeyPattern) instead of JSON paths, like the rules of the other key/value formats.
+	ContentFormatDotenv = "dotenv"
+	// ContentFormatIni is INI content, whose rules can also select the values by the pattern of their section (see
+	// RuleInfo.Section).
+	ContentFormatIni = "ini"
+	// ContentFormatProperties is Java properties content (Ex: application.properties).
+	ContentFormatProperties = "properties"
+	// ContentFormatCsv is CSV content, including TSV and other delimiters (see RuleSet.Delimiter). The rules select the
+	// cells by their column (see RuleInfo.Column and RuleInfo.ColumnIndex) instead of JSON paths.
+	ContentFormatCsv = "csv"
+	// ContentFormatNdjson is newline delimited JSON content (Ex: structured logs, JSON Lines). The rules are applied to the
+	// JSON value of each line like the rules of ContentFormatJson.
+	ContentFormatNdjson = "ndjson"
+)
+
+var supportedFormats = []string{ContentFormatJson, ContentFormatYaml, ContentFormatXml, ContentFormatText, ContentFormatDotenv, ContentFormatIni, ContentFormatProperties, ContentFormatCsv, ContentFormatNdjson}
+
+type Config struct {
+	MaximumInputFileSizeThroughWebsiteInMB int      `json:"MaximumInputFileSizeThroughWebsiteInMB"`
+	MaximumInputFilesThroughWebsite        int      `json:"MaximumInputFilesThroughWebsite"`
+	RemovedSecretReplacement               string   `json:"RemovedSecretReplacement"`
+	SecretPrefix                           string   `json:"SecretPrefix"`
+	SupportedFileExtensions                []string `json:"SupportedFileExtensions"`
+	SupportedActions                       []string `json:"SupportedActions"`
+	// SecretKeyMode determines the key used to derive contextual replacements as HMAC-SHA256 of the secrets.
+	// If empty, the replacements are unkeyed SHA-256 hashes of the secrets, unless a key is set with Sanitizer.SetSecretKey.
+	SecretKeyMode string `json:"SecretKeyMode"`
+	// OutputFormat is the formatting of the sanitized content. Defaults to OutputFormatPretty.
+	OutputFormat string `json:"OutputFormat"`
+	// OutputIndent is the indent used by OutputFormatPretty. Defaults to 2 spaces.
+	OutputIndent string `json:"OutputIndent"`
+	// MaxConcurrency is the maximum number of rules applied to a file at a time. Defaults to runtime.GOMAXPROCS(0).
+	MaxConcurrency int `json:"MaxConcurrency"`
+	// MaximumStreamedInputFileSizeThroughWebsiteInMB is the maximum size of the files larger than the
+	// MaximumInputFileSizeThroughWebsiteInMB, which are sanitized while streaming them (see Sanitizer.SanitizeStream)
+	// instead of in memory. Such files are rejected if it's zero. The website keeps the sanitized content of a streamed
+	// file in memory until it's downloaded, so this also bounds the memory it uses.
+	MaximumStreamedInputFileSizeThroughWebsiteInMB int `json:"MaximumStreamedInputFileSizeThroughWebsiteInMB"`
+}
+
+type RuleInfo struct {
+	Description string `yaml:"description"`
+	Action      string `yaml:"action"`
+	// Pattern is a regex matched against every string value within the Scope. The action is applied to the matching values.
+	// For such rules, the rule key is only an identifier and not a JSON path.
+	Pattern string `yaml:"pattern"`
+	// Scope is the JSON path of the nodes whose string values are matched against the Pattern. Defaults to the whole document.
+	Scope string `yaml:"scope"`
+	// Partial replaces only the substrings matched by the Pattern instead of the whole value.
+	// If the Pattern has named groups, only the substrings they capture are replaced, and otherwise if it has capture
+	// groups, only the captured substrings are replaced.
+	Partial bool `yaml:"partial"`
+	// KeepLast is the number of trailing characters left unmasked by the mask action.
+	KeepLast int `yaml:"keep_last"`
+	// MaskCharacter is the character used by the mask action. Defaults to *.
+	MaskCharacter string `yaml:"mask_character"`
+	// MaxLength is the number of leading characters retained by the truncate action.
+	MaxLength int `yaml:"max_length"`
+	// KeepType replaces numbers, booleans, objects and arrays with a value of the same type instead of a string.
+	KeepType bool `yaml:"keep_type"`
+	// Recursive applies the action to each number, boolean and string within the matched objects and arrays, instead of
+	// replacing them whole.
+	Recursive bool `yaml:"recursive"`
+	// Priority determines which rule's replacement is applied when multiple rules match the same value. Rules with a
+	// higher priority take precedence, and rules with the same priority take precedence in the order of the rule file.
+	Priority int `yaml:"priority"`
+	// LineFilter is a regex the lines of ContentFormatText content must match for the rule to apply to them. Defaults to
+	// every line.
+	LineFilter string `yaml:"line_filter"`
+	// KeyPattern is a regex the keys of dotenv, INI and properties content must match for the rule to apply to their
+	// values. For such rules, the rule key is only an identifier.
+	KeyPattern string `yaml:"key_pattern"`
+	// Section is a regex the sections of INI content must match for the rule to apply to the values in them. The values
+	// before the first section are in the section with an empty name. Defaults to every section.
+	Section string `yaml:"section"`
+	// Column is a regex the whole name (in the header) of the columns of CSV content must match for the rule to apply to
+	// their cells (Ex: (?i)e-?mail).
+	Column string `yaml:"column"`
+	// ColumnIndex is the 1-based index of the column of CSV content whose cells the rule applies to, instead of its name.
+	ColumnIndex int `yaml:"column_index"`
+}
+
+// GetJsonPath returns the JSON path to evaluate for the rule with the specified key.
+func (ruleInfo RuleInfo) GetJsonPath(ruleKey string) string {
+	if ruleInfo.Pattern == "" {
+		return ruleKey
+	}
+	if ruleInfo.Scope == "" {
+		return "$"
+	}
+	return ruleInfo.Scope
+}
+
+// RuleSet is the rules applied to the content of a format (Ex: ContentFormatYaml, defaults to ContentFormatJson).
+type RuleSet struct {
+	Description string              `yaml:"description"`
+	Format      string              `yaml:"format"`
+	Rules       map[string]RuleInfo `yaml:"rules"`
+	// Namespaces are the namespaces (keyed by their prefixes) the prefixed names in the XPaths of ContentFormatXml rules
+	// are matched against. If it isn't set, the prefixed names are matched against the prefixes in the content.
+	Namespaces map[string]string `yaml:"namespaces"`
+	// Delimiter is the character separating the fields of ContentFormatCsv content (Ex: "\t" for TSV). Defaults to ",".
+	Delimiter string `yaml:"delimiter"`
+	// Headerless is whether the first record of ContentFormatCsv content is data instead of the names of the columns.
+	Headerless bool `yaml:"headerless"`
+	// RuleOrder is the order of the rule keys in the rule file, set when the rule set is unmarshalled from YAML.
+	// Rules that aren't present in it are ordered after the rest, by their keys.
+	RuleOrder []string `yaml:"-"`
+}
+
+// UnmarshalYAML unmarshals the rule set, recording the order of the rules in the RuleOrder.
+func (ruleSet *RuleSet) UnmarshalYAML(node *yaml.Node) error {
+	// The alias type doesn't have this method, to avoid recursing.
+	type ruleSetAlias RuleSet
+	if err := node.Decode((*ruleSetAlias)(ruleSet)); err != nil {
+		return err
+	}
+	ruleSet.RuleOrder = nil
+	for index := 0; node.Kind == yaml.MappingNode && index+1 < len(node.Content); index += 2 {
+		if node.Content[index].Value != "rules" || node.Content[index+1].Kind != yaml.MappingNode {
+			continue
+		}
+		rulesNode := node.Content[index+1]
+		for ruleIndex := 0; ruleIndex+1 < len(rulesNode.Content); ruleIndex += 2 {
+			ruleSet.RuleOrder = append(ruleSet.RuleOrder, rulesNode.Content[ruleIndex].Value)
+		}
+	}
+	return nil
+}
+
+// GetOrderedRuleKeys returns the rule keys in their order of precedence: by descending priority, and then by their
+// order in the RuleOrder.
+func (ruleSet RuleSet) GetOrderedRuleKeys() []string {
+	ruleKeys := make([]string, 0, len(ruleSet.Rules))
+	for ruleKey := range ruleSet.Rules {
+		ruleKeys = append(ruleKeys, ruleKey)
+	}
+	getOrderIndex := func(ruleKey string) int {
+		if index := slices.Index(ruleSet.RuleOrder, ruleKey); index >= 0 {
+			return index
+		}
+		return len(ruleSet.RuleOrder)
+	}
+	slices.SortFunc(ruleKeys, func(a string, b string) int {
+		return cmp.Or(
+			cmp.Compare(ruleSet.Rules[b].Priority, ruleSet.Rules[a].Priority),
+			cmp.Compare(getOrderIndex(a), getOrderIndex(b)),
+			cmp.Compare(a, b),
+		)
+	})
+	return ruleKeys
+}
+
+// getFormat returns the format of the content the rule set applies to, or an error if it isn't supported.
+func (ruleSet RuleSet) getFormat() (string, error) {
+	if ruleSet.Format == "" {
+		return ContentFormatJson, nil
+	} else if !slices.Contains(supportedFormats, ruleSet.Format) {
+		return "", fmt.Errorf("unsupported format (%s), supported formats are %s", ruleSet.Format, strings.Join(supportedFormats, ","))
+	} else if _, err := ruleSet.getCsvDelimiter(); ruleSet.Format == ContentFormatCsv && err != nil {
+		return "", err
+	}
+	return ruleSet.Format, nil
+}
+
+// getCsvDelimiter returns the delimiter of the fields of ContentFormatCsv content, or an error if it isn't a single
+// character that can separate fields.
+func (ruleSet RuleSet) getCsvDelimiter() (rune, error) {
+	if ruleSet.Delimiter == "" {
+		return ',', nil
+	}
+	delimiter, size := utf8.DecodeRuneInString(ruleSet.Delimiter)
+	if size != len(ruleSet.Delimiter) || delimiter == utf8.RuneError || strings.ContainsRune("\"\r\n", delimiter) {
+		return 0, fmt.Errorf("invalid delimiter (%q), it must be a single character other than a quote or a line ending", ruleSet.Delimiter)
+	}
+	return delimiter, nil
+}
+
+// GetRuleFilePath returns the path of the rule file for the specified file extension, relative to the project root.
+func GetRuleFilePath(fileExtension string) string {
+	return "rules/" + fileExtension + ".yaml"
+}
@@ -0,0 +1,268 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// csvField is a field of a record of CSV content.
+type csvField struct {
+	// value is the field with its quotes removed.
+	value string
+	// path is the JSON path the value is keyed by, $["row"]["column"] (see csvDocument.setPaths).
+	path string
+	line int
+	// start and end are the offsets of the field's text in the content, including its quotes.
+	start    int
+	end      int
+	isQuoted bool
+}
+
+// csvDocument is the records of CSV content, which the rules' columns are matched against.
+type csvDocument struct {
+	stringDocument
+	// header is the names of the columns, or nil if the content is headerless.
+	header []string
+	// records are the data records, excluding the header.
+	records [][]csvField
+}
+
+// csvContent is CSV content with any delimiter, which is a single document.
+//
+// Only the text of the sanitized fields is rewritten (quoted as needed), so the quoting of the other fields, the line
+// endings and the rest of the content are preserved.
+type csvContent struct {
+	content   string
+	delimiter string
+	document  *csvDocument
+	// fields are the fields of the data records, keyed by their paths.
+	fields map[string]csvField
+}
+
+// parseCsvContent parses the records of the CSV content as per RFC 4180, with the delimiter. The fields can be enclosed
+// in double quotes, with the quotes in them doubled, to contain delimiters and line endings. The empty lines are skipped.
+// Unless the content is headerless, its first record is the header.
+func parseCsvContent(content string, delimiter rune, isHeaderless bool) (*csvContent, error) {
+	csvContent := &csvContent{content: content, delimiter: string(delimiter), fields: map[string]csvField{}}
+	var records [][]csvField
+	// A byte order mark isn't part of the first field.
+	offset := len(content) - len(strings.TrimPrefix(content, "\ufeff"))
+	line := 1
+	for offset < len(content) {
+		if lineEnding := getCsvLineEnding(content[offset:]); lineEnding != "" {
+			offset += len(lineEnding)
+			line++
+			continue
+		}
+		record, err := csvContent.parseRecord(&offset, &line)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	document := &csvDocument{stringDocument: stringDocument{valueLines: map[string]int{}}, records: records}
+	if !isHeaderless && len(records) > 0 {
+		document.header = make([]string, len(records[0]))
+		for index, field := range records[0] {
+			document.header[index] = field.value
+		}
+		document.records = records[1:]
+	}
+	document.setPaths()
+	for _, record := range document.records {
+		for _, field := range record {
+			csvContent.fields[field.path] = field
+			document.valueLines[field.path] = field.line
+		}
+	}
+	csvContent.document = document
+	return csvContent, nil
+}
+
+// getCsvLineEnding returns the line ending the text starts with, or an empty string if it doesn't start with one.
+func getCsvLineEnding(text string) string {
+	if strings.HasPrefix(text, "\n") {
+		return "\n"
+	} else if strings.HasPrefix(text, "\r\n") {
+		return "\r\n"
+	}
+	return ""
+}
+
+// parseRecord parses the record at the offset, advancing the offset and the line past its line ending.
+func (content *csvContent) parseRecord(offset *int, line *int) ([]csvField, error) {
+	var record []csvField
+	for {
+		field := csvField{start: *offset, line: *line}
+		if strings.HasPrefix(content.content[*offset:], `"`) {
+			var builder strings.Builder
+			index := *offset + 1
+			for {
+				quoteIndex := strings.IndexByte(content.content[index:], '"')
+				if quoteIndex < 0 {
+					return nil, fmt.Errorf("line %d: unterminated quoted field", field.line)
+				}
+				builder.WriteString(content.content[index : index+quoteIndex])
+				*line += strings.Count(content.content[index:index+quoteIndex], "\n")
+				index += quoteIndex + 1
+				if !strings.HasPrefix(content.content[index:], `"`) {
+					break
+				}
+				// A doubled quote is a quote in the field.
+				builder.WriteByte('"')
+				index++
+			}
+			rest := content.content[index:]
+			if rest != "" && getCsvLineEnding(rest) == "" && !strings.HasPrefix(rest, content.delimiter) {
+				return nil, fmt.Errorf("line %d: unexpected text after the quoted field", *line)
+			}
+			field.value, field.end, field.isQuoted = builder.String(), index, true
+		} else {
+			field.end = *offset
+			for field.end < len(content.content) && content.content[field.end] != '\n' && !strings.HasPrefix(content.content[field.end:], content.delimiter) {
+				field.end++
+			}
+			// The carriage return of the line ending isn't part of the field.
+			if field.end > field.start && content.content[field.end-1] == '\r' && !strings.HasPrefix(content.content[field.end:], content.delimiter) {
+				field.end--
+			}
+			field.value = content.content[field.start:field.end]
+		}
+		record = append(record, field)
+		*offset = field.end
+		if strings.HasPrefix(content.content[*offset:], content.delimiter) {
+			*offset += len(content.delimiter)
+			continue
+		}
+		if lineEnding := getCsvLineEnding(content.content[*offset:]); lineEnding != "" {
+			*offset += len(lineEnding)
+			*line++
+		}
+		return record, nil
+	}
+}
+
+// setPaths sets the paths of the data records' fields: $["row"]["column"], where the row is the 0-based index of the
+// record (excluding the header), and the column is the name of the column in the header. The column is its 1-based
+// index instead if the content is headerless, or if the name is empty or isn't unique.
+func (document *csvDocument) setPaths() {
+	nameCounts := map[string]int{}
+	for _, name := range document.header {
+		nameCounts[name]++
+	}
+	for row, record := range document.records {
+		rowPath := appendJsonPathSegment("$", strconv.Itoa(row))
+		for index := range record {
+			column := strconv.Itoa(index + 1)
+			if index < len(document.header) && document.header[index] != "" && nameCounts[document.header[index]] == 1 {
+				column = document.header[index]
+			}
+			record[index].path = appendJsonPathSegment(rowPath, column)
+		}
+	}
+}
+
+// findValues returns the values of the data records' fields in the rule's column (see findColumnValues).
+func (document *csvDocument) findValues(rule *CompiledRule, _ int) (map[string]interface{}, *regexp.Regexp, []error) {
+	return document.findColumnValues(rule.columnPattern, rule.Info.ColumnIndex), rule.pattern, nil
+}
+
+// findColumnValues returns the values of the data records' fields in the columns whose names match the pattern, or in
+// the column with the 1-based index (or in every column if neither is set), keyed by their paths. There's nothing to sanitize in empty fields, so they're
+// skipped.
+func (document *csvDocument) findColumnValues(columnPattern *regexp.Regexp, columnIndex int) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, record := range document.records {
+		for index, field := range record {
+			isSelected := (columnPattern == nil && columnIndex == 0) || columnIndex == index+1 ||
+				(columnPattern != nil && index < len(document.header) && columnPattern.MatchString(document.header[index]))
+			if isSelected && field.value != "" {
+				values[field.path] = field.value
+			}
+		}
+	}
+	return values
+}
+
+// getValues returns the values of the data records' fields, keyed by their paths.
+func (document *csvDocument) getValues() map[string]interface{} {
+	return document.findColumnValues(nil, 0)
+}
+
+func (content *csvContent) getDocuments() []document {
+	return []document{content.document}
+}
+
+func (content *csvContent) setValues(replacementMaps []map[string]jsonValueReplacement) (string, error) {
+	var errs []error
+	edits := make([]jsonEdit, 0, len(replacementMaps[0]))
+	for path, replacement := range replacementMaps[0] {
+		field, isPresent := content.fields[path]
+		if !isPresent {
+			errs = append(errs, &PathWriteError{JsonPath: path, Err: errors.New("no value at the JSON path")})
+			continue
+		}
+		value := ""
+		if replacement.Delete || json.Unmarshal([]byte(replacement.RawValue), &value) != nil {
+			errs = append(errs, &PathWriteError{JsonPath: path, Err: fmt.Errorf("the replacement (%s) isn't a string", replacement.RawValue)})
+			continue
+		}
+		edits = append(edits, jsonEdit{Start: field.start, End: field.end, Text: content.encodeField(field, value)})
+	}
+	return applyJsonEdits(content.content, edits), errors.Join(errs...)
+}
+
+// encodeField returns the text of the value written in place of the field, which is quoted if the field was quoted or
+// the value can't be written unquoted.
+func (content *csvContent) encodeField(field csvField, value string) string {
+	if field.isQuoted || strings.ContainsAny(value, "\"\r\n") || strings.Contains(value, content.delimiter) {
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	}
+	return value
+}
+
+// format returns the sanitized content as is, as CSV content isn't reformatted.
+func (content *csvContent) format(sanitizedContent string, outputFormat string, indent string) ([]byte, error) {
+	return formatUnstructuredContent(sanitizedContent, outputFormat)
+}
+
+// compileCsvRule checks that the rule can be applied to CSV content with the supported actions, and compiles its
+// column and pattern. The rules select the cells by their column (by a pattern of its name, or its index if the content
+// is headerless), and their pattern.
+func compileCsvRule(ruleKey string, ruleInfo RuleInfo, isHeaderless bool, supportedActions []string) (*CompiledRule, error) {
+	if !slices.Contains(supportedActions, ruleInfo.Action) {
+		return nil, fmt.Errorf("unsupported action (%s)", ruleInfo.Action)
+	} else if isStructuralAction(ruleInfo.Action) {
+		return nil, fmt.Errorf("action (%s) isn't supported for CSV content", ruleInfo.Action)
+	} else if ruleInfo.Column == "" && ruleInfo.ColumnIndex == 0 && ruleInfo.Pattern == "" {
+		return nil, errors.New("the rules of CSV content must have a column or a pattern")
+	} else if ruleInfo.Column != "" && ruleInfo.ColumnIndex != 0 {
+		return nil, errors.New("the column can't be selected by both its name and its index")
+	} else if ruleInfo.ColumnIndex < 0 {
+		return nil, fmt.Errorf("invalid column index (%d), the columns are numbered from 1", ruleInfo.ColumnIndex)
+	} else if ruleInfo.Column != "" && isHeaderless {
+		return nil, errors.New("the columns of headerless CSV content can only be selected by their index")
+	} else if ruleInfo.Scope != "" {
+		return nil, errors.New("the rules of CSV content can't have a scope")
+	}
+	compiledRule := &CompiledRule{Key: ruleKey, Info: ruleInfo}
+	var err error
+	if ruleInfo.Pattern != "" {
+		if compiledRule.pattern, err = regexp.Compile(ruleInfo.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	if ruleInfo.Column != "" {
+		if compiledRule.columnPattern, err = regexp.Compile("^(?:" + ruleInfo.Column + ")$"); err != nil {
+			return nil, err
+		}
+	}
+	return compiledRule, nil
+}
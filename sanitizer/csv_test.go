@@ -0,0 +1,168 @@
+package sanitizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeCsv(t *testing.T) {
+	content := "\ufeffid,email,notes,password\r\n" +
+		"1,alice@example.com,\"Line 1\r\ntoken=abc\",\"p,w\"\r\n" +
+		"\r\n" +
+		"2,bob@example.com,,hunter2\r\n" +
+		"3,alice@example.com,\"She said \"\"hi\"\"\",\r\n"
+	sanitizer := newTestRuleSetSanitizer(t, "csv", RuleSet{Format: ContentFormatCsv, Rules: map[string]RuleInfo{
+		"email":    {Action: ActionContextualReplacement, Column: "email"},
+		"password": {Action: ActionRemove, ColumnIndex: 4},
+		"token":    {Action: ActionMask, Pattern: `token=(\w+)`, Partial: true},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "csv", "a.csv", "a_sanitized.csv")
+	require.NoError(t, err)
+
+	findings := map[string]Finding{}
+	for _, finding := range result.Report.Findings {
+		findings[finding.JsonPath] = finding
+	}
+	require.Len(t, findings, 6)
+	// The same emails in different rows get the same replacement, so the rows can still be joined by them.
+	emailReplacement := findings[`$["0"]["email"]`].Replacement
+	assert.Equal(t, emailReplacement, findings[`$["2"]["email"]`].Replacement)
+	assert.NotEqual(t, emailReplacement, findings[`$["1"]["email"]`].Replacement)
+	assert.Equal(t, map[string]int{
+		`$["0"]["email"]`:    2,
+		`$["0"]["notes"]`:    2,
+		`$["0"]["password"]`: 3,
+		`$["1"]["email"]`:    5,
+		`$["1"]["password"]`: 5,
+		`$["2"]["email"]`:    6,
+	}, map[string]int{
+		`$["0"]["email"]`:    findings[`$["0"]["email"]`].Line,
+		`$["0"]["notes"]`:    findings[`$["0"]["notes"]`].Line,
+		`$["0"]["password"]`: findings[`$["0"]["password"]`].Line,
+		`$["1"]["email"]`:    findings[`$["1"]["email"]`].Line,
+		`$["1"]["password"]`: findings[`$["1"]["password"]`].Line,
+		`$["2"]["email"]`:    findings[`$["2"]["email"]`].Line,
+	})
+
+	// The quoted fields stay quoted, and the rest of the content (including the empty field and line) is preserved.
+	assert.Equal(t, "\ufeffid,email,notes,password\r\n"+
+		"1,"+emailReplacement+",\"Line 1\r\ntoken=***\",\"<REMOVED>\"\r\n"+
+		"\r\n"+
+		"2,"+findings[`$["1"]["email"]`].Replacement+",,<REMOVED>\r\n"+
+		"3,"+emailReplacement+",\"She said \"\"hi\"\"\",\r\n", result.Content)
+}
+
+func TestSanitizeCsvWithDelimiters(t *testing.T) {
+	for _, delimiter := range []string{"\t", ";", "|"} {
+		content := strings.ReplaceAll("name|comment\nalice|\"x|yz\"\nbob|abcdef\n", "|", delimiter)
+		sanitizer := newTestRuleSetSanitizer(t, "csv", RuleSet{Format: ContentFormatCsv, Delimiter: delimiter, Rules: map[string]RuleInfo{
+			"comment": {Action: ActionMask, Column: "comment", KeepLast: 1, MaskCharacter: delimiter},
+		}})
+		sanitizedContent, _, _, err := sanitizer.Sanitize(content, "csv", "a.csv", "a_sanitized.csv")
+		require.NoError(t, err, delimiter)
+		// The replacements containing the delimiter are quoted.
+		assert.Equal(t, strings.ReplaceAll("name|comment\nalice|\"|||z\"\nbob|\"|||||f\"\n", "|", delimiter), sanitizedContent, delimiter)
+	}
+}
+
+func TestSanitizeHeaderlessCsv(t *testing.T) {
+	content := "a,a,x\n1,2,x\n"
+	sanitizer := newTestRuleSetSanitizer(t, "csv", RuleSet{Format: ContentFormatCsv, Headerless: true, Rules: map[string]RuleInfo{
+		"second": {Action: ActionRemove, ColumnIndex: 2},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "csv", "a.csv", "a_sanitized.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "a,<REMOVED>,x\n1,<REMOVED>,x\n", result.Content)
+	require.Len(t, result.Report.Findings, 2)
+	assert.Equal(t, `$["0"]["2"]`, result.Report.Findings[0].JsonPath)
+
+	// The columns whose names aren't unique are keyed by their index.
+	sanitizer = newTestRuleSetSanitizer(t, "csv", RuleSet{Format: ContentFormatCsv, Rules: map[string]RuleInfo{"a": {Action: ActionRemove, Column: "a"}}})
+	result, err = sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "csv", "a.csv", "a_sanitized.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "a,a,x\n<REMOVED>,<REMOVED>,x\n", result.Content)
+	require.Len(t, result.Report.Findings, 2)
+	assert.Equal(t, `$["0"]["1"]`, result.Report.Findings[0].JsonPath)
+}
+
+func TestDesanitizeCsv(t *testing.T) {
+	content := "user,token\nalice,abc\nbob,\"x,y\"\n"
+	sanitizer := newTestRuleSetSanitizer(t, "csv", RuleSet{Format: ContentFormatCsv, Rules: map[string]RuleInfo{
+		"token": {Action: ActionContextualReplacement, Column: "token"},
+	}})
+	vault := NewVault()
+	sanitizer.SetVault(vault)
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "csv", "a.csv", "a_sanitized.csv")
+	require.NoError(t, err)
+	assert.NotContains(t, sanitizedContent, "abc")
+	desanitizedContent, _, _, err := sanitizer.Desanitize(sanitizedContent, "csv", "a_sanitized.csv", "a_desanitized.csv", vault)
+	require.NoError(t, err)
+	assert.Equal(t, content, desanitizedContent)
+}
+
+func TestSanitizeWithCsvRuleFiles(t *testing.T) {
+	for fileExtension, content := range map[string]string{
+		"csv": "id,email,phone,password\n1,alice@example.com,+1 555 0100,hunter2\n",
+		"tsv": "id\temail\tphone\tpassword\n1\talice@example.com\t+1 555 0100\thunter2\n",
+	} {
+		sanitizer := newTestRuleSetSanitizer(t, fileExtension, loadTestRuleSet(t, fileExtension))
+		sanitizedContent, _, _, err := sanitizer.Sanitize(content, fileExtension, "a", "a_sanitized")
+		require.NoError(t, err, fileExtension)
+		for _, secret := range []string{"alice@example.com", "555 0100", "hunter2"} {
+			assert.NotContains(t, sanitizedContent, secret, fileExtension)
+		}
+		assert.True(t, strings.HasPrefix(sanitizedContent, content[:strings.Index(content, "\n")+3]), fileExtension)
+	}
+}
+
+func TestSanitizeWithCsvRuleFilesMixedCaseHeader(t *testing.T) {
+	for fileExtension, delimiter := range map[string]string{"csv": ",", "tsv": "\t"} {
+		header := strings.Join([]string{"Id", "EMAIL", "Phone", "Password", "Token", "Email_Verified"}, delimiter)
+		content := header + "\n" + strings.Join([]string{"1", "alice@example.com", "+1 555 0100", "hunter2", "abc123", "yes"}, delimiter) + "\n"
+		sanitizer := newTestRuleSetSanitizer(t, fileExtension, loadTestRuleSet(t, fileExtension))
+		result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, fileExtension, "a", "a_sanitized")
+		require.NoError(t, err, fileExtension)
+		for _, secret := range []string{"alice@example.com", "555 0100", "hunter2", "abc123"} {
+			assert.NotContains(t, result.Content, secret, fileExtension)
+		}
+		// The column patterns match the whole name of the columns.
+		for _, finding := range result.Report.Findings {
+			assert.NotEqual(t, `$["0"]["Email_Verified"]`, finding.JsonPath, fileExtension)
+		}
+		assert.True(t, strings.HasSuffix(result.Content, delimiter+"yes\n"), fileExtension)
+	}
+}
+
+func TestSanitizeCsvErrors(t *testing.T) {
+	sanitizer := newTestRuleSetSanitizer(t, "csv", RuleSet{Format: ContentFormatCsv, Rules: map[string]RuleInfo{"a": {Action: ActionRemove, Column: "a"}}})
+	for content, expectedErr := range map[string]string{
+		"a\n\"x\n":    "line 2: unterminated quoted field",
+		"a\n\"x\"y\n": "line 2: unexpected text after the quoted field",
+	} {
+		_, _, _, err := sanitizer.Sanitize(content, "csv", "a.csv", "a_sanitized.csv")
+		var invalidInputErr *InvalidInputError
+		assert.ErrorAs(t, err, &invalidInputErr, content)
+		assert.ErrorContains(t, err, expectedErr, content)
+	}
+
+	config := sanitizer.config
+	_, err := CompileRuleSet(RuleSet{Format: ContentFormatCsv, Delimiter: "ab"}, config)
+	assert.ErrorContains(t, err, `invalid delimiter ("ab")`)
+	for ruleInfo, expectedErr := range map[RuleInfo]string{
+		{Action: ActionRemove}:                              "must have a column or a pattern",
+		{Action: ActionDelete, Column: "a"}:                 "action (delete) isn't supported for CSV content",
+		{Action: ActionRemove, Column: "a", ColumnIndex: 1}: "both its name and its index",
+		{Action: ActionRemove, ColumnIndex: -1}:             "invalid column index (-1)",
+		{Action: ActionRemove, Pattern: "x", Scope: "$"}:    "can't have a scope",
+	} {
+		_, err := CompileRuleSet(RuleSet{Format: ContentFormatCsv, Rules: map[string]RuleInfo{"rule": ruleInfo}}, config)
+		assert.ErrorContains(t, err, expectedErr)
+	}
+	_, err = CompileRuleSet(RuleSet{Format: ContentFormatCsv, Headerless: true, Rules: map[string]RuleInfo{"a": {Action: ActionRemove, Column: "a"}}}, config)
+	assert.ErrorContains(t, err, "can only be selected by their index")
+	_, err = CompileRuleSet(RuleSet{Rules: map[string]RuleInfo{"a": {Action: ActionRemove, ColumnIndex: 1}}}, config)
+	assert.ErrorContains(t, err, "only the rules of csv content can have a column")
+}
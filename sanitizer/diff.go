@@ -0,0 +1,108 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"fmt"
+	"github.com/hexops/gotextdiff" // Library is deprecated, it needs to be replaced.
+	"github.com/hexops/gotextdiff/span"
+	"strings"
+)
+
+const (
+	// diffLookahead is the maximum number of lines searched (in each content) to resynchronize after differing lines.
+	diffLookahead = 1000
+	// diffAnchorLines is the number of consecutive equal lines required to resynchronize, unless only a line is replaced,
+	// inserted or deleted.
+	diffAnchorLines = 3
+)
+
+func getDiff(originalContent string, originalFileName string, modifiedContent string, modifiedFileName string) (string, bool) {
+	edits := computeLineEdits(span.URIFromPath(originalFileName), originalContent, modifiedContent)
+	diff := fmt.Sprint(gotextdiff.ToUnified(originalFileName, modifiedFileName, originalContent, edits))
+	isEmptyDiff := len(diff) == 0
+	if isEmptyDiff {
+		diff = "--- " + originalFileName + "\n+++ " + modifiedFileName + "\n"
+	}
+	return diff, isEmptyDiff
+}
+
+// computeLineEdits returns the line edits converting the original content into the modified content.
+// The memory used by the Myers diff grows quadratically with the number of differing lines, which runs out of memory
+// for large sanitized files. Instead, the lines are compared in order, and each run of differing lines is replaced up to
+// the nearest lines where the contents are equal again. This is linear for the localized changes of sanitization, but
+// the edits aren't always minimal.
+func computeLineEdits(uri span.URI, originalContent string, modifiedContent string) []gotextdiff.TextEdit {
+	originalLines := splitLines(originalContent)
+	modifiedLines := splitLines(modifiedContent)
+	edits := make([]gotextdiff.TextEdit, 0)
+	addEdit := func(originalStart int, originalEnd int, modifiedStart int, modifiedEnd int) {
+		edits = append(edits, gotextdiff.TextEdit{
+			Span:    span.New(uri, span.NewPoint(originalStart+1, 1, 0), span.NewPoint(originalEnd+1, 1, 0)),
+			NewText: strings.Join(modifiedLines[modifiedStart:modifiedEnd], ""),
+		})
+	}
+	originalIndex, modifiedIndex := 0, 0
+	for originalIndex < len(originalLines) && modifiedIndex < len(modifiedLines) {
+		if originalLines[originalIndex] == modifiedLines[modifiedIndex] {
+			originalIndex++
+			modifiedIndex++
+			continue
+		}
+		originalSkip, modifiedSkip, isSynchronized := findSynchronization(originalLines[originalIndex:], modifiedLines[modifiedIndex:])
+		if !isSynchronized {
+			break
+		}
+		addEdit(originalIndex, originalIndex+originalSkip, modifiedIndex, modifiedIndex+modifiedSkip)
+		originalIndex += originalSkip
+		modifiedIndex += modifiedSkip
+	}
+	if originalIndex < len(originalLines) || modifiedIndex < len(modifiedLines) {
+		addEdit(originalIndex, len(originalLines), modifiedIndex, len(modifiedLines))
+	}
+	return edits
+}
+
+// findSynchronization returns the fewest lines to skip in each of the contents (starting with differing lines), after
+// which the contents have diffAnchorLines equal lines (or equal lines up to their end), and whether they're found
+// within the diffLookahead. A single equal line is enough after a replaced, inserted or deleted line, as sanitization
+// mostly changes lines in place.
+func findSynchronization(originalLines []string, modifiedLines []string) (int, int, bool) {
+	for skipped := 1; skipped <= 2*diffLookahead; skipped++ {
+		for originalSkip := max(0, skipped-diffLookahead); originalSkip <= min(skipped, diffLookahead); originalSkip++ {
+			modifiedSkip := skipped - originalSkip
+			if originalSkip >= len(originalLines) || modifiedSkip >= len(modifiedLines) {
+				continue
+			}
+			anchorLines := diffAnchorLines
+			if skipped <= 2 {
+				anchorLines = 1
+			}
+			isAnchor := true
+			for index := 0; index < anchorLines && isAnchor; index++ {
+				originalIndex, modifiedIndex := originalSkip+index, modifiedSkip+index
+				if originalIndex >= len(originalLines) || modifiedIndex >= len(modifiedLines) {
+					// Equal lines up to the end of either content.
+					isAnchor = index > 0 && originalIndex == len(originalLines) && modifiedIndex == len(modifiedLines)
+					break
+				}
+				isAnchor = originalLines[originalIndex] == modifiedLines[modifiedIndex]
+			}
+			if isAnchor {
+				return originalSkip, modifiedSkip, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// splitLines splits the text into lines, retaining their line endings.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
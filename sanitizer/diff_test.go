@@ -0,0 +1,37 @@
+package sanitizer
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDiff(t *testing.T) {
+	diff, isDiffEmpty := getDiff("a\nb\nc\n", "a.txt", "a\nb\nc\n", "b.txt")
+	assert.True(t, isDiffEmpty)
+	assert.Equal(t, "--- a.txt\n+++ b.txt\n", diff)
+
+	diff, isDiffEmpty = getDiff("a\nb\nc\nd\ne\nf\n", "a.txt", "a\nx\nc\nd\ne\n", "b.txt")
+	assert.False(t, isDiffEmpty)
+	assert.Equal(t, "--- a.txt\n+++ b.txt\n@@ -1,6 +1,5 @@\n a\n-b\n+x\n c\n d\n e\n-f\n", diff)
+}
+
+func TestGetDiffOfLargeContent(t *testing.T) {
+	// Every other line of a large content is changed, which runs out of memory with the Myers diff.
+	originalLines := make([]string, 0)
+	modifiedLines := make([]string, 0)
+	for index := range 200000 {
+		line := "\"value\": \"" + strconv.Itoa(index) + "\","
+		originalLines = append(originalLines, line)
+		if index%2 == 0 {
+			line = "\"value\": \"<REMOVED>\","
+		}
+		modifiedLines = append(modifiedLines, line)
+	}
+	diff, isDiffEmpty := getDiff(strings.Join(originalLines, "\n"), "a.txt", strings.Join(modifiedLines, "\n"), "b.txt")
+	assert.False(t, isDiffEmpty)
+	assert.Equal(t, 100000, strings.Count(diff, "\n+\"value\": \"<REMOVED>\","))
+	assert.Equal(t, 100000, strings.Count(diff, "\n-\"value\": \""))
+}
@@ -0,0 +1,151 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// document is a document of the content, parsed once and shared by the rules applied to it. Each format has its own
+// document, which finds the values of the rules in its own way.
+type document interface {
+	// findValues returns the values the rule selects in the document, keyed by their paths, along with the errors of the
+	// rule. pathState is the state of the rule's path pattern the document is reached in (see
+	// ruleDetectionTaskInput.PathState). The values are matched against the returned pattern afterward, which is nil if
+	// the values are already the pattern's matches.
+	findValues(rule *CompiledRule, pathState int) (map[string]interface{}, *regexp.Regexp, []error)
+	// getValues returns the values of the document to search for patterns in, keyed by their paths.
+	getValues() map[string]interface{}
+	// getLine returns the line of the value at the path in the content, or 0 if it isn't recorded.
+	getLine(path string) int
+	// getScalarText returns the text in the content of the number, boolean or null at the path.
+	getScalarText(path string) (string, error)
+}
+
+// jsonDocument is JSON content (or content converted to JSON, Ex: YAML), whose values are selected by the rules' JSON
+// paths.
+type jsonDocument struct {
+	content string
+	// tree is the content decoded by encoding/json, which the rules' JSON paths are evaluated against. It's shared by the
+	// rules running in parallel, so it must not be modified.
+	tree interface{}
+
+	scalarTextsOnce sync.Once
+	// scalarTexts are the texts of the numbers, booleans and nulls in the content, keyed by their JSON path.
+	// They're collected on first use, as only the rules matching such values need them.
+	scalarTexts    map[string]string
+	scalarTextsErr error
+	// valueLines are the lines of the values in the content, keyed by their JSON path, for the formats that record them.
+	valueLines map[string]int
+}
+
+// parseJsonDocument parses the JSON content into a document.
+func parseJsonDocument(content string) (*jsonDocument, error) {
+	document := &jsonDocument{content: content}
+	if err := json.Unmarshal([]byte(content), &document.tree); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// findValues returns the values selected by the rule's JSON path. The errors of evaluating the JSON path only mean it
+// doesn't select some values (Ex: a missing key), so they aren't the rule's errors.
+func (document *jsonDocument) findValues(rule *CompiledRule, pathState int) (map[string]interface{}, *regexp.Regexp, []error) {
+	values, _ := rule.findJsonValues(document.tree, pathState)
+	return values, rule.pattern, nil
+}
+
+func (document *jsonDocument) getValues() map[string]interface{} {
+	return map[string]interface{}{"$": document.tree}
+}
+
+func (document *jsonDocument) getLine(jsonPath string) int {
+	return document.valueLines[jsonPath]
+}
+
+// getScalarText returns the text in the content of the number, boolean or null at the JSON path.
+func (document *jsonDocument) getScalarText(jsonPath string) (string, error) {
+	document.scalarTextsOnce.Do(func() {
+		document.scalarTexts = map[string]string{}
+		scanner := jsonScanner{content: document.content}
+		document.scalarTextsErr = scanner.collectScalarTexts("$", document.scalarTexts)
+	})
+	if document.scalarTextsErr != nil {
+		return "", document.scalarTextsErr
+	}
+	text, isPresent := document.scalarTexts[jsonPath]
+	if !isPresent {
+		return "", fmt.Errorf("no number, boolean or null at %s", jsonPath)
+	}
+	return text, nil
+}
+
+// stringDocument is embedded by the documents whose values are all strings (Ex: XML, CSV), along with the lines of the
+// values.
+type stringDocument struct {
+	// valueLines are the lines of the values in the content, keyed by their paths.
+	valueLines map[string]int
+}
+
+func (document *stringDocument) getLine(path string) int {
+	return document.valueLines[path]
+}
+
+func (document *stringDocument) getScalarText(path string) (string, error) {
+	return "", fmt.Errorf("no number, boolean or null at %s", path)
+}
+
+// parsedContent is the content of a file, parsed as per the format of its rule set.
+type parsedContent interface {
+	// getDocuments returns the documents the rules are evaluated against independently.
+	getDocuments() []document
+	// setValues writes the replacements of each document (keyed by the JSON paths of the values) in the content.
+	// The errors of the replacements that couldn't be written are returned along with the content.
+	setValues(replacementMaps []map[string]jsonValueReplacement) (string, error)
+	// format formats the sanitized content as per the output format (see Config.OutputFormat).
+	format(content string, outputFormat string, indent string) ([]byte, error)
+}
+
+// parseContent parses the content as per the format of the rule set (see RuleSet.Format), and its options for the format.
+func parseContent(content string, format string, ruleSet RuleSet) (parsedContent, error) {
+	switch format {
+	case ContentFormatYaml:
+		return parseYamlContent(content)
+	case ContentFormatXml:
+		return parseXmlContent(content)
+	case ContentFormatText:
+		return parseTextContent(content)
+	case ContentFormatDotenv, ContentFormatIni, ContentFormatProperties:
+		return parseKeyValueContent(content, format)
+	case ContentFormatCsv:
+		delimiter, err := ruleSet.getCsvDelimiter()
+		if err != nil {
+			return nil, err
+		}
+		return parseCsvContent(content, delimiter, ruleSet.Headerless)
+	case ContentFormatNdjson:
+		return parseNdjsonContent(content)
+	default:
+		document, err := parseJsonDocument(content)
+		return jsonContent{document: document}, err
+	}
+}
+
+// jsonContent is JSON content, which is a single document.
+type jsonContent struct {
+	document *jsonDocument
+}
+
+func (content jsonContent) getDocuments() []document {
+	return []document{content.document}
+}
+
+func (content jsonContent) setValues(replacementMaps []map[string]jsonValueReplacement) (string, error) {
+	return setJsonValues(content.document.content, replacementMaps[0])
+}
+
+func (content jsonContent) format(sanitizedContent string, outputFormat string, indent string) ([]byte, error) {
+	return FormatJson([]byte(sanitizedContent), outputFormat, indent)
+}
@@ -0,0 +1,56 @@
+package sanitizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJsonDocument(t *testing.T) {
+	document, err := parseJsonDocument(`{"a": [1.50, true, null, "1"], "k\"ey": {"n": -2e3}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a":    []interface{}{1.5, true, nil, "1"},
+		`k"ey`: map[string]interface{}{"n": -2000.0},
+	}, document.tree)
+
+	for jsonPath, expectedText := range map[string]string{
+		`$["a"]["0"]`:     "1.50",
+		`$["a"]["1"]`:     "true",
+		`$["a"]["2"]`:     "null",
+		`$["k\"ey"]["n"]`: "-2e3",
+	} {
+		text, err := document.getScalarText(jsonPath)
+		require.NoError(t, err, jsonPath)
+		assert.Equal(t, expectedText, text, jsonPath)
+	}
+	for _, jsonPath := range []string{`$["a"]["3"]`, `$["a"]`, `$["b"]`} {
+		_, err = document.getScalarText(jsonPath)
+		assert.Error(t, err, jsonPath)
+	}
+
+	_, err = parseJsonDocument(`{"a": }`)
+	assert.Error(t, err)
+}
+
+func TestGetDocumentLines(t *testing.T) {
+	for _, testCase := range []struct {
+		content      string
+		format       string
+		path         string
+		expectedLine int
+	}{
+		{"a: 1\nb: x\n", ContentFormatYaml, `$["b"]`, 2},
+		{"<a>\n<b>x</b>\n</a>", ContentFormatXml, "/a[1]/b[1]/text()[1]", 2},
+		{"a\nb c\n", ContentFormatText, "2:3-3", 2},
+		{"A=1\nB=x\n", ContentFormatDotenv, `$["B"]`, 2},
+		{"a,b\n1,2\n3,4\n", ContentFormatCsv, `$["1"]["b"]`, 3},
+		{"{}\n\n{\"b\": \"x\"}\n", ContentFormatNdjson, `$["b"]`, 3},
+	} {
+		parsedContent, err := parseContent(testCase.content, testCase.format, RuleSet{})
+		require.NoError(t, err, testCase.format)
+		documents := parsedContent.getDocuments()
+		assert.Equal(t, testCase.expectedLine, documents[len(documents)-1].getLine(testCase.path), testCase.format)
+	}
+}
@@ -0,0 +1,115 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSecretKeyRequired is returned when sanitizing without a secret key, if the config's SecretKeyMode is user.
+var ErrSecretKeyRequired = errors.New("a secret key is required to sanitize files")
+
+// UnsupportedFormatError is returned when there's no rule set for the file extension of the content.
+type UnsupportedFormatError struct {
+	FileExtension           string
+	SupportedFileExtensions []string
+}
+
+func (err *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported file extension (%s), supported file extensions are %s", err.FileExtension, strings.Join(err.SupportedFileExtensions, ","))
+}
+
+// InvalidInputError is returned when the content can't be parsed.
+type InvalidInputError struct {
+	FileName string
+	Err      error
+}
+
+func (err *InvalidInputError) Error() string {
+	return fmt.Sprintf("invalid content in %s: %s", err.FileName, err.Err)
+}
+
+func (err *InvalidInputError) Unwrap() error {
+	return err.Err
+}
+
+// RuleCompileError is returned when a rule is invalid (Ex: its JSON path or pattern doesn't compile, or its action isn't
+// supported), so it can't be applied at all.
+type RuleCompileError struct {
+	RuleKey string
+	Err     error
+}
+
+func (err *RuleCompileError) Error() string {
+	return fmt.Sprintf("error compiling rule %s: %s", err.RuleKey, err.Err)
+}
+
+func (err *RuleCompileError) Unwrap() error {
+	return err.Err
+}
+
+// RuleError is returned when a rule can't be applied to a value it matched (Ex: the value's type can't be kept).
+type RuleError struct {
+	RuleKey  string
+	JsonPath string
+	Err      error
+}
+
+func (err *RuleError) Error() string {
+	return fmt.Sprintf("error applying rule %s at %s: %s", err.RuleKey, err.JsonPath, err.Err)
+}
+
+func (err *RuleError) Unwrap() error {
+	return err.Err
+}
+
+// PathWriteError is returned when a replacement can't be written at its JSON path in the content.
+type PathWriteError struct {
+	JsonPath string
+	Err      error
+}
+
+func (err *PathWriteError) Error() string {
+	return fmt.Sprintf("error writing value at %s: %s", err.JsonPath, err.Err)
+}
+
+func (err *PathWriteError) Unwrap() error {
+	return err.Err
+}
+
+// PartialSanitizationError is returned along with the sanitized content when some rules or replacements couldn't be
+// applied. The rest of the content is sanitized, so it can be used with a warning.
+type PartialSanitizationError struct {
+	// Errors are the RuleCompileError, RuleError and PathWriteError errors that occurred.
+	Errors []error
+}
+
+func (err *PartialSanitizationError) Error() string {
+	messages := make([]string, 0, len(err.Errors))
+	for _, ruleErr := range err.Errors {
+		messages = append(messages, ruleErr.Error())
+	}
+	return fmt.Sprintf("%d error(s) while sanitizing: %s", len(err.Errors), strings.Join(messages, "; "))
+}
+
+func (err *PartialSanitizationError) Unwrap() []error {
+	return err.Errors
+}
+
+// newPartialSanitizationError returns a PartialSanitizationError with the errors, flattening the joined errors.
+// It returns nil if there are no errors.
+func newPartialSanitizationError(errs []error) error {
+	flattenedErrs := make([]error, 0, len(errs))
+	for _, err := range errs {
+		if joinedErr, isJoined := err.(interface{ Unwrap() []error }); isJoined {
+			flattenedErrs = append(flattenedErrs, joinedErr.Unwrap()...)
+		} else if err != nil {
+			flattenedErrs = append(flattenedErrs, err)
+		}
+	}
+	if len(flattenedErrs) == 0 {
+		return nil
+	}
+	return &PartialSanitizationError{Errors: flattenedErrs}
+}
@@ -0,0 +1,67 @@
+package sanitizer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeErrors(t *testing.T) {
+	sanitizer := newTestSanitizer(map[string]RuleInfo{
+		`$["a"]`:         {Action: ActionRemove},
+		`$[?(@.b ==`:     {Action: ActionRemove},
+		"invalid_regex":  {Action: ActionRemove, Pattern: `(`},
+		`$["c"]`:         {Action: "unknown"},
+		`$["d"]`:         {Action: ActionNull, Partial: true, Pattern: "x"},
+		`$["e"]`:         {Action: ActionMask, KeepType: true},
+		`$["not_found"]`: {Action: ActionRemove},
+	})
+
+	_, _, _, err := sanitizer.Sanitize(`{}`, "xml", "a.xml", "a_sanitized.xml")
+	unsupportedFormatErr := &UnsupportedFormatError{}
+	require.ErrorAs(t, err, &unsupportedFormatErr)
+	assert.Equal(t, "xml", unsupportedFormatErr.FileExtension)
+
+	_, _, _, err = sanitizer.Sanitize(`{"a": `, "json", "a.json", "a_sanitized.json")
+	invalidInputErr := &InvalidInputError{}
+	require.ErrorAs(t, err, &invalidInputErr)
+	assert.Equal(t, "a.json", invalidInputErr.FileName)
+
+	// The other rules are applied, and the errors of each rule are returned along with the sanitized content.
+	sanitizedContent, _, _, err := sanitizer.Sanitize(`{"a": "x", "c": "y", "d": "z", "e": true}`, "json", "a.json", "a_sanitized.json")
+	assert.Equal(t, "{\n  \"a\": \"<REMOVED>\",\n  \"c\": \"y\",\n  \"d\": \"z\",\n  \"e\": true\n}", sanitizedContent)
+	partialSanitizationErr := &PartialSanitizationError{}
+	require.ErrorAs(t, err, &partialSanitizationErr)
+	ruleKeys := make([]string, 0)
+	for _, ruleErr := range partialSanitizationErr.Errors {
+		ruleCompileErr := &RuleCompileError{}
+		if errors.As(ruleErr, &ruleCompileErr) {
+			ruleKeys = append(ruleKeys, ruleCompileErr.RuleKey)
+		}
+	}
+	assert.ElementsMatch(t, []string{`$[?(@.b ==`, "invalid_regex", `$["c"]`, `$["d"]`}, ruleKeys)
+	ruleErr := &RuleError{}
+	require.ErrorAs(t, err, &ruleErr)
+	assert.Equal(t, `$["e"]`, ruleErr.RuleKey)
+	assert.Len(t, partialSanitizationErr.Errors, 5)
+
+	userSanitizer := newTestSanitizer(map[string]RuleInfo{})
+	userSanitizer.config.SecretKeyMode = SecretKeyModeUser
+	_, _, _, err = userSanitizer.Sanitize(`{}`, "json", "a.json", "a_sanitized.json")
+	assert.ErrorIs(t, err, ErrSecretKeyRequired)
+}
+
+func TestNewPartialSanitizationError(t *testing.T) {
+	assert.NoError(t, newPartialSanitizationError(nil))
+	assert.NoError(t, newPartialSanitizationError([]error{nil}))
+
+	pathWriteErr := &PathWriteError{JsonPath: `$["a"]`, Err: errors.New("key not found")}
+	ruleErr := &RuleError{RuleKey: "b", JsonPath: `$["b"]`, Err: errors.New("type mismatch")}
+	err := newPartialSanitizationError([]error{errors.Join(pathWriteErr, nil), ruleErr})
+	partialSanitizationErr := &PartialSanitizationError{}
+	require.ErrorAs(t, err, &partialSanitizationErr)
+	assert.Equal(t, []error{pathWriteErr, ruleErr}, partialSanitizationErr.Errors)
+	assert.Equal(t, `2 error(s) while sanitizing: error writing value at $["a"]: key not found; error applying rule b at $["b"]: type mismatch`, err.Error())
+}
@@ -0,0 +1,397 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jsonValueReplacement is the replacement of a JSON value.
+type jsonValueReplacement struct {
+	// RawValue is the JSON text written in place of the value. Ignored if Delete is set.
+	RawValue string
+	// Delete removes the value along with its key (or the array element), instead of replacing it.
+	Delete bool
+}
+
+// parseJsonPath splits a normalized JSON path returned by jsonpath.GetWithPaths (Ex: $["log"]["entries"]["0"])
+// into its unescaped segments (Ex: [log entries 0]).
+func parseJsonPath(jsonPath string) ([]string, error) {
+	if !strings.HasPrefix(jsonPath, "$") {
+		return nil, fmt.Errorf("JSON path (%s) doesn't start with $", jsonPath)
+	}
+	segments := make([]string, 0)
+	remainingPath := jsonPath[1:]
+	for len(remainingPath) > 0 {
+		if !strings.HasPrefix(remainingPath, "[\"") {
+			return nil, fmt.Errorf("invalid segment in JSON path (%s) at: %s", jsonPath, remainingPath)
+		}
+		// Find the closing quote of the segment, skipping escaped characters.
+		endIndex := 2
+		for endIndex < len(remainingPath) && remainingPath[endIndex] != '"' {
+			if remainingPath[endIndex] == '\\' {
+				endIndex++
+			}
+			endIndex++
+		}
+		if endIndex+1 >= len(remainingPath) || remainingPath[endIndex+1] != ']' {
+			return nil, fmt.Errorf("unterminated segment in JSON path (%s) at: %s", jsonPath, remainingPath)
+		}
+		segment, err := strconv.Unquote(remainingPath[1 : endIndex+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid segment in JSON path (%s) at: %s", jsonPath, remainingPath)
+		}
+		segments = append(segments, segment)
+		remainingPath = remainingPath[endIndex+2:]
+	}
+	return segments, nil
+}
+
+// toJsonString marshals the value into a JSON string without escaping HTML characters.
+func toJsonString(value string) (string, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// jsonPathTrie holds the replacements of a JSON value and the values within it, keyed by their path segments.
+type jsonPathTrie struct {
+	jsonPath    string
+	replacement *jsonValueReplacement
+	children    map[string]*jsonPathTrie
+	isWritten   bool
+}
+
+// jsonEdit replaces the byte range [Start, End) of the content with the Text.
+type jsonEdit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// jsonMember is the byte range [Start, End) of an object member (from its key to the end of its value) or an array
+// element, and whether it's deleted.
+type jsonMember struct {
+	Start     int
+	End       int
+	IsDeleted bool
+}
+
+// setJsonValues replaces the value at each of the JSON paths (as returned by jsonpath.GetWithPaths) in the content.
+// Values are written back by their exact path segments, so keys with any characters can be replaced.
+// The content is scanned once, descending only into the values containing replacements, and the replacements within a
+// replaced or deleted value are ignored.
+func setJsonValues(content string, replacementMap map[string]jsonValueReplacement) (string, error) {
+	root := &jsonPathTrie{jsonPath: "$"}
+	var errs []error
+	for jsonPath, replacementValue := range replacementMap {
+		pathSegments, err := parseJsonPath(jsonPath)
+		if err != nil {
+			errs = append(errs, &PathWriteError{JsonPath: jsonPath, Err: err})
+			continue
+		}
+		node := root
+		for _, segment := range pathSegments {
+			if node.children == nil {
+				node.children = map[string]*jsonPathTrie{}
+			}
+			child, isPresent := node.children[segment]
+			if !isPresent {
+				child = &jsonPathTrie{jsonPath: appendJsonPathSegment(node.jsonPath, segment)}
+				node.children[segment] = child
+			}
+			node = child
+		}
+		node.jsonPath = jsonPath
+		node.replacement = &replacementValue
+	}
+	if root.replacement != nil && root.replacement.Delete {
+		errs = append(errs, &PathWriteError{JsonPath: root.jsonPath, Err: errors.New("cannot delete the root value")})
+		root.replacement = nil
+	}
+
+	scanner := jsonScanner{content: content}
+	edits := make([]jsonEdit, 0, len(replacementMap))
+	if err := scanner.writeValue(root, &edits); err != nil {
+		return content, errors.Join(append(errs, err)...)
+	}
+	errs = append(errs, getUnwrittenPathErrors(root)...)
+
+	return applyJsonEdits(content, edits), errors.Join(errs...)
+}
+
+// applyJsonEdits returns the content with the edits, which mustn't overlap, applied.
+func applyJsonEdits(content string, edits []jsonEdit) string {
+	slices.SortFunc(edits, func(a jsonEdit, b jsonEdit) int {
+		return a.Start - b.Start
+	})
+	var builder strings.Builder
+	builder.Grow(len(content))
+	lastIndex := 0
+	for _, edit := range edits {
+		builder.WriteString(content[lastIndex:edit.Start])
+		builder.WriteString(edit.Text)
+		lastIndex = edit.End
+	}
+	builder.WriteString(content[lastIndex:])
+	return builder.String()
+}
+
+// getUnwrittenPathErrors returns a PathWriteError for each replacement in the trie that wasn't written, as there's no
+// value at its path.
+func getUnwrittenPathErrors(node *jsonPathTrie) []error {
+	if node.isWritten {
+		return nil
+	}
+	var errs []error
+	if node.replacement != nil {
+		errs = append(errs, &PathWriteError{JsonPath: node.jsonPath, Err: errors.New("no value at the JSON path")})
+	}
+	segments := make([]string, 0, len(node.children))
+	for segment := range node.children {
+		segments = append(segments, segment)
+	}
+	slices.Sort(segments)
+	for _, segment := range segments {
+		errs = append(errs, getUnwrittenPathErrors(node.children[segment])...)
+	}
+	return errs
+}
+
+// getDeletionEdits returns the edits deleting the members of a container, along with their separating commas and
+// whitespace. The members of the container span from openEnd (just after its opening bracket) to closeStart (its
+// closing bracket).
+func getDeletionEdits(members []jsonMember, openEnd int, closeStart int) []jsonEdit {
+	lastKeptIndex := len(members) - 1
+	for lastKeptIndex >= 0 && members[lastKeptIndex].IsDeleted {
+		lastKeptIndex--
+	}
+	edits := make([]jsonEdit, 0)
+	for index, member := range members {
+		if !member.IsDeleted {
+			continue
+		}
+		if lastKeptIndex < 0 {
+			// Every member is deleted, remove everything within the container.
+			return []jsonEdit{{Start: openEnd, End: closeStart}}
+		} else if index < lastKeptIndex {
+			// Remove up to the next member.
+			edits = append(edits, jsonEdit{Start: member.Start, End: members[index+1].Start})
+		} else {
+			// The trailing members are removed along with the comma after the last kept member.
+			return append(edits, jsonEdit{Start: members[lastKeptIndex].End, End: members[len(members)-1].End})
+		}
+	}
+	return edits
+}
+
+const jsonWhitespace = " \t\n\r"
+
+// jsonScanner is a minimal scanner used to locate values in JSON content without decoding it.
+type jsonScanner struct {
+	content string
+	offset  int
+}
+
+func (scanner *jsonScanner) peek() byte {
+	if scanner.offset >= len(scanner.content) {
+		return 0
+	}
+	return scanner.content[scanner.offset]
+}
+
+func (scanner *jsonScanner) skipWhitespace() {
+	for scanner.offset < len(scanner.content) {
+		if !strings.ContainsRune(jsonWhitespace, rune(scanner.content[scanner.offset])) {
+			return
+		}
+		scanner.offset++
+	}
+}
+
+func (scanner *jsonScanner) expect(character byte) error {
+	scanner.skipWhitespace()
+	if scanner.peek() != character {
+		return fmt.Errorf("expected '%c' at offset %d", character, scanner.offset)
+	}
+	scanner.offset++
+	scanner.skipWhitespace()
+	return nil
+}
+
+// skipString skips a JSON string and returns its raw (quoted) content.
+func (scanner *jsonScanner) skipString() (string, error) {
+	start := scanner.offset
+	if scanner.peek() != '"' {
+		return "", fmt.Errorf("expected a string at offset %d", start)
+	}
+	for scanner.offset++; scanner.offset < len(scanner.content); scanner.offset++ {
+		switch scanner.content[scanner.offset] {
+		case '\\':
+			scanner.offset++
+		case '"':
+			scanner.offset++
+			return scanner.content[start:scanner.offset], nil
+		}
+	}
+	return "", fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func (scanner *jsonScanner) skipValue() error {
+	scanner.skipWhitespace()
+	start := scanner.offset
+	switch scanner.peek() {
+	case '"':
+		_, err := scanner.skipString()
+		return err
+	case '{', '[':
+		depth := 0
+		for scanner.offset < len(scanner.content) {
+			switch scanner.content[scanner.offset] {
+			case '"':
+				if _, err := scanner.skipString(); err != nil {
+					return err
+				}
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			scanner.offset++
+			if depth == 0 {
+				return nil
+			}
+		}
+		return fmt.Errorf("unterminated container at offset %d", start)
+	default:
+		// Numbers, booleans and null.
+		for scanner.offset < len(scanner.content) && !strings.ContainsRune(",}]"+jsonWhitespace, rune(scanner.content[scanner.offset])) {
+			scanner.offset++
+		}
+		if scanner.offset == start {
+			return fmt.Errorf("expected a value at offset %d", start)
+		}
+		return nil
+	}
+}
+
+// forEachMember calls visit for each member of the object or element of the array at the offset, with the scanner at
+// the start of the member's value, and moves the scanner past the container. visit must move the scanner past the value.
+// The segment is the member's unescaped key or the element's index, and memberStart is the offset of the member's key
+// or the element.
+func (scanner *jsonScanner) forEachMember(visit func(segment string, memberStart int) error) error {
+	scanner.skipWhitespace()
+	start := scanner.offset
+	opening := scanner.peek()
+	closing := byte(']')
+	if opening == '{' {
+		closing = '}'
+	} else if opening != '[' {
+		return fmt.Errorf("expected an object or array at offset %d", start)
+	}
+	if err := scanner.expect(opening); err != nil {
+		return err
+	}
+	for index := 0; scanner.peek() != closing; index++ {
+		if scanner.offset >= len(scanner.content) {
+			return fmt.Errorf("unterminated container at offset %d", start)
+		}
+		memberStart := scanner.offset
+		segment := strconv.Itoa(index)
+		if opening == '{' {
+			rawKey, err := scanner.skipString()
+			if err != nil {
+				return err
+			}
+			if err = scanner.expect(':'); err != nil {
+				return err
+			}
+			if err = json.Unmarshal([]byte(rawKey), &segment); err != nil {
+				return err
+			}
+		}
+		if err := visit(segment, memberStart); err != nil {
+			return err
+		}
+		scanner.skipWhitespace()
+		if scanner.peek() == ',' {
+			scanner.offset++
+			scanner.skipWhitespace()
+		} else if scanner.peek() != closing {
+			return fmt.Errorf("expected ',' or '%c' at offset %d", closing, scanner.offset)
+		}
+	}
+	scanner.offset++
+	return nil
+}
+
+// writeValue moves the scanner past the value at the offset, adding the edits of the replacements in the trie to it.
+func (scanner *jsonScanner) writeValue(node *jsonPathTrie, edits *[]jsonEdit) error {
+	scanner.skipWhitespace()
+	start := scanner.offset
+	if node.replacement != nil {
+		// Deletions are written by the container of the value.
+		if err := scanner.skipValue(); err != nil {
+			return err
+		}
+		*edits = append(*edits, jsonEdit{Start: start, End: scanner.offset, Text: node.replacement.RawValue})
+		node.isWritten = true
+		return nil
+	}
+	if len(node.children) == 0 || (scanner.peek() != '{' && scanner.peek() != '[') {
+		return scanner.skipValue()
+	}
+	members := make([]jsonMember, 0)
+	err := scanner.forEachMember(func(segment string, memberStart int) error {
+		child := node.children[segment]
+		isDeleted := child != nil && child.replacement != nil && child.replacement.Delete
+		var err error
+		if isDeleted {
+			child.isWritten = true
+			err = scanner.skipValue()
+		} else if child != nil {
+			err = scanner.writeValue(child, edits)
+		} else {
+			err = scanner.skipValue()
+		}
+		members = append(members, jsonMember{Start: memberStart, End: scanner.offset, IsDeleted: isDeleted})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	*edits = append(*edits, getDeletionEdits(members, start+1, scanner.offset-1)...)
+	return nil
+}
+
+// collectScalarTexts moves the scanner past the value at the offset, collecting the text of the numbers, booleans and
+// nulls within it (keyed by their JSON path).
+func (scanner *jsonScanner) collectScalarTexts(jsonPath string, texts map[string]string) error {
+	scanner.skipWhitespace()
+	switch scanner.peek() {
+	case '{', '[':
+		return scanner.forEachMember(func(segment string, _ int) error {
+			return scanner.collectScalarTexts(appendJsonPathSegment(jsonPath, segment), texts)
+		})
+	case '"':
+		_, err := scanner.skipString()
+		return err
+	default:
+		start := scanner.offset
+		if err := scanner.skipValue(); err != nil {
+			return err
+		}
+		texts[jsonPath] = scanner.content[start:scanner.offset]
+		return nil
+	}
+}
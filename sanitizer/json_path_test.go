@@ -0,0 +1,127 @@
+package sanitizer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJsonPath(t *testing.T) {
+	segments, err := parseJsonPath(`$["log"]["entries"]["0"]["k.e*y?|"]["q\"x]["]`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"log", "entries", "0", "k.e*y?|", `q"x][`}, segments)
+
+	for _, invalidJsonPath := range []string{`log`, `$.log`, `$["log"`, `$["log"]x`} {
+		_, err = parseJsonPath(invalidJsonPath)
+		assert.Error(t, err, invalidJsonPath)
+	}
+}
+
+func TestSetJsonValues(t *testing.T) {
+	content := `{
+  "log": {"entries": [
+    {"a": {"b": {"c": [{"headers": [{"name": "Cookie", "value": "deep"}]}]}}},
+    {"request": {"headers": [{"name": "Cookie", "value": "shallow"}]}}
+  ]},
+  "k.e*y?|": {"q\"x": "special", "0": "zero", "a": "escaped"},
+  "n": 1
+}`
+	contentJson := interface{}(nil)
+	require.NoError(t, json.Unmarshal([]byte(content), &contentJson))
+
+	replacementMap := map[string]jsonValueReplacement{}
+	for _, ruleJsonPath := range []string{`$..["headers"][?(@["name"] == "Cookie")]["value"]`, `$["k.e*y?|"][*]`} {
+		values, err := jsonpath.GetWithPaths(ruleJsonPath, contentJson)
+		require.NoError(t, err)
+		for jsonPath := range values.(map[string]interface{}) {
+			replacementMap[jsonPath] = jsonValueReplacement{RawValue: `"<REMOVED>"`}
+		}
+	}
+	require.Len(t, replacementMap, 5)
+
+	sanitizedContent, err := setJsonValues(content, replacementMap)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "log": {"entries": [
+    {"a": {"b": {"c": [{"headers": [{"name": "Cookie", "value": "<REMOVED>"}]}]}}},
+    {"request": {"headers": [{"name": "Cookie", "value": "<REMOVED>"}]}}
+  ]},
+  "k.e*y?|": {"q\"x": "<REMOVED>", "0": "<REMOVED>", "a": "<REMOVED>"},
+  "n": 1
+}`, sanitizedContent)
+}
+
+func TestSetJsonValuesReportsMissingPaths(t *testing.T) {
+	content := `{"a": ["x", "y"]}`
+	replacement := jsonValueReplacement{RawValue: `"z"`}
+	sanitizedContent, err := setJsonValues(content, map[string]jsonValueReplacement{
+		`$["a"]["1"]`:      replacement,
+		`$["a"]["2"]`:      replacement,
+		`$["b"]`:           replacement,
+		`$["a"]["0"]["c"]`: replacement,
+	})
+	pathWriteErr := &PathWriteError{}
+	require.ErrorAs(t, err, &pathWriteErr)
+	assert.Equal(t, `{"a": ["x", "z"]}`, sanitizedContent)
+}
+
+func TestSetJsonValuesDeletes(t *testing.T) {
+	content := `{
+  "a": 1,
+  "b": [1, 2, 3, 4],
+  "c": {"d": "x"},
+  "e": {"f": 1, "g": 2},
+  "h": true
+}`
+	deletion := jsonValueReplacement{Delete: true}
+	sanitizedContent, err := setJsonValues(content, map[string]jsonValueReplacement{
+		`$["a"]`:      deletion,
+		`$["b"]["1"]`: deletion,
+		`$["b"]["3"]`: deletion,
+		`$["c"]["d"]`: deletion,
+		`$["e"]["f"]`: deletion,
+		`$["e"]["g"]`: deletion,
+		`$["h"]`:      deletion,
+		`$["b"]["0"]`: {RawValue: "null"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "b": [null, 3],
+  "c": {},
+  "e": {}
+}`, sanitizedContent)
+
+	_, err = setJsonValues(content, map[string]jsonValueReplacement{`$`: deletion})
+	assert.Error(t, err)
+}
+
+func TestSetJsonValuesDeletesConsecutiveMembers(t *testing.T) {
+	content := `{
+  "a": [
+    1,
+    2,
+    3,
+    4
+  ],
+  "b": {"c": {"d": 1}, "e": 2}
+}`
+	deletion := jsonValueReplacement{Delete: true}
+	sanitizedContent, err := setJsonValues(content, map[string]jsonValueReplacement{
+		`$["a"]["0"]`: deletion,
+		`$["a"]["1"]`: deletion,
+		`$["a"]["3"]`: deletion,
+		`$["b"]["c"]`: deletion,
+		// Replacements within a deleted value are ignored.
+		`$["b"]["c"]["d"]`: {RawValue: "0"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "a": [
+    3
+  ],
+  "b": {"e": 2}
+}`, sanitizedContent)
+}
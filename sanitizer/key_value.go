@@ -0,0 +1,536 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// keyValueFormats are the formats of key/value content, whose rules select the values by the patterns of their keys
+// (and sections).
+var keyValueFormats = []string{ContentFormatDotenv, ContentFormatIni, ContentFormatProperties}
+
+// keyValueEntry is a key and its value in key/value content.
+type keyValueEntry struct {
+	// section is the INI section the entry is in, or empty if it's before the first section.
+	section string
+	key     string
+	// value is the value with its quotes and escapes removed.
+	value string
+	// path is the JSON path the value is keyed by, $["key"], or $["section"]["key"] in INI content. The keys before the
+	// first INI section are in the section with an empty name ($[""]["key"]), so their paths can't be the prefix of a
+	// section's paths. The occurrences of a repeated key after the first have their occurrence appended to the key (Ex:
+	// $["KEY#2"]).
+	path string
+	line int
+	// valueStart and valueEnd are the offsets of the value's text in the content, including its quotes.
+	valueStart int
+	valueEnd   int
+	// start and end are the offsets of the entry's lines in the content, including the line ending of its last line.
+	start int
+	end   int
+	// quote is the quote the value is enclosed in, or 0 if it isn't quoted.
+	quote byte
+}
+
+// keyValueContent is dotenv, INI or Java properties content, which is a single document.
+//
+// Only the text of the sanitized values is rewritten (quoted and escaped as per the format), so the comments, the order
+// of the entries and the line endings are preserved.
+type keyValueContent struct {
+	content string
+	// contentFormat is the key/value format of the content.
+	contentFormat string
+	document      *keyValueDocument
+	// entryIndexes are the indexes of the entries, keyed by their paths.
+	entryIndexes map[string]int
+}
+
+// keyValueParser parses the entries of key/value content line by line.
+type keyValueParser struct {
+	content string
+	// offset is the offset of the next line.
+	offset     int
+	lineNumber int
+	entries    []keyValueEntry
+	// paths are the paths of the entries.
+	paths map[string]bool
+	// hasSections is set for INI content, whose paths have the section of the entries.
+	hasSections bool
+}
+
+// parseKeyValueContent parses the dotenv, INI or properties content into its entries.
+func parseKeyValueContent(content string, format string) (*keyValueContent, error) {
+	parser := &keyValueParser{content: content, entries: []keyValueEntry{}, paths: map[string]bool{}}
+	var err error
+	switch format {
+	case ContentFormatDotenv:
+		err = parser.parseDotenv()
+	case ContentFormatIni:
+		err = parser.parseIni()
+	default:
+		err = parser.parseProperties()
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyValueContent := &keyValueContent{content: content, contentFormat: format, entryIndexes: map[string]int{}}
+	valueLines := map[string]int{}
+	for index, entry := range parser.entries {
+		keyValueContent.entryIndexes[entry.path] = index
+		valueLines[entry.path] = entry.line
+	}
+	keyValueContent.document = &keyValueDocument{stringDocument: stringDocument{valueLines: valueLines}, entries: parser.entries}
+	return keyValueContent, nil
+}
+
+// nextLine returns the offsets of the start and the end (excluding the line ending) of the next line, and whether
+// there's one.
+func (parser *keyValueParser) nextLine() (int, int, bool) {
+	if parser.offset >= len(parser.content) {
+		return 0, 0, false
+	}
+	start := parser.offset
+	end := strings.IndexByte(parser.content[start:], '\n')
+	if end < 0 {
+		end = len(parser.content)
+		parser.offset = end
+	} else {
+		end += start
+		parser.offset = end + 1
+	}
+	parser.lineNumber++
+	if end > start && parser.content[end-1] == '\r' {
+		end--
+	}
+	return start, end, true
+}
+
+// addEntry adds the entry, whose lines end at the current line.
+func (parser *keyValueParser) addEntry(entry keyValueEntry) {
+	sectionPath := "$"
+	if parser.hasSections {
+		sectionPath = appendJsonPathSegment(sectionPath, entry.section)
+	}
+	entry.path = appendJsonPathSegment(sectionPath, entry.key)
+	// The occurrence is appended to the key rather than as a segment, as the paths within a replaced value are dropped.
+	for occurrence := 2; parser.paths[entry.path]; occurrence++ {
+		entry.path = appendJsonPathSegment(sectionPath, entry.key+"#"+strconv.Itoa(occurrence))
+	}
+	parser.paths[entry.path] = true
+	entry.end = parser.offset
+	parser.entries = append(parser.entries, entry)
+}
+
+// skipBlanks returns the offset of the first character from the offset that isn't a space or a tab, up to the end.
+func (parser *keyValueParser) skipBlanks(offset int, end int) int {
+	for offset < end && (parser.content[offset] == ' ' || parser.content[offset] == '\t') {
+		offset++
+	}
+	return offset
+}
+
+// getUnquotedValueEnd returns the end of the unquoted value from the offset, which ends before an inline comment
+// (whitespace followed by one of the comment characters) and the trailing whitespace.
+func (parser *keyValueParser) getUnquotedValueEnd(offset int, end int, commentCharacters string) int {
+	for index := offset; index+1 < end; index++ {
+		if (parser.content[index] == ' ' || parser.content[index] == '\t') && strings.IndexByte(commentCharacters, parser.content[index+1]) >= 0 {
+			end = index
+			break
+		}
+	}
+	for end > offset && (parser.content[end-1] == ' ' || parser.content[end-1] == '\t') {
+		end--
+	}
+	return end
+}
+
+// parseDotenv parses KEY=value lines, optionally prefixed with export. The values can be enclosed in single quotes
+// (as is) or double quotes (with escapes), which can span multiple lines. The unquoted values end before an inline
+// comment.
+func (parser *keyValueParser) parseDotenv() error {
+	for {
+		start, end, hasLine := parser.nextLine()
+		if !hasLine {
+			return nil
+		}
+		lineNumber := parser.lineNumber
+		offset := parser.skipBlanks(start, end)
+		if offset == end || parser.content[offset] == '#' {
+			continue
+		}
+		if strings.HasPrefix(parser.content[offset:end], "export ") {
+			offset = parser.skipBlanks(offset+len("export "), end)
+		}
+		separator := strings.IndexByte(parser.content[offset:end], '=')
+		if separator <= 0 {
+			return fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+		entry := keyValueEntry{key: strings.TrimRight(parser.content[offset:offset+separator], " \t"), line: lineNumber, start: start}
+		entry.valueStart = parser.skipBlanks(offset+separator+1, end)
+		if entry.valueStart < end && (parser.content[entry.valueStart] == '"' || parser.content[entry.valueStart] == '\'') {
+			if err := parser.parseDotenvQuotedValue(&entry); err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+		} else {
+			entry.valueEnd = parser.getUnquotedValueEnd(offset+separator+1, end, "#")
+			entry.valueStart = min(entry.valueStart, entry.valueEnd)
+			entry.value = parser.content[entry.valueStart:entry.valueEnd]
+		}
+		parser.addEntry(entry)
+	}
+}
+
+// parseDotenvQuotedValue parses the quoted value at the entry's value start, up to its closing quote, skipping the
+// lines it spans.
+func (parser *keyValueParser) parseDotenvQuotedValue(entry *keyValueEntry) error {
+	entry.quote = parser.content[entry.valueStart]
+	var builder strings.Builder
+	for index := entry.valueStart + 1; index < len(parser.content); index++ {
+		character := parser.content[index]
+		if character == entry.quote {
+			entry.valueEnd = index + 1
+			entry.value = builder.String()
+			parser.lineNumber += strings.Count(parser.content[entry.valueStart:index], "\n")
+			if lineEnd := strings.IndexByte(parser.content[index:], '\n'); lineEnd < 0 {
+				parser.offset = len(parser.content)
+			} else {
+				parser.offset = index + lineEnd + 1
+			}
+			return nil
+		} else if character == '\\' && entry.quote == '"' && index+1 < len(parser.content) {
+			index++
+			switch parser.content[index] {
+			case 'n':
+				builder.WriteByte('\n')
+			case 'r':
+				builder.WriteByte('\r')
+			case 't':
+				builder.WriteByte('\t')
+			case '\\', '"', '$':
+				builder.WriteByte(parser.content[index])
+			default:
+				builder.WriteByte('\\')
+				builder.WriteByte(parser.content[index])
+			}
+			continue
+		}
+		builder.WriteByte(character)
+	}
+	return errors.New("unterminated quoted value")
+}
+
+// parseIni parses [section] headers and key=value (or key: value) lines. The values can be enclosed in quotes, and the
+// unquoted values end before an inline comment. The lines without a separator are keys without a value, and are
+// skipped.
+func (parser *keyValueParser) parseIni() error {
+	parser.hasSections = true
+	section := ""
+	for {
+		start, end, hasLine := parser.nextLine()
+		if !hasLine {
+			return nil
+		}
+		offset := parser.skipBlanks(start, end)
+		if offset == end || parser.content[offset] == ';' || parser.content[offset] == '#' {
+			continue
+		} else if parser.content[offset] == '[' {
+			sectionEnd := strings.IndexByte(parser.content[offset:end], ']')
+			if sectionEnd < 0 {
+				return fmt.Errorf("line %d: unterminated section header", parser.lineNumber)
+			}
+			section = strings.TrimSpace(parser.content[offset+1 : offset+sectionEnd])
+			continue
+		}
+		separator := strings.IndexAny(parser.content[offset:end], "=:")
+		if separator < 0 {
+			continue
+		}
+		entry := keyValueEntry{
+			section: section,
+			key:     strings.TrimRight(parser.content[offset:offset+separator], " \t"),
+			line:    parser.lineNumber,
+			start:   start,
+		}
+		entry.valueStart = parser.skipBlanks(offset+separator+1, end)
+		quoteEnd := -1
+		if entry.valueStart < end && (parser.content[entry.valueStart] == '"' || parser.content[entry.valueStart] == '\'') {
+			quoteEnd = strings.IndexByte(parser.content[entry.valueStart+1:end], parser.content[entry.valueStart])
+		}
+		if quoteEnd >= 0 {
+			entry.quote = parser.content[entry.valueStart]
+			entry.valueEnd = entry.valueStart + quoteEnd + 2
+			entry.value = parser.content[entry.valueStart+1 : entry.valueEnd-1]
+		} else {
+			entry.valueEnd = parser.getUnquotedValueEnd(offset+separator+1, end, ";#")
+			entry.valueStart = min(entry.valueStart, entry.valueEnd)
+			entry.value = parser.content[entry.valueStart:entry.valueEnd]
+		}
+		parser.addEntry(entry)
+	}
+}
+
+// parseProperties parses the logical lines of Java properties content as per java.util.Properties.load: the key ends
+// at the first unescaped =, : or whitespace, and the lines ending with an odd number of backslashes continue on the
+// next line.
+func (parser *keyValueParser) parseProperties() error {
+	for {
+		start, end, hasLine := parser.nextLine()
+		if !hasLine {
+			return nil
+		}
+		lineNumber := parser.lineNumber
+		offset := start
+		for offset < end && strings.IndexByte(" \t\f", parser.content[offset]) >= 0 {
+			offset++
+		}
+		if offset == end || parser.content[offset] == '#' || parser.content[offset] == '!' {
+			continue
+		}
+		logicalEnd := end
+		for isContinuedPropertiesLine(parser.content[offset:logicalEnd]) {
+			_, nextEnd, hasNextLine := parser.nextLine()
+			if !hasNextLine {
+				break
+			}
+			logicalEnd = nextEnd
+		}
+		line := parser.content[offset:logicalEnd]
+		keyEnd := 0
+		for keyEnd < len(line) && strings.IndexByte("=: \t\f", line[keyEnd]) < 0 {
+			if line[keyEnd] == '\\' {
+				keyEnd++
+			}
+			keyEnd++
+		}
+		keyEnd = min(keyEnd, len(line))
+		valueStart := keyEnd
+		for valueStart < len(line) && strings.IndexByte(" \t\f", line[valueStart]) >= 0 {
+			valueStart++
+		}
+		if valueStart < len(line) && (line[valueStart] == '=' || line[valueStart] == ':') {
+			valueStart++
+			for valueStart < len(line) && strings.IndexByte(" \t\f", line[valueStart]) >= 0 {
+				valueStart++
+			}
+		}
+		parser.addEntry(keyValueEntry{
+			key:        unescapeProperties(line[:keyEnd]),
+			value:      unescapeProperties(line[valueStart:]),
+			line:       lineNumber,
+			valueStart: offset + valueStart,
+			valueEnd:   logicalEnd,
+			start:      start,
+		})
+	}
+}
+
+// isContinuedPropertiesLine returns whether the line of properties content ends with an odd number of backslashes.
+func isContinuedPropertiesLine(line string) bool {
+	return (len(line)-len(strings.TrimRight(line, `\`)))%2 == 1
+}
+
+// unescapeProperties returns the text of properties content with its escapes and line continuations removed.
+func unescapeProperties(text string) string {
+	var builder strings.Builder
+	for index := 0; index < len(text); index++ {
+		if text[index] != '\\' {
+			builder.WriteByte(text[index])
+			continue
+		} else if index++; index == len(text) {
+			break
+		}
+		switch text[index] {
+		case '\r', '\n':
+			// A line continuation, which is removed along with the leading whitespace of the next line.
+			if text[index] == '\r' && index+1 < len(text) && text[index+1] == '\n' {
+				index++
+			}
+			for index+1 < len(text) && strings.IndexByte(" \t\f", text[index+1]) >= 0 {
+				index++
+			}
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'u':
+			codeUnit, err := strconv.ParseUint(text[index+1:min(index+5, len(text))], 16, 16)
+			if err != nil || index+5 > len(text) {
+				builder.WriteByte('u')
+				continue
+			}
+			index += 4
+			character := rune(codeUnit)
+			if utf16.IsSurrogate(character) && strings.HasPrefix(text[index+1:], `\u`) && index+7 <= len(text) {
+				if lowCodeUnit, err := strconv.ParseUint(text[index+3:index+7], 16, 16); err == nil {
+					if pair := utf16.DecodeRune(character, rune(lowCodeUnit)); pair != utf8.RuneError {
+						character = pair
+						index += 6
+					}
+				}
+			}
+			builder.WriteRune(character)
+		default:
+			builder.WriteByte(text[index])
+		}
+	}
+	return builder.String()
+}
+
+// dotenvEscaper escapes the values written in double quotes in dotenv content.
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+
+// encodeValue returns the text of the value written in place of the entry's value, retaining its quotes when the value
+// can be written in them.
+func (content *keyValueContent) encodeValue(entry keyValueEntry, value string) string {
+	switch content.contentFormat {
+	case ContentFormatDotenv:
+		if entry.quote == '\'' && !strings.Contains(value, "'") {
+			return "'" + value + "'"
+		} else if entry.quote == 0 && value != "" && !strings.ContainsAny(value, " \t\r\n#'\"\\`$") {
+			return value
+		}
+		return `"` + dotenvEscaper.Replace(value) + `"`
+	case ContentFormatIni:
+		if entry.quote != 0 && !strings.ContainsRune(value, rune(entry.quote)) {
+			return string(entry.quote) + value + string(entry.quote)
+		} else if value == strings.TrimSpace(value) && !strings.ContainsAny(value, ";#") && !strings.HasPrefix(value, `"`) && !strings.HasPrefix(value, "'") {
+			return value
+		} else if !strings.Contains(value, `"`) {
+			return `"` + value + `"`
+		}
+		return value
+	default:
+		var builder strings.Builder
+		for index, character := range value {
+			switch character {
+			case '\\':
+				builder.WriteString(`\\`)
+			case '\n':
+				builder.WriteString(`\n`)
+			case '\r':
+				builder.WriteString(`\r`)
+			case '\t':
+				builder.WriteString(`\t`)
+			case '\f':
+				builder.WriteString(`\f`)
+			case ' ':
+				// A leading space would be skipped when parsed.
+				if index == 0 {
+					builder.WriteByte('\\')
+				}
+				builder.WriteByte(' ')
+			default:
+				builder.WriteRune(character)
+			}
+		}
+		return builder.String()
+	}
+}
+
+// keyValueDocument is the entries of key/value content, which the rules' key patterns are matched against.
+type keyValueDocument struct {
+	stringDocument
+	entries []keyValueEntry
+}
+
+// findValues returns the values of the entries in the rule's sections with the rule's keys.
+func (document *keyValueDocument) findValues(rule *CompiledRule, _ int) (map[string]interface{}, *regexp.Regexp, []error) {
+	return findKeyValues(document.entries, rule.sectionPattern, rule.keyPattern), rule.pattern, nil
+}
+
+// getValues returns the values of the entries, keyed by their paths.
+func (document *keyValueDocument) getValues() map[string]interface{} {
+	return findKeyValues(document.entries, nil, nil)
+}
+
+// findKeyValues returns the values of the entries whose sections and keys match the patterns (if any), keyed by their
+// paths. There's nothing to sanitize in empty values, so they're skipped.
+func findKeyValues(entries []keyValueEntry, sectionPattern *regexp.Regexp, keyPattern *regexp.Regexp) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, entry := range entries {
+		if entry.value == "" || (sectionPattern != nil && !sectionPattern.MatchString(entry.section)) || (keyPattern != nil && !keyPattern.MatchString(entry.key)) {
+			continue
+		}
+		values[entry.path] = entry.value
+	}
+	return values
+}
+
+func (content *keyValueContent) getDocuments() []document {
+	return []document{content.document}
+}
+
+func (content *keyValueContent) setValues(replacementMaps []map[string]jsonValueReplacement) (string, error) {
+	var errs []error
+	edits := make([]jsonEdit, 0, len(replacementMaps[0]))
+	for path, replacement := range replacementMaps[0] {
+		entryIndex, isPresent := content.entryIndexes[path]
+		if !isPresent {
+			errs = append(errs, &PathWriteError{JsonPath: path, Err: errors.New("no value at the JSON path")})
+			continue
+		}
+		entry := content.document.entries[entryIndex]
+		if replacement.Delete {
+			edits = append(edits, jsonEdit{Start: entry.start, End: entry.end})
+			continue
+		}
+		value := ""
+		if err := json.Unmarshal([]byte(replacement.RawValue), &value); err != nil {
+			errs = append(errs, &PathWriteError{JsonPath: path, Err: fmt.Errorf("the replacement (%s) isn't a string", replacement.RawValue)})
+			continue
+		}
+		edits = append(edits, jsonEdit{Start: entry.valueStart, End: entry.valueEnd, Text: content.encodeValue(entry, value)})
+	}
+	return applyJsonEdits(content.content, edits), errors.Join(errs...)
+}
+
+// format returns the sanitized content as is, as key/value content isn't reformatted.
+func (content *keyValueContent) format(sanitizedContent string, outputFormat string, indent string) ([]byte, error) {
+	return formatUnstructuredContent(sanitizedContent, outputFormat)
+}
+
+// compileKeyValueRule checks that the rule can be applied to the content of the key/value format with the supported
+// actions, and compiles its patterns. The rules select the values by their key pattern and section, and their pattern.
+func compileKeyValueRule(ruleKey string, ruleInfo RuleInfo, format string, supportedActions []string) (*CompiledRule, error) {
+	if !slices.Contains(supportedActions, ruleInfo.Action) {
+		return nil, fmt.Errorf("unsupported action (%s)", ruleInfo.Action)
+	} else if ruleInfo.Action == ActionNull {
+		return nil, fmt.Errorf("action (%s) isn't supported for %s content", ruleInfo.Action, format)
+	} else if ruleInfo.Partial && isStructuralAction(ruleInfo.Action) {
+		return nil, fmt.Errorf("action (%s) cannot be partial", ruleInfo.Action)
+	} else if ruleInfo.KeyPattern == "" && ruleInfo.Pattern == "" {
+		return nil, fmt.Errorf("the rules of %s content must have a key pattern or a pattern", format)
+	} else if ruleInfo.Scope != "" {
+		return nil, fmt.Errorf("the rules of %s content can't have a scope", format)
+	}
+	compiledRule := &CompiledRule{Key: ruleKey, Info: ruleInfo}
+	var err error
+	if ruleInfo.Pattern != "" {
+		if compiledRule.pattern, err = regexp.Compile(ruleInfo.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	if ruleInfo.KeyPattern != "" {
+		if compiledRule.keyPattern, err = regexp.Compile(ruleInfo.KeyPattern); err != nil {
+			return nil, err
+		}
+	}
+	if ruleInfo.Section != "" {
+		if compiledRule.sectionPattern, err = regexp.Compile(ruleInfo.Section); err != nil {
+			return nil, err
+		}
+	}
+	return compiledRule, nil
+}
@@ -0,0 +1,220 @@
+package sanitizer
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeDotenv(t *testing.T) {
+	content := "# Database.\r\n" +
+		"DB_USER=admin\r\n" +
+		"export DB_PASSWORD=hunter2 # Rotated monthly.\r\n" +
+		"API_TOKEN='abc def'\r\n" +
+		"PRIVATE_KEY=\"line1\\nline2\r\nline3\"\r\n" +
+		"EMPTY_SECRET=\r\n" +
+		"DB_PASSWORD=\"x\\\"y\"\r\n"
+	sanitizer := newTestRuleSetSanitizer(t, ContentFormatDotenv, RuleSet{Format: ContentFormatDotenv, Rules: map[string]RuleInfo{
+		"secrets": {Action: ActionRemove, KeyPattern: `(?i)(password|token|key|secret)$`},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "dotenv", "a.env", "a_sanitized.env")
+	require.NoError(t, err)
+	// The quotes are retained if the replacement can be written in them, and the values that can't be written unquoted
+	// are double quoted.
+	assert.Equal(t, "# Database.\r\n"+
+		"DB_USER=admin\r\n"+
+		"export DB_PASSWORD=<REMOVED> # Rotated monthly.\r\n"+
+		"API_TOKEN='<REMOVED>'\r\n"+
+		"PRIVATE_KEY=\"<REMOVED>\"\r\n"+
+		"EMPTY_SECRET=\r\n"+
+		"DB_PASSWORD=\"<REMOVED>\"\r\n", result.Content)
+
+	lines := map[string]int{}
+	for _, finding := range result.Report.Findings {
+		lines[finding.JsonPath] = finding.Line
+	}
+	assert.Equal(t, map[string]int{
+		`$["DB_PASSWORD"]`:   3,
+		`$["API_TOKEN"]`:     4,
+		`$["PRIVATE_KEY"]`:   5,
+		`$["DB_PASSWORD#2"]`: 8,
+	}, lines)
+}
+
+func TestSanitizeDotenvUnquotedValues(t *testing.T) {
+	sanitizer := newTestRuleSetSanitizer(t, ContentFormatDotenv, RuleSet{Format: ContentFormatDotenv, Rules: map[string]RuleInfo{
+		"token": {Action: ActionMask, KeyPattern: `^TOKEN$`, KeepLast: 2},
+		"url":   {Action: ActionTruncate, KeyPattern: `^URL$`, MaxLength: 4},
+	}})
+	sanitizedContent, _, _, err := sanitizer.Sanitize("TOKEN=abcdef\nURL=http://x#y\n", "dotenv", "a.env", "a_sanitized.env")
+	require.NoError(t, err)
+	assert.Equal(t, "TOKEN=****ef\nURL=http\n", sanitizedContent)
+}
+
+func TestSanitizeIni(t *testing.T) {
+	content := `; Global settings.
+password = top
+[database]
+host = db.local
+password = "hunter2" ; Quoted.
+user: admin
+[database_replica]
+password=s3cret # Inline comment.
+token = abc
+[cache]
+password = other
+`
+	sanitizer := newTestRuleSetSanitizer(t, ContentFormatIni, RuleSet{Format: ContentFormatIni, Rules: map[string]RuleInfo{
+		"database_passwords": {Action: ActionRemove, Section: `^database`, KeyPattern: `^password$`},
+		"global_password":    {Action: ActionMask, Section: `^$`, KeyPattern: `^password$`},
+		"tokens":             {Action: ActionDelete, KeyPattern: `^token$`},
+	}})
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "ini", "a.ini", "a_sanitized.ini")
+	require.NoError(t, err)
+	assert.Equal(t, `; Global settings.
+password = ***
+[database]
+host = db.local
+password = "<REMOVED>" ; Quoted.
+user: admin
+[database_replica]
+password=<REMOVED> # Inline comment.
+[cache]
+password = other
+`, sanitizedContent)
+}
+
+func TestSanitizeIniKeyNamedAfterSection(t *testing.T) {
+	sanitizer := newTestRuleSetSanitizer(t, ContentFormatIni, RuleSet{Format: ContentFormatIni, Rules: map[string]RuleInfo{
+		"password": {Action: ActionRemove, KeyPattern: `^password$`},
+		"token":    {Action: ActionRemove, KeyPattern: `^token$`},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), "password = rootpw\n[password]\ntoken = x\n", "ini", "a.ini", "a_sanitized.ini")
+	require.NoError(t, err)
+	// The key before the first section doesn't cover the values of the section with its name.
+	assert.Equal(t, "password = <REMOVED>\n[password]\ntoken = <REMOVED>\n", result.Content)
+	jsonPaths := []string{}
+	for _, finding := range result.Report.Findings {
+		jsonPaths = append(jsonPaths, finding.JsonPath)
+	}
+	assert.ElementsMatch(t, []string{`$[""]["password"]`, `$["password"]["token"]`}, jsonPaths)
+}
+
+func TestSanitizeProperties(t *testing.T) {
+	content := `# Spring settings.
+spring.datasource.url=jdbc:postgresql://db/app
+spring.datasource.password = hunter2
+app.api-key: abc\
+    def
+app.secret   été
+app.token=
+! Comment.
+app.description=keep
+`
+	sanitizer := newTestRuleSetSanitizer(t, ContentFormatProperties, RuleSet{Format: ContentFormatProperties, Rules: map[string]RuleInfo{
+		"secrets": {Action: ActionRemove, KeyPattern: `(password|api-key|secret|token)$`},
+		"url":     {Action: ActionMask, KeyPattern: `\.url$`, KeepLast: 3},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "properties", "a.properties", "a_sanitized.properties")
+	require.NoError(t, err)
+	assert.Equal(t, `# Spring settings.
+spring.datasource.url=*********************app
+spring.datasource.password = <REMOVED>
+app.api-key: <REMOVED>
+app.secret   <REMOVED>
+app.token=
+! Comment.
+app.description=keep
+`, result.Content)
+
+	values := map[string]string{}
+	for _, finding := range result.Report.Findings {
+		values[finding.JsonPath] = finding.RuleKey
+	}
+	assert.Len(t, values, 4)
+	assert.Contains(t, values, `$["app.secret"]`)
+}
+
+func TestParsePropertiesEscapes(t *testing.T) {
+	content, err := parseKeyValueContent("a\\=b=c\\td\\u0041\\uD83D\\uDE00\r\n  continued = \\\r\n    value\\\\\n", ContentFormatProperties)
+	require.NoError(t, err)
+	require.Len(t, content.document.entries, 2)
+	assert.Equal(t, "a=b", content.document.entries[0].key)
+	assert.Equal(t, "c\tdA😀", content.document.entries[0].value)
+	assert.Equal(t, "continued", content.document.entries[1].key)
+	assert.Equal(t, `value\`, content.document.entries[1].value)
+
+	sanitizedContent, err := content.setValues([]map[string]jsonValueReplacement{{`$["continued"]`: {RawValue: `" x\n"`}}})
+	require.NoError(t, err)
+	assert.Equal(t, "a\\=b=c\\td\\u0041\\uD83D\\uDE00\r\n  continued = \\ x\\n\n", sanitizedContent)
+}
+
+func TestSanitizeWithKeyValueRuleFiles(t *testing.T) {
+	for fileExtension, content := range map[string]string{
+		"env":        "DB_HOST=db\nDB_PASSWORD=hunter2\nDATABASE_URL=postgres://app:hunter2@db/app\n",
+		"ini":        "[client]\nhost = db\npassword = hunter2\nurl = postgres://app:hunter2@db/app\n",
+		"properties": "spring.datasource.host=db\nspring.datasource.password=hunter2\nspring.datasource.url=jdbc:postgresql://app:hunter2@db/app\n",
+	} {
+		sanitizer := newTestRuleSetSanitizer(t, fileExtension, loadTestRuleSet(t, fileExtension))
+		result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, fileExtension, "a", "a_sanitized")
+		require.NoError(t, err, fileExtension)
+		assert.NotContains(t, result.Content, "hunter2", fileExtension)
+		assert.Contains(t, result.Content, strings.SplitAfter(content, "db\n")[0], fileExtension)
+		// The password and the password in the URL are the same secret, so they get the same replacement.
+		secretReplacements := regexp.MustCompile("secret_[0-9a-f]{64}").FindAllString(result.Content, -1)
+		require.Len(t, secretReplacements, 2, fileExtension)
+		assert.Equal(t, secretReplacements[0], secretReplacements[1], fileExtension)
+	}
+}
+
+func TestDesanitizeKeyValueContent(t *testing.T) {
+	for format, content := range map[string]string{
+		ContentFormatDotenv:     "# Comment.\nTOKEN='abc'\nURL=https://x?key=def\n",
+		ContentFormatIni:        "[auth]\ntoken = abc ; Comment.\nurl = https://x?key=def\n",
+		ContentFormatProperties: "token=abc\nurl=https://x?key=def\n",
+	} {
+		sanitizer := newTestRuleSetSanitizer(t, format, RuleSet{Format: format, Rules: map[string]RuleInfo{
+			"token": {Action: ActionContextualReplacement, KeyPattern: `(?i)^token$`},
+			"key":   {Action: ActionContextualReplacement, Pattern: `key=(\w+)`, Partial: true},
+		}})
+		vault := NewVault()
+		sanitizer.SetVault(vault)
+		sanitizedContent, _, _, err := sanitizer.Sanitize(content, format, "a", "a_sanitized")
+		require.NoError(t, err, format)
+		assert.NotContains(t, sanitizedContent, "abc", format)
+		assert.NotContains(t, sanitizedContent, "def", format)
+		desanitizedContent, _, _, err := sanitizer.Desanitize(sanitizedContent, format, "a_sanitized", "a_desanitized", vault)
+		require.NoError(t, err, format)
+		assert.Equal(t, content, desanitizedContent, format)
+	}
+}
+
+func TestSanitizeKeyValueContentErrors(t *testing.T) {
+	for format, content := range map[string]string{
+		ContentFormatDotenv: "A=1\nB='unterminated\n",
+		ContentFormatIni:    "a=1\n[section\n",
+	} {
+		sanitizer := newTestRuleSetSanitizer(t, format, RuleSet{Format: format, Rules: map[string]RuleInfo{"a": {Action: ActionRemove, KeyPattern: "a"}}})
+		_, _, _, err := sanitizer.Sanitize(content, format, "a", "a_sanitized")
+		var invalidInputErr *InvalidInputError
+		assert.ErrorAs(t, err, &invalidInputErr, format)
+		assert.ErrorContains(t, err, "line 2", format)
+	}
+
+	config := newTestSanitizer(nil).config
+	for ruleInfo, expectedErr := range map[RuleInfo]string{
+		{Action: ActionRemove}:                                "must have a key pattern or a pattern",
+		{Action: ActionNull, KeyPattern: "x"}:                 "action (null) isn't supported for dotenv content",
+		{Action: ActionRemove, KeyPattern: "x", Scope: "$"}:   "can't have a scope",
+		{Action: ActionRemove, KeyPattern: "("}:               "missing closing )",
+		{Action: ActionRemove, KeyPattern: "x", Section: "x"}: "only the rules of ini content can have a section",
+	} {
+		_, err := CompileRuleSet(RuleSet{Format: ContentFormatDotenv, Rules: map[string]RuleInfo{"rule": ruleInfo}}, config)
+		assert.ErrorContains(t, err, expectedErr)
+	}
+	_, err := CompileRuleSet(RuleSet{Rules: map[string]RuleInfo{"x": {Action: ActionRemove, KeyPattern: "x"}}}, config)
+	assert.ErrorContains(t, err, "only the rules of dotenv, ini and properties content can have a key pattern")
+}
@@ -0,0 +1,44 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"context"
+	"log/slog"
+)
+
+// discardLogger is the logger of the sanitizers without a logger, which discards the diagnostics.
+var discardLogger = slog.New(discardLogHandler{})
+
+// discardLogHandler is a slog.Handler that discards the records without formatting them.
+type discardLogHandler struct{}
+
+func (handler discardLogHandler) Enabled(context.Context, slog.Level) bool {
+	return false
+}
+
+func (handler discardLogHandler) Handle(context.Context, slog.Record) error {
+	return nil
+}
+
+func (handler discardLogHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return handler
+}
+
+func (handler discardLogHandler) WithGroup(string) slog.Handler {
+	return handler
+}
+
+// getLogger returns the logger set with SetLogger, or the discardLogger if it isn't set.
+func (sanitizer *Sanitizer) getLogger() *slog.Logger {
+	sanitizer.mutex.RLock()
+	defer sanitizer.mutex.RUnlock()
+	if sanitizer.logger == nil {
+		return discardLogger
+	}
+	return sanitizer.logger
+}
+
+// logError logs the error, which doesn't include the values of the content.
+func (sanitizer *Sanitizer) logError(err error) {
+	sanitizer.getLogger().Error("Sanitization error.", "error", err)
+}
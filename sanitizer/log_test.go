@@ -0,0 +1,32 @@
+package sanitizer
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLogger(t *testing.T) {
+	sanitizer := newTestSanitizer(map[string]RuleInfo{`$.password`: {Action: ActionContextualReplacement}})
+	content := `{"password": "hunter2"}`
+	var logs bytes.Buffer
+	sanitizer.SetLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	_, _, _, err := sanitizer.Sanitize(content, "json", "a.json", "a_sanitized.json")
+	require.NoError(t, err)
+	_, _, _, err = sanitizer.Sanitize(`{"password": hunter2}`, "json", "b.json", "b_sanitized.json")
+	require.Error(t, err)
+	assert.Contains(t, logs.String(), `msg="Applying the rule." rule=$.password`)
+	assert.Contains(t, logs.String(), "level=ERROR")
+	// The diagnostics don't include the values of the content.
+	assert.NotContains(t, logs.String(), "hunter2")
+
+	// The diagnostics are discarded without a logger.
+	logs.Reset()
+	sanitizer.SetLogger(nil)
+	_, _, _, err = sanitizer.Sanitize(content, "json", "a.json", "a_sanitized.json")
+	require.NoError(t, err)
+	assert.Empty(t, logs.String())
+}
@@ -0,0 +1,113 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ndjsonLine is a line of NDJSON content with a JSON value.
+type ndjsonLine struct {
+	// start and end are the offsets of the JSON value's text in the content, excluding the line ending.
+	start  int
+	end    int
+	number int
+}
+
+// ndjsonContent is newline delimited JSON content, with a document for the JSON value of each line.
+//
+// The lines are sanitized independently with the same replacement context, so the same secret on different lines gets
+// the same replacement. Only the lines with replacements are rewritten, and the line endings and blank lines are
+// preserved.
+type ndjsonContent struct {
+	content   string
+	lines     []ndjsonLine
+	documents []document
+}
+
+// ndjsonDocument is the JSON value of a line of NDJSON content, whose values are all on that line.
+type ndjsonDocument struct {
+	*jsonDocument
+	line int
+}
+
+func (document *ndjsonDocument) getLine(string) int {
+	return document.line
+}
+
+// parseNdjsonContent parses the JSON value of each line of the NDJSON content. The blank lines are skipped.
+func parseNdjsonContent(content string) (*ndjsonContent, error) {
+	ndjsonContent := &ndjsonContent{content: content, lines: getNdjsonLines(content)}
+	for _, line := range ndjsonContent.lines {
+		document, err := parseJsonDocument(content[line.start:line.end])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+		ndjsonContent.documents = append(ndjsonContent.documents, &ndjsonDocument{jsonDocument: document, line: line.number})
+	}
+	return ndjsonContent, nil
+}
+
+// getNdjsonLines returns the lines of the NDJSON content that aren't blank. A byte order mark isn't part of the first
+// line's value.
+func getNdjsonLines(content string) []ndjsonLine {
+	var lines []ndjsonLine
+	offset := len(content) - len(strings.TrimPrefix(content, "\ufeff"))
+	for number := 1; offset < len(content); number++ {
+		end := len(content)
+		nextOffset := end
+		if index := strings.IndexByte(content[offset:], '\n'); index >= 0 {
+			end, nextOffset = offset+index, offset+index+1
+		}
+		// The carriage return of the line ending isn't part of the value.
+		end = offset + len(strings.TrimSuffix(content[offset:end], "\r"))
+		if strings.TrimSpace(content[offset:end]) != "" {
+			lines = append(lines, ndjsonLine{start: offset, end: end, number: number})
+		}
+		offset = nextOffset
+	}
+	return lines
+}
+
+func (content *ndjsonContent) getDocuments() []document {
+	return content.documents
+}
+
+// setValues writes the replacements of each line's document in the line. The errors of the replacements are prefixed
+// with their line, as the same JSON path can be on multiple lines.
+func (content *ndjsonContent) setValues(replacementMaps []map[string]jsonValueReplacement) (string, error) {
+	var errs []error
+	edits := make([]jsonEdit, 0)
+	for index, replacementMap := range replacementMaps {
+		if len(replacementMap) == 0 {
+			continue
+		}
+		line := content.lines[index]
+		sanitizedLine, err := setJsonValues(content.content[line.start:line.end], replacementMap)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line.number, err))
+		}
+		edits = append(edits, jsonEdit{Start: line.start, End: line.end, Text: sanitizedLine})
+	}
+	return applyJsonEdits(content.content, edits), errors.Join(errs...)
+}
+
+// format minifies each line of the sanitized content for OutputFormatMinify, and returns it as is otherwise, as the
+// values can't be indented without splitting them across lines.
+func (content *ndjsonContent) format(sanitizedContent string, outputFormat string, indent string) ([]byte, error) {
+	if outputFormat != OutputFormatMinify {
+		return formatUnstructuredContent(sanitizedContent, outputFormat)
+	}
+	edits := make([]jsonEdit, 0)
+	for _, line := range getNdjsonLines(sanitizedContent) {
+		var out bytes.Buffer
+		if err := json.Compact(&out, []byte(sanitizedContent[line.start:line.end])); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+		edits = append(edits, jsonEdit{Start: line.start, End: line.end, Text: out.String()})
+	}
+	return []byte(applyJsonEdits(sanitizedContent, edits)), nil
+}
@@ -0,0 +1,95 @@
+package sanitizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeNdjson(t *testing.T) {
+	content := "{\"level\": \"info\", \"user\": {\"password\": \"hunter2\"}}\r\n" +
+		"\r\n" +
+		"{\"level\": \"debug\", \"msg\": \"ok\"}\r\n" +
+		"{\"user\": {\"password\": \"hunter2\"}, \"token\": \"abc\"}\r\n" +
+		"[1, 2]"
+	sanitizer := newTestRuleSetSanitizer(t, "ndjson", RuleSet{Format: ContentFormatNdjson, Rules: map[string]RuleInfo{
+		`$..["password"]`: {Action: ActionContextualReplacement},
+		`$.token`:         {Action: ActionRemove},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "ndjson", "a.ndjson", "a_sanitized.ndjson")
+	require.NoError(t, err)
+
+	lines := map[int][]string{}
+	replacements := map[int]string{}
+	for _, finding := range result.Report.Findings {
+		lines[finding.Line] = append(lines[finding.Line], finding.JsonPath)
+		if finding.JsonPath == `$["user"]["password"]` {
+			replacements[finding.Line] = finding.Replacement
+		}
+	}
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{`$["user"]["password"]`}, lines[1])
+	assert.ElementsMatch(t, []string{`$["user"]["password"]`, `$["token"]`}, lines[4])
+	// The lines share the replacement context, so the same password gets the same replacement on each line.
+	assert.Equal(t, replacements[1], replacements[4])
+
+	// Only the values are rewritten, so the whitespace, the blank line and the line endings are preserved.
+	assert.Equal(t, "{\"level\": \"info\", \"user\": {\"password\": \""+replacements[1]+"\"}}\r\n"+
+		"\r\n"+
+		"{\"level\": \"debug\", \"msg\": \"ok\"}\r\n"+
+		"{\"user\": {\"password\": \""+replacements[1]+"\"}, \"token\": \"<REMOVED>\"}\r\n"+
+		"[1, 2]", result.Content)
+}
+
+func TestSanitizeNdjsonOutputFormats(t *testing.T) {
+	content := "\ufeff{\"a\": \"x\",  \"b\": 1}\n\n{ \"a\": \"y\" }\n"
+	sanitizer := newTestRuleSetSanitizer(t, "ndjson", RuleSet{Format: ContentFormatNdjson, Rules: map[string]RuleInfo{"$.a": {Action: ActionRemove}}})
+	for outputFormat, expectedContent := range map[string]string{
+		OutputFormatMinify: "\ufeff{\"a\":\"<REMOVED>\",\"b\":1}\n\n{\"a\":\"<REMOVED>\"}\n",
+		// The values aren't indented, as they must stay on a single line.
+		OutputFormatPretty: "\ufeff{\"a\": \"<REMOVED>\",  \"b\": 1}\n\n{ \"a\": \"<REMOVED>\" }\n",
+	} {
+		sanitizer.config.OutputFormat = outputFormat
+		sanitizedContent, _, _, err := sanitizer.Sanitize(content, "ndjson", "a.ndjson", "a_sanitized.ndjson")
+		require.NoError(t, err, outputFormat)
+		assert.Equal(t, expectedContent, sanitizedContent, outputFormat)
+	}
+}
+
+func TestDesanitizeNdjson(t *testing.T) {
+	content := "{\"token\": \"abc\", \"url\": \"https://x?key=def\"}\n{\"token\": \"ghi\"}\n"
+	sanitizer := newTestRuleSetSanitizer(t, "ndjson", RuleSet{Format: ContentFormatNdjson, Rules: map[string]RuleInfo{
+		"$.token": {Action: ActionContextualReplacement},
+		"key":     {Action: ActionContextualReplacement, Pattern: `key=(\w+)`, Partial: true},
+	}})
+	vault := NewVault()
+	sanitizer.SetVault(vault)
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "ndjson", "a.ndjson", "a_sanitized.ndjson")
+	require.NoError(t, err)
+	for _, secret := range []string{"abc", "def", "ghi"} {
+		assert.NotContains(t, sanitizedContent, secret)
+	}
+	desanitizedContent, _, _, err := sanitizer.Desanitize(sanitizedContent, "ndjson", "a_sanitized.ndjson", "a_desanitized.ndjson", vault)
+	require.NoError(t, err)
+	assert.Equal(t, content, desanitizedContent)
+}
+
+func TestSanitizeWithNdjsonRuleFiles(t *testing.T) {
+	content := "{\"msg\": \"login\", \"password\": \"hunter2\"}\n{\"msg\": \"connect postgres://app:hunter2@db/app\"}\n"
+	for _, fileExtension := range []string{"ndjson", "jsonl"} {
+		sanitizer := newTestRuleSetSanitizer(t, fileExtension, loadTestRuleSet(t, fileExtension))
+		sanitizedContent, _, _, err := sanitizer.Sanitize(content, fileExtension, "a", "a_sanitized")
+		require.NoError(t, err, fileExtension)
+		assert.NotContains(t, sanitizedContent, "hunter2", fileExtension)
+		assert.Contains(t, sanitizedContent, "postgres://app:", fileExtension)
+	}
+}
+
+func TestSanitizeNdjsonErrors(t *testing.T) {
+	sanitizer := newTestRuleSetSanitizer(t, "ndjson", RuleSet{Format: ContentFormatNdjson, Rules: map[string]RuleInfo{"$.a": {Action: ActionRemove}}})
+	_, _, _, err := sanitizer.Sanitize("{\"a\": 1}\n\n{\"a\": \n", "ndjson", "a.ndjson", "a_sanitized.ndjson")
+	var invalidInputErr *InvalidInputError
+	assert.ErrorAs(t, err, &invalidInputErr)
+	assert.ErrorContains(t, err, "line 3: ")
+}
@@ -0,0 +1,85 @@
+package sanitizer
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// findPatternMatches returns the string values (keyed by their JSON path) within the provided values that match the pattern.
+// Objects and arrays are searched recursively.
+func findPatternMatches(values map[string]interface{}, pattern *regexp.Regexp) map[string]interface{} {
+	matches := map[string]interface{}{}
+	for jsonPath, value := range values {
+		collectPatternMatches(jsonPath, value, pattern, matches)
+	}
+	return matches
+}
+
+func collectPatternMatches(jsonPath string, value interface{}, pattern *regexp.Regexp, matches map[string]interface{}) {
+	switch typedValue := value.(type) {
+	case string:
+		if pattern.MatchString(typedValue) {
+			matches[jsonPath] = typedValue
+		}
+	case map[string]interface{}:
+		for key, childValue := range typedValue {
+			collectPatternMatches(appendJsonPathSegment(jsonPath, key), childValue, pattern, matches)
+		}
+	case []interface{}:
+		for index, childValue := range typedValue {
+			collectPatternMatches(appendJsonPathSegment(jsonPath, strconv.Itoa(index)), childValue, pattern, matches)
+		}
+	}
+}
+
+// appendJsonPathSegment appends a segment to a JSON path in the notation returned by jsonpath.GetWithPaths.
+func appendJsonPathSegment(jsonPath string, segment string) string {
+	return jsonPath + "[" + strconv.Quote(segment) + "]"
+}
+
+// replacePatternMatches replaces the substrings of the value matched by the pattern with their replacements.
+// Only the spans returned by getPatternSpans are replaced, and the rest of the matches is retained.
+func replacePatternMatches(value string, pattern *regexp.Regexp, getReplacement func(string) (string, error)) (string, error) {
+	var builder strings.Builder
+	lastIndex := 0
+	for _, span := range getPatternSpans(value, pattern) {
+		replacement, err := getReplacement(value[span[0]:span[1]])
+		if err != nil {
+			return value, err
+		}
+		builder.WriteString(value[lastIndex:span[0]])
+		builder.WriteString(replacement)
+		lastIndex = span[1]
+	}
+	builder.WriteString(value[lastIndex:])
+	return builder.String(), nil
+}
+
+// getPatternSpans returns the [start, end) offsets of the substrings of the value to replace for the pattern's matches:
+// the named groups if the pattern has any, or else the capture groups if it has any, or else the whole matches.
+// The groups that didn't participate in a match, are empty or are nested within an earlier group are skipped.
+func getPatternSpans(value string, pattern *regexp.Regexp) [][2]int {
+	groupNames := pattern.SubexpNames()
+	hasNamedGroups := slices.ContainsFunc(groupNames, func(groupName string) bool {
+		return groupName != ""
+	})
+	var spans [][2]int
+	lastIndex := 0
+	for _, matchIndexes := range pattern.FindAllStringSubmatchIndex(value, -1) {
+		// The first pair of indexes is the whole match and the rest are the capture groups.
+		for group := range len(matchIndexes) / 2 {
+			if (group == 0) != (len(groupNames) == 1) || (hasNamedGroups && groupNames[group] == "") {
+				continue
+			}
+			start, end := matchIndexes[2*group], matchIndexes[2*group+1]
+			if start < lastIndex || start == end {
+				continue
+			}
+			spans = append(spans, [2]int{start, end})
+			lastIndex = end
+		}
+	}
+	return spans
+}
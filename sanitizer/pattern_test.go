@@ -0,0 +1,38 @@
+package sanitizer
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplacePatternMatches(t *testing.T) {
+	toUpper := func(match string) (string, error) {
+		return strings.ToUpper(match), nil
+	}
+	testCases := []struct {
+		pattern  string
+		value    string
+		expected string
+	}{
+		{`token=\w+`, "a?token=abc&token=def", "a?TOKEN=ABC&TOKEN=DEF"},
+		{`[?&]token=([^&]+)`, "https://x/api?token=abc&page=2", "https://x/api?token=ABC&page=2"},
+		{`(\w+)@(\w+)\.com`, "mail ab@cd.com now", "mail AB@CD.com now"},
+		{`((\w+)-\w+)`, "ab-cd", "AB-CD"},
+		{`a(x)?b`, "ab axb", "ab aXb"},
+		{`nomatch`, "value", "value"},
+	}
+	for _, testCase := range testCases {
+		actual, err := replacePatternMatches(testCase.value, regexp.MustCompile(testCase.pattern), toUpper)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.expected, actual, testCase.pattern)
+	}
+
+	_, err := replacePatternMatches("abc", regexp.MustCompile("b"), func(string) (string, error) {
+		return "", errors.New("failed")
+	})
+	assert.Error(t, err)
+}
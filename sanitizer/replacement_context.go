@@ -0,0 +1,89 @@
+package sanitizer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// ReplacementContext holds the contextual replacements of the files sanitized with it, so that identical secrets in
+// them get the same replacement. Files sanitized with different contexts only get the same replacements if they share
+// a secret key set with Sanitizer.SetSecretKey. It's safe for concurrent use.
+type ReplacementContext struct {
+	mutex              sync.RWMutex
+	secretReplacements map[string]string
+	// secretKey is the key used to derive the replacements (as HMAC-SHA256 of the secrets), or nil if they aren't keyed.
+	secretKey []byte
+	// err is the error creating the context, returned when sanitizing with it.
+	err error
+	// isDryRun is set for the contexts of dry runs, whose replacements aren't recorded in the vault.
+	isDryRun bool
+}
+
+// NewReplacementContext creates a replacement context to share across the files sanitized with it.
+// It uses the secret key set with SetSecretKey. Otherwise, if the config's SecretKeyMode is session, a random key
+// is generated for the context.
+func (sanitizer *Sanitizer) NewReplacementContext() *ReplacementContext {
+	replacementContext := &ReplacementContext{
+		secretReplacements: map[string]string{},
+		secretKey:          sanitizer.getSecretKey(),
+	}
+	if replacementContext.secretKey == nil && sanitizer.config.SecretKeyMode == SecretKeyModeSession {
+		replacementContext.secretKey = make([]byte, sessionKeySize)
+		if _, err := rand.Read(replacementContext.secretKey); err != nil {
+			replacementContext.err = fmt.Errorf("error generating the session key: %w", err)
+		}
+	}
+	return replacementContext
+}
+
+// newDryRunContext returns a context with the same secret key and error, for a dry run that doesn't change this context.
+func (replacementContext *ReplacementContext) newDryRunContext() *ReplacementContext {
+	return &ReplacementContext{
+		secretReplacements: map[string]string{},
+		secretKey:          replacementContext.secretKey,
+		err:                replacementContext.err,
+		isDryRun:           true,
+	}
+}
+
+// Len returns the number of secrets replaced in the context.
+func (replacementContext *ReplacementContext) Len() int {
+	replacementContext.mutex.RLock()
+	defer replacementContext.mutex.RUnlock()
+	return len(replacementContext.secretReplacements)
+}
+
+// getSecretReplacement returns the replacement of the secret, deriving it with the prefix if it hasn't been replaced in
+// the context before. It also returns whether the replacement was already present.
+func (replacementContext *ReplacementContext) getSecretReplacement(secret string, prefix string) (string, bool) {
+	replacementContext.mutex.RLock()
+	secretReplacement, isSecretReplacementPresent := replacementContext.secretReplacements[secret]
+	replacementContext.mutex.RUnlock()
+	if isSecretReplacementPresent {
+		return secretReplacement, true
+	}
+
+	secretReplacement = prefix + "_" + replacementContext.digest(secret)
+
+	replacementContext.mutex.Lock()
+	defer replacementContext.mutex.Unlock()
+	replacementContext.secretReplacements[secret] = secretReplacement
+	return secretReplacement, false
+}
+
+// digest returns the hex HMAC-SHA256 of the value with the context's secret key, or its SHA-256 if there's no key.
+func (replacementContext *ReplacementContext) digest(value string) string {
+	var hasher hash.Hash
+	if replacementContext.secretKey != nil {
+		hasher = hmac.New(sha256.New, replacementContext.secretKey)
+	} else {
+		hasher = sha256.New()
+	}
+	hasher.Write([]byte(value))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
@@ -0,0 +1,269 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Supported report formats.
+const (
+	ReportFormatJson  = "json"
+	ReportFormatSarif = "sarif"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "sanitizer"
+	toolUri      = "https://github.com/padaiyal/sanitizer"
+)
+
+// Finding is a value sanitized by a rule.
+type Finding struct {
+	FileName        string `json:"fileName"`
+	RuleKey         string `json:"ruleKey"`
+	RuleDescription string `json:"ruleDescription"`
+	Action          string `json:"action"`
+	// JsonPath is the normalized JSON path of the sanitized value (Ex: $["log"]["entries"]["0"]), or its location path
+	// for ContentFormatXml (Ex: /settings[1]/servers[1]/server[1]/password[1]/text()[1]), or the line and the columns of
+	// the sanitized substring for ContentFormatText (Ex: 12:5-40).
+	JsonPath string `json:"jsonPath"`
+	// Replacement is the value written in place of the original value. It's empty for the delete action, and the JSON
+	// text of the replacement for non string replacements (Ex: null).
+	Replacement string `json:"replacement"`
+	// ValueFingerprint is the HMAC-SHA256 of the original value with the replacement context's secret key. It identifies
+	// the value across findings without revealing it. It's empty if the context has no secret key, since a plain digest
+	// of a low entropy value (Ex: a password) could be reversed by brute force.
+	ValueFingerprint string `json:"valueFingerprint,omitempty"`
+	// Line is the line of the sanitized value in the file, for the formats that record it (Ex: ContentFormatYaml). In a
+	// multi-document YAML stream, it distinguishes the values with the same JSON path in different documents.
+	Line int `json:"line,omitempty"`
+}
+
+// UnmatchedRule is a rule that didn't match any value in a file.
+type UnmatchedRule struct {
+	FileName string `json:"fileName"`
+	RuleKey  string `json:"ruleKey"`
+}
+
+// RuleConflict is a value matched by multiple rules with different replacements. Only the replacement of the rule that
+// takes precedence (see RuleInfo.Priority) is applied.
+type RuleConflict struct {
+	FileName          string `json:"fileName"`
+	JsonPath          string `json:"jsonPath"`
+	RuleKey           string `json:"ruleKey"`
+	Action            string `json:"action"`
+	OverriddenRuleKey string `json:"overriddenRuleKey"`
+	OverriddenAction  string `json:"overriddenAction"`
+	// Line is the line of the value in the file, for the formats that record it (see Finding.Line).
+	Line int `json:"line,omitempty"`
+}
+
+// Report lists the findings of the sanitized files, the rules that didn't match any value in them and the conflicts
+// between the rules.
+type Report struct {
+	Findings       []Finding       `json:"findings"`
+	UnmatchedRules []UnmatchedRule `json:"unmatchedRules"`
+	Conflicts      []RuleConflict  `json:"conflicts"`
+}
+
+// newFinding returns the finding of the replacement of the value at the JSON path by the rule.
+func newFinding(replacementContext *ReplacementContext, fileName string, jsonPath string, valueText string, ruleKey string, ruleInfo RuleInfo, replacement jsonValueReplacement) Finding {
+	replacementValue := ""
+	if !replacement.Delete && json.Unmarshal([]byte(replacement.RawValue), &replacementValue) != nil {
+		replacementValue = replacement.RawValue
+	}
+	finding := Finding{
+		FileName:        fileName,
+		RuleKey:         ruleKey,
+		RuleDescription: ruleInfo.Description,
+		Action:          ruleInfo.Action,
+		JsonPath:        jsonPath,
+		Replacement:     replacementValue,
+	}
+	if replacementContext.secretKey != nil {
+		finding.ValueFingerprint = replacementContext.digest(valueText)
+	}
+	return finding
+}
+
+// Merge appends the findings, unmatched rules and conflicts of the other report, and sorts them by file name, line, JSON
+// path and rule key.
+func (report *Report) Merge(other Report) {
+	report.Findings = append(report.Findings, other.Findings...)
+	slices.SortStableFunc(report.Findings, func(a Finding, b Finding) int {
+		return cmp.Or(cmp.Compare(a.FileName, b.FileName), cmp.Compare(a.Line, b.Line), cmp.Compare(a.JsonPath, b.JsonPath), cmp.Compare(a.RuleKey, b.RuleKey))
+	})
+	report.UnmatchedRules = append(report.UnmatchedRules, other.UnmatchedRules...)
+	slices.SortStableFunc(report.UnmatchedRules, func(a UnmatchedRule, b UnmatchedRule) int {
+		return cmp.Or(cmp.Compare(a.FileName, b.FileName), cmp.Compare(a.RuleKey, b.RuleKey))
+	})
+	report.Conflicts = append(report.Conflicts, other.Conflicts...)
+	slices.SortStableFunc(report.Conflicts, func(a RuleConflict, b RuleConflict) int {
+		return cmp.Or(cmp.Compare(a.FileName, b.FileName), cmp.Compare(a.Line, b.Line), cmp.Compare(a.JsonPath, b.JsonPath), cmp.Compare(a.OverriddenRuleKey, b.OverriddenRuleKey))
+	})
+}
+
+// Export returns the report in the specified format (ReportFormatJson or ReportFormatSarif).
+func (report Report) Export(reportFormat string) ([]byte, error) {
+	switch reportFormat {
+	case ReportFormatJson:
+		return report.ToJson()
+	case ReportFormatSarif:
+		return report.ToSarif()
+	}
+	return nil, fmt.Errorf("unsupported report format (%s), supported report formats are %s", reportFormat, strings.Join([]string{ReportFormatJson, ReportFormatSarif}, ","))
+}
+
+// ToJson returns the report as indented JSON.
+func (report Report) ToJson() ([]byte, error) {
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	if report.UnmatchedRules == nil {
+		report.UnmatchedRules = []UnmatchedRule{}
+	}
+	if report.Conflicts == nil {
+		report.Conflicts = []RuleConflict{}
+	}
+	return json.MarshalIndent(report, "", "  ")
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful            bool                `json:"executionSuccessful"`
+	ToolConfigurationNotifications []sarifNotification `json:"toolConfigurationNotifications"`
+}
+
+type sarifNotification struct {
+	Descriptor sarifDescriptor `json:"descriptor"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+}
+
+type sarifDescriptor struct {
+	Id string `json:"id"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Properties          map[string]string `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// ToSarif returns the report as a SARIF 2.1.0 log, with a result for each finding.
+// The JSON path of a finding is its logical location (along with its line, if it's recorded), and its value fingerprint
+// is a partial fingerprint.
+// The unmatched rules and conflicts are reported as tool configuration notifications.
+func (report Report) ToSarif() ([]byte, error) {
+	rules := make([]sarifRule, 0)
+	results := make([]sarifResult, 0, len(report.Findings))
+	for _, finding := range report.Findings {
+		if !slices.ContainsFunc(rules, func(rule sarifRule) bool { return rule.Id == finding.RuleKey }) {
+			rules = append(rules, sarifRule{Id: finding.RuleKey, ShortDescription: sarifMessage{Text: finding.RuleDescription}})
+		}
+		physicalLocation := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{Uri: finding.FileName}}
+		if finding.Line > 0 {
+			physicalLocation.Region = &sarifRegion{StartLine: finding.Line}
+		}
+		var partialFingerprints map[string]string
+		if finding.ValueFingerprint != "" {
+			partialFingerprints = map[string]string{"valueFingerprint/v1": finding.ValueFingerprint}
+		}
+		results = append(results, sarifResult{
+			RuleId:  finding.RuleKey,
+			Level:   "note",
+			Message: sarifMessage{Text: "Sensitive value at " + finding.JsonPath + " sanitized with the " + finding.Action + " action."},
+			Locations: []sarifLocation{{
+				PhysicalLocation: physicalLocation,
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: finding.JsonPath, Kind: "member"}},
+			}},
+			PartialFingerprints: partialFingerprints,
+			Properties:          map[string]string{"action": finding.Action, "replacement": finding.Replacement},
+		})
+	}
+	notifications := make([]sarifNotification, 0, len(report.UnmatchedRules)+len(report.Conflicts))
+	for _, unmatchedRule := range report.UnmatchedRules {
+		notifications = append(notifications, sarifNotification{
+			Descriptor: sarifDescriptor{Id: unmatchedRule.RuleKey},
+			Level:      "note",
+			Message:    sarifMessage{Text: "Rule " + unmatchedRule.RuleKey + " didn't match any value in " + unmatchedRule.FileName + "."},
+		})
+	}
+	for _, conflict := range report.Conflicts {
+		notifications = append(notifications, sarifNotification{
+			Descriptor: sarifDescriptor{Id: conflict.OverriddenRuleKey},
+			Level:      "warning",
+			Message:    sarifMessage{Text: "Rule " + conflict.OverriddenRuleKey + " (" + conflict.OverriddenAction + ") is overridden by rule " + conflict.RuleKey + " (" + conflict.Action + ") at " + conflict.JsonPath + " in " + conflict.FileName + "."},
+		})
+	}
+	return json.MarshalIndent(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:        sarifTool{Driver: sarifDriver{Name: toolName, InformationUri: toolUri, Rules: rules}},
+			Invocations: []sarifInvocation{{ExecutionSuccessful: true, ToolConfigurationNotifications: notifications}},
+			Results:     results,
+		}},
+	}, "", "  ")
+}
@@ -0,0 +1,130 @@
+package sanitizer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeReport(t *testing.T) {
+	content := `{"a": "x", "b": {"c": 1}, "d": "token=abc", "e": "secret_` + hashValue("x") + `"}`
+	sanitizer := newTestSanitizer(map[string]RuleInfo{
+		`$["a"]`:      {Description: "Replace a.", Action: ActionContextualReplacement},
+		`$["b"]["c"]`: {Description: "Delete c.", Action: ActionDelete},
+		"token":       {Description: "Remove the token.", Action: ActionRemove, Pattern: "token=(\\w+)", Partial: true},
+		`$["e"]`:      {Description: "Already sanitized.", Action: ActionContextualReplacement},
+	})
+	replacementContext := sanitizer.NewReplacementContext()
+	result, err := sanitizer.SanitizeWithReport(replacementContext, content, "json", "a.json", "a_sanitized.json")
+	require.NoError(t, err)
+	assert.False(t, result.IsDiffEmpty)
+	assert.Equal(t, []Finding{
+		{"a.json", `$["a"]`, "Replace a.", ActionContextualReplacement, `$["a"]`, "secret_" + hashValue("x"), "", 0},
+		{"a.json", `$["b"]["c"]`, "Delete c.", ActionDelete, `$["b"]["c"]`, "", "", 0},
+		{"a.json", "token", "Remove the token.", ActionRemove, `$["d"]`, "token=<REMOVED>", "", 0},
+	}, result.Report.Findings)
+
+	reportJson, err := result.Report.Export(ReportFormatJson)
+	require.NoError(t, err)
+	report := Report{}
+	require.NoError(t, json.Unmarshal(reportJson, &report))
+	assert.Equal(t, result.Report.Findings, report.Findings)
+	assert.Empty(t, report.UnmatchedRules)
+
+	_, err = result.Report.Export("xml")
+	assert.EqualError(t, err, "unsupported report format (xml), supported report formats are json,sarif")
+}
+
+func TestSanitizeReportFingerprints(t *testing.T) {
+	content := `{"a": "x", "b": "x", "c": "y"}`
+	sanitizer := newTestSanitizer(map[string]RuleInfo{
+		`$["a"]`: {Description: "Remove a.", Action: ActionRemove},
+		`$["b"]`: {Description: "Remove b.", Action: ActionRemove},
+		`$["c"]`: {Description: "Remove c.", Action: ActionRemove},
+	})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "json", "a.json", "a_sanitized.json")
+	require.NoError(t, err)
+	require.Len(t, result.Report.Findings, 3)
+	for _, finding := range result.Report.Findings {
+		assert.Empty(t, finding.ValueFingerprint)
+	}
+	reportJson, err := result.Report.Export(ReportFormatJson)
+	require.NoError(t, err)
+	assert.NotContains(t, string(reportJson), "valueFingerprint")
+
+	sanitizer.SetSecretKey([]byte("key"))
+	result, err = sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "json", "a.json", "a_sanitized.json")
+	require.NoError(t, err)
+	require.Len(t, result.Report.Findings, 3)
+	findings := result.Report.Findings
+	assert.NotEmpty(t, findings[0].ValueFingerprint)
+	assert.NotEqual(t, hashValue("x"), findings[0].ValueFingerprint)
+	assert.Equal(t, findings[0].ValueFingerprint, findings[1].ValueFingerprint)
+	assert.NotEqual(t, findings[0].ValueFingerprint, findings[2].ValueFingerprint)
+}
+
+func TestDryRun(t *testing.T) {
+	content := `{"a": "x", "b": "y"}`
+	sanitizer := newTestSanitizer(map[string]RuleInfo{
+		`$["a"]`:      {Action: ActionContextualReplacement},
+		`$["c"]`:      {Action: ActionRemove},
+		"unmatched":   {Action: ActionRemove, Pattern: "z"},
+		"invalid":     {Action: ActionRemove, Pattern: "("},
+		`$["b"]`:      {Action: ActionRemove},
+		`$..["d"]`:    {Action: ActionRemove},
+		`$["a", "b"]`: {Action: ActionNull},
+	})
+	vault := NewVault()
+	sanitizer.SetVault(vault)
+	replacementContext := sanitizer.NewReplacementContext()
+	result, err := sanitizer.DryRun(replacementContext, content, "json", "a.json", "a_sanitized.json")
+	partialSanitizationErr := &PartialSanitizationError{}
+	require.ErrorAs(t, err, &partialSanitizationErr)
+	assert.True(t, result.IsDryRun)
+	assert.Empty(t, result.Content)
+	assert.False(t, result.IsDiffEmpty)
+	assert.Contains(t, result.DiffPatchText, `-{"a": "x", "b": "y"}`)
+	// Without a rule order, the rules are ordered by key, so the null action of $["a", "b"] overrides the other rules.
+	assert.Len(t, result.Report.Findings, 2)
+	assert.Len(t, result.Report.Conflicts, 2)
+	assert.Equal(t, []UnmatchedRule{{"a.json", `$..["d"]`}, {"a.json", `$["c"]`}, {"a.json", "unmatched"}}, result.Report.UnmatchedRules)
+	// Nothing is recorded.
+	assert.Zero(t, vault.Len())
+	assert.Zero(t, replacementContext.Len())
+
+	result, err = sanitizer.SanitizeWithReport(replacementContext, content, "json", "a.json", "a_sanitized.json")
+	require.ErrorAs(t, err, &partialSanitizationErr)
+	assert.False(t, result.IsDryRun)
+	assert.NotEmpty(t, result.Content)
+	assert.Positive(t, vault.Len())
+}
+
+func TestReportToSarif(t *testing.T) {
+	report := Report{}
+	report.Merge(Report{Findings: []Finding{
+		{"b.yaml", "rule_1", "Rule 1.", ActionRemove, `$["x"]`, "<REMOVED>", "f1", 3},
+		{"a.har", "rule_2", "Rule 2.", ActionNull, `$["y"]`, "null", "f2", 0},
+	}})
+	report.Merge(Report{Findings: []Finding{{"a.har", "rule_1", "Rule 1.", ActionRemove, `$["x"]`, "<REMOVED>", "", 0}}})
+	assert.Equal(t, []string{"a.har", "a.har", "b.yaml"}, []string{report.Findings[0].FileName, report.Findings[1].FileName, report.Findings[2].FileName})
+
+	sarifBytes, err := report.Export(ReportFormatSarif)
+	require.NoError(t, err)
+	sarif := sarifLog{}
+	require.NoError(t, json.Unmarshal(sarifBytes, &sarif))
+	assert.Equal(t, sarifVersion, sarif.Version)
+	require.Len(t, sarif.Runs, 1)
+	assert.Equal(t, []sarifRule{{"rule_1", sarifMessage{"Rule 1."}}, {"rule_2", sarifMessage{"Rule 2."}}}, sarif.Runs[0].Tool.Driver.Rules)
+	require.Len(t, sarif.Runs[0].Results, 3)
+	result := sarif.Runs[0].Results[0]
+	assert.Equal(t, "rule_1", result.RuleId)
+	assert.Equal(t, "a.har", result.Locations[0].PhysicalLocation.ArtifactLocation.Uri)
+	assert.Equal(t, `$["x"]`, result.Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Nil(t, result.PartialFingerprints)
+	assert.Equal(t, map[string]string{"action": ActionRemove, "replacement": "<REMOVED>"}, result.Properties)
+	assert.Nil(t, result.Locations[0].PhysicalLocation.Region)
+	assert.Equal(t, &sarifRegion{StartLine: 3}, sarif.Runs[0].Results[2].Locations[0].PhysicalLocation.Region)
+	assert.Equal(t, map[string]string{"valueFingerprint/v1": "f1"}, sarif.Runs[0].Results[2].PartialFingerprints)
+}
@@ -0,0 +1,549 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Sanitizer sanitizes content using the rule set matching the content's file extension.
+// The contextual replacements are held in a ReplacementContext per sanitization (or per batch of files), so multiple
+// sanitizers and sanitizations can run concurrently in a process.
+type Sanitizer struct {
+	config           Config
+	compiledRuleSets map[string]*CompiledRuleSet
+	// secretPatterns match the values that are already sanitized, which aren't replaced again.
+	secretPatterns    []*regexp.Regexp
+	secretPatternsErr error
+	// mutex guards the userKey and the vault.
+	mutex sync.RWMutex
+	// userKey is the key set with SetSecretKey.
+	userKey []byte
+	// vault records the original values behind the contextual replacements, if set.
+	vault *Vault
+	// logger logs the diagnostics of the sanitizations, if set.
+	logger *slog.Logger
+}
+
+// New creates a Sanitizer with the provided config and rule sets (keyed by file extension).
+// The rule sets are compiled with CompileRuleSet, and the errors of their invalid rules are returned in the
+// PartialSanitizationError of each file sanitized with them. To report them when loading the rule sets instead, compile
+// them beforehand and use NewWithCompiledRuleSets.
+func New(config Config, ruleSets map[string]RuleSet) *Sanitizer {
+	compiledRuleSets := make(map[string]*CompiledRuleSet, len(ruleSets))
+	for fileExtension, ruleSet := range ruleSets {
+		compiledRuleSets[fileExtension], _ = CompileRuleSet(ruleSet, config)
+	}
+	return NewWithCompiledRuleSets(config, compiledRuleSets)
+}
+
+// NewWithCompiledRuleSets creates a Sanitizer with the provided config and compiled rule sets (keyed by file extension).
+// Compiled rule sets can be shared by any number of sanitizers.
+func NewWithCompiledRuleSets(config Config, compiledRuleSets map[string]*CompiledRuleSet) *Sanitizer {
+	sanitizer := &Sanitizer{
+		config:           config,
+		compiledRuleSets: compiledRuleSets,
+	}
+	for _, secretPattern := range []string{config.SecretPrefix + "_\\w+", config.RemovedSecretReplacement} {
+		compiledSecretPattern, err := regexp.Compile(secretPattern)
+		if err != nil {
+			sanitizer.secretPatternsErr = err
+			break
+		}
+		sanitizer.secretPatterns = append(sanitizer.secretPatterns, compiledSecretPattern)
+	}
+	return sanitizer
+}
+
+// SetSecretKey sets the key used to derive the contextual replacements (as HMAC-SHA256 of the secrets) in the
+// replacement contexts created afterward. Files sanitized with the same key get the same replacements for the same
+// secrets, so they can be correlated across files. An empty key reverts to the default key of the config's SecretKeyMode.
+func (sanitizer *Sanitizer) SetSecretKey(key []byte) {
+	sanitizer.mutex.Lock()
+	defer sanitizer.mutex.Unlock()
+	sanitizer.userKey = bytes.Clone(key)
+}
+
+// SetVault sets the vault to record the original values behind the contextual replacements in. A nil vault stops recording.
+func (sanitizer *Sanitizer) SetVault(vault *Vault) {
+	sanitizer.mutex.Lock()
+	defer sanitizer.mutex.Unlock()
+	sanitizer.vault = vault
+}
+
+// SetLogger sets the logger of the diagnostics of the sanitizations, such as the rules applied and the errors
+// encountered. The diagnostics never include the values of the content. A nil logger (the default) discards them.
+func (sanitizer *Sanitizer) SetLogger(logger *slog.Logger) {
+	sanitizer.mutex.Lock()
+	defer sanitizer.mutex.Unlock()
+	sanitizer.logger = logger
+}
+
+// getSecretKey returns the key set with SetSecretKey, or nil if it isn't set.
+func (sanitizer *Sanitizer) getSecretKey() []byte {
+	sanitizer.mutex.RLock()
+	defer sanitizer.mutex.RUnlock()
+	if len(sanitizer.userKey) > 0 {
+		return sanitizer.userKey
+	}
+	return nil
+}
+
+// Config returns the config the sanitizer was created with.
+func (sanitizer *Sanitizer) Config() Config {
+	return sanitizer.config
+}
+
+// RuleSets returns the rule sets the sanitizer was created with, keyed by file extension.
+func (sanitizer *Sanitizer) RuleSets() map[string]RuleSet {
+	ruleSets := make(map[string]RuleSet, len(sanitizer.compiledRuleSets))
+	for fileExtension, compiledRuleSet := range sanitizer.compiledRuleSets {
+		ruleSets[fileExtension] = compiledRuleSet.RuleSet
+	}
+	return ruleSets
+}
+
+// CompiledRuleSets returns the compiled rule sets of the sanitizer, keyed by file extension.
+func (sanitizer *Sanitizer) CompiledRuleSets() map[string]*CompiledRuleSet {
+	return sanitizer.compiledRuleSets
+}
+
+func (sanitizer *Sanitizer) getSecretReplacement(replacementContext *ReplacementContext, secret string, prefix string) (string, error) {
+	// Check if secret has already been replaced.
+	// Need to consider the scenario when the secret pattern matches the actual secret.
+	if sanitizer.secretPatternsErr != nil {
+		return "", sanitizer.secretPatternsErr
+	}
+	for _, secretPattern := range sanitizer.secretPatterns {
+		if secretPattern.MatchString(secret) {
+			sanitizer.getLogger().Debug("Skipping the contextual replacement of a value that's already sanitized.")
+			return secret, nil
+		}
+	}
+
+	secretReplacement, isSecretReplacementPresent := replacementContext.getSecretReplacement(secret, prefix)
+	if isSecretReplacementPresent {
+		sanitizer.getLogger().Debug("Reusing the contextual replacement.", "replacement", secretReplacement)
+	}
+	if !replacementContext.isDryRun {
+		sanitizer.recordInVault(secretReplacement, secret)
+	}
+	return secretReplacement, nil
+}
+
+func (sanitizer *Sanitizer) recordInVault(secretReplacement string, secret string) {
+	sanitizer.mutex.RLock()
+	vault := sanitizer.vault
+	sanitizer.mutex.RUnlock()
+	if vault != nil {
+		vault.Add(secretReplacement, secret)
+	}
+}
+
+// getActionReplacement returns the replacement of the value for the specified rule.
+// It only supports actions that produce a string replacement.
+func (sanitizer *Sanitizer) getActionReplacement(replacementContext *ReplacementContext, value string, ruleKey string, ruleInfo RuleInfo) (string, error) {
+	switch ruleInfo.Action {
+	case ActionContextualReplacement:
+		return sanitizer.getSecretReplacement(replacementContext, value, sanitizer.config.SecretPrefix)
+	case ActionRemove:
+		return sanitizer.config.RemovedSecretReplacement, nil
+	case ActionMask:
+		return mask(value, ruleInfo.KeepLast, ruleInfo.MaskCharacter), nil
+	case ActionTruncate:
+		return truncate(value, ruleInfo.MaxLength), nil
+	case ActionHash:
+		return hashValue(value), nil
+	}
+	return "", fmt.Errorf("unsupported action (%s)", ruleInfo.Action)
+}
+
+// getValueReplacement returns the replacement of the JSON value at the JSON path of the content for the specified rule,
+// and whether it needs to be replaced.
+// The actions are applied to the text of non string values (see getJsonValueText), which are replaced with a string
+// unless the rule keeps their type.
+func (sanitizer *Sanitizer) getValueReplacement(replacementContext *ReplacementContext, document document, jsonPath string, value interface{}, ruleKey string, ruleInfo RuleInfo, pattern *regexp.Regexp) (jsonValueReplacement, bool, error) {
+	if ruleInfo.Action == ActionDelete {
+		return jsonValueReplacement{Delete: true}, true, nil
+	} else if ruleInfo.Action == ActionNull || value == nil {
+		// There's nothing to sanitize in a null value.
+		return jsonValueReplacement{RawValue: "null"}, value != nil, nil
+	}
+
+	valueStr, isString := value.(string)
+	if !isString {
+		var err error
+		if valueStr, err = getJsonValueText(document, jsonPath, value); err != nil {
+			return jsonValueReplacement{}, false, err
+		}
+	}
+	var replacementValue string
+	var err error
+	if pattern != nil && ruleInfo.Partial {
+		replacementValue, err = replacePatternMatches(valueStr, pattern, func(match string) (string, error) {
+			return sanitizer.getActionReplacement(replacementContext, match, ruleKey, ruleInfo)
+		})
+	} else {
+		replacementValue, err = sanitizer.getActionReplacement(replacementContext, valueStr, ruleKey, ruleInfo)
+	}
+	if err != nil || replacementValue == valueStr {
+		return jsonValueReplacement{}, false, err
+	}
+	if !isString && ruleInfo.KeepType {
+		rawValue, err := getTypedReplacement(value, ruleInfo.Action, replacementValue)
+		return jsonValueReplacement{RawValue: rawValue}, err == nil && rawValue != valueStr, err
+	}
+	rawValue, err := toJsonString(replacementValue)
+	return jsonValueReplacement{RawValue: rawValue}, err == nil, err
+}
+
+// runRuleDetectionTask detects the values matched by the rule in the content, and their replacements.
+// The rule's errors are returned in the output, so the other rules can still be applied.
+func (sanitizer *Sanitizer) runRuleDetectionTask(ctx context.Context, ruleDetectionTaskInput ruleDetectionTaskInput) (ruleDetectionTaskOutput, error) {
+	rule := ruleDetectionTaskInput.Rule
+	ruleJsonPath := rule.Key
+	ruleInfo := rule.Info
+	sanitizer.getLogger().Debug("Applying the rule.", "rule", ruleJsonPath, "action", ruleInfo.Action)
+
+	output := ruleDetectionTaskOutput{RuleKey: ruleJsonPath, Replacements: map[string]jsonValueReplacement{}}
+	valuesMap, pattern, errs := ruleDetectionTaskInput.Document.findValues(rule, ruleDetectionTaskInput.PathState)
+	for _, err := range errs {
+		sanitizer.logError(err)
+		output.Errors = append(output.Errors, err)
+	}
+	if pattern != nil {
+		valuesMap = findPatternMatches(valuesMap, pattern)
+	} else if ruleInfo.Recursive {
+		valuesMap = findLeafValues(valuesMap)
+	}
+	output.IsMatched = len(valuesMap) > 0
+	sanitizer.getLogger().Debug("Matched the values of the rule.", "rule", ruleJsonPath, "count", len(valuesMap))
+	if len(valuesMap) > 0 {
+		for jsonPath, value := range valuesMap {
+			if err := ctx.Err(); err != nil {
+				return output, err
+			}
+			replacement, isReplaced, err := sanitizer.getValueReplacement(ruleDetectionTaskInput.ReplacementContext, ruleDetectionTaskInput.Document, jsonPath, value, ruleJsonPath, ruleInfo, pattern)
+			if err != nil {
+				err = &RuleError{RuleKey: ruleJsonPath, JsonPath: jsonPath, Err: err}
+				sanitizer.logError(err)
+				output.Errors = append(output.Errors, err)
+			} else if !isReplaced {
+				sanitizer.getLogger().Debug("Skipping a value that's already sanitized.", "rule", ruleJsonPath, "jsonPath", jsonPath)
+			} else {
+				output.Replacements[jsonPath] = replacement
+				finding := newFinding(ruleDetectionTaskInput.ReplacementContext, ruleDetectionTaskInput.FileName, jsonPath, getFindingValueText(ruleDetectionTaskInput.Document, jsonPath, value), ruleJsonPath, ruleInfo, replacement)
+				finding.Line = ruleDetectionTaskInput.Document.getLine(jsonPath)
+				output.Findings = append(output.Findings, finding)
+			}
+		}
+	}
+	return output, nil
+}
+
+// Sanitize sanitizes the content using the rule set for the specified file extension, with a new replacement context.
+// It returns the sanitized content (formatted as per the config's output format), the unified diff between the content
+// and the sanitized content and whether the diff is empty.
+//
+// The content is parsed as per the rule set's format (see RuleSet.Format). For ContentFormatYaml, the rules are applied
+// to each document of the YAML stream, and the comments, anchors and key order are preserved unless the output is
+// reformatted.
+func (sanitizer *Sanitizer) Sanitize(content string, fileExtension string, inputFileName string, outputFileName string) (string, string, bool, error) {
+	return sanitizer.SanitizeWithReplacementContext(sanitizer.NewReplacementContext(), content, fileExtension, inputFileName, outputFileName)
+}
+
+// SanitizeWithReplacementContext sanitizes the content like Sanitize, using the provided replacement context.
+// Sharing a replacement context across files gets the same replacements for identical secrets in them.
+func (sanitizer *Sanitizer) SanitizeWithReplacementContext(replacementContext *ReplacementContext, content string, fileExtension string, inputFileName string, outputFileName string) (string, string, bool, error) {
+	result, err := sanitizer.SanitizeWithReport(replacementContext, content, fileExtension, inputFileName, outputFileName)
+	return result.Content, result.DiffPatchText, result.IsDiffEmpty, err
+}
+
+// SanitizeResult is the result of sanitizing a file.
+type SanitizeResult struct {
+	// Content is the sanitized content, formatted as per the config's output format.
+	Content string
+	// DiffPatchText is the unified diff between the original content and the sanitized content.
+	DiffPatchText string
+	IsDiffEmpty   bool
+	// Report lists the values sanitized in the file.
+	Report Report
+	// IsDryRun is set if the values were only detected, and the content isn't sanitized (see Sanitizer.DryRun).
+	IsDryRun bool
+}
+
+// SanitizeWithReport sanitizes the content like SanitizeWithReplacementContext, and also returns the report of the
+// sanitized values.
+//
+// If the content can't be sanitized at all, an ErrSecretKeyRequired, UnsupportedFormatError or InvalidInputError is
+// returned. If only some of the rules or replacements couldn't be applied, the partially sanitized content is returned
+// along with a PartialSanitizationError.
+func (sanitizer *Sanitizer) SanitizeWithReport(replacementContext *ReplacementContext, content string, fileExtension string, inputFileName string, outputFileName string) (SanitizeResult, error) {
+	return sanitizer.SanitizeContext(context.Background(), replacementContext, content, fileExtension, inputFileName, outputFileName)
+}
+
+// SanitizeContext sanitizes the content like SanitizeWithReport, until the context is done.
+// If the context is done before the rules are applied, the context's error is returned without any content.
+func (sanitizer *Sanitizer) SanitizeContext(ctx context.Context, replacementContext *ReplacementContext, content string, fileExtension string, inputFileName string, outputFileName string) (SanitizeResult, error) {
+	return sanitizer.sanitize(ctx, replacementContext, content, fileExtension, inputFileName, outputFileName, false)
+}
+
+// DryRun detects the values the rules would sanitize in the content, without sanitizing it.
+// It returns the report of the values and the proposed diff, but no content, and the result is marked as a dry run.
+// The replacements aren't recorded in the vault or the replacement context, and errors are reported like
+// SanitizeWithReport.
+func (sanitizer *Sanitizer) DryRun(replacementContext *ReplacementContext, content string, fileExtension string, inputFileName string, outputFileName string) (SanitizeResult, error) {
+	return sanitizer.DryRunContext(context.Background(), replacementContext, content, fileExtension, inputFileName, outputFileName)
+}
+
+// DryRunContext detects the values the rules would sanitize in the content like DryRun, until the context is done.
+// If the context is done before the rules are applied, the context's error is returned.
+func (sanitizer *Sanitizer) DryRunContext(ctx context.Context, replacementContext *ReplacementContext, content string, fileExtension string, inputFileName string, outputFileName string) (SanitizeResult, error) {
+	return sanitizer.sanitize(ctx, replacementContext.newDryRunContext(), content, fileExtension, inputFileName, outputFileName, true)
+}
+
+func (sanitizer *Sanitizer) sanitize(ctx context.Context, replacementContext *ReplacementContext, content string, fileExtension string, inputFileName string, outputFileName string, isDryRun bool) (SanitizeResult, error) {
+	result := SanitizeResult{IsDiffEmpty: true, IsDryRun: isDryRun}
+	if replacementContext.err != nil {
+		sanitizer.logError(replacementContext.err)
+		return result, replacementContext.err
+	}
+	if sanitizer.config.SecretKeyMode == SecretKeyModeUser && replacementContext.secretKey == nil {
+		sanitizer.logError(ErrSecretKeyRequired)
+		return result, ErrSecretKeyRequired
+	}
+	compiledRuleSet, err := sanitizer.getCompiledRuleSet(fileExtension)
+	if err != nil {
+		sanitizer.logError(err)
+		return result, err
+	}
+	ruleSet := compiledRuleSet.RuleSet
+	format, err := ruleSet.getFormat()
+	if err != nil {
+		sanitizer.logError(err)
+		return result, err
+	}
+	// The content is parsed once, and the rules are evaluated against the shared documents.
+	parsedContent, err := parseContent(content, format, ruleSet)
+	if err != nil {
+		invalidInputErr := &InvalidInputError{FileName: inputFileName, Err: err}
+		sanitizer.logError(invalidInputErr)
+		return result, invalidInputErr
+	}
+	documents := parsedContent.getDocuments()
+	sanitizer.getLogger().Debug("Sanitizing the content.", "file", inputFileName, "format", format, "rules", len(compiledRuleSet.Rules), "documents", len(documents))
+	ruleDetectionTaskInputs := make([]ruleDetectionTaskInput, 0, len(documents)*len(compiledRuleSet.Rules))
+	for _, document := range documents {
+		for _, rule := range compiledRuleSet.Rules {
+			ruleDetectionTaskInput := ruleDetectionTaskInput{
+				Document:           document,
+				Rule:               rule,
+				ReplacementContext: replacementContext,
+				FileName:           inputFileName,
+			}
+			ruleDetectionTaskInputs = append(ruleDetectionTaskInputs, ruleDetectionTaskInput)
+		}
+	}
+	// The results are in the order of the documents, and then of the rules, i.e. their precedence.
+	ruleDetectionTaskResults := RunTasks(ctx, sanitizer.runRuleDetectionTask, ruleDetectionTaskInputs, TaskOptions{Concurrency: sanitizer.config.MaxConcurrency})
+	if err := ctx.Err(); err != nil {
+		sanitizer.logError(err)
+		return result, err
+	}
+
+	// The rules that failed to compile are reported along with the errors of the applied rules.
+	errs := slices.Clone(compiledRuleSet.Errors)
+	ruleDetectionTaskOutputs := make([][]ruleDetectionTaskOutput, len(documents))
+	// A rule is unmatched if it didn't match any value in any of the documents.
+	isRuleMatched := map[string]bool{}
+	for index, ruleDetectionTaskResult := range ruleDetectionTaskResults {
+		output := ruleDetectionTaskResult.Output
+		if ruleDetectionTaskResult.Err != nil {
+			ruleKey := ruleDetectionTaskInputs[index].Rule.Key
+			err := &RuleError{RuleKey: ruleKey, JsonPath: "$", Err: ruleDetectionTaskResult.Err}
+			sanitizer.logError(err)
+			output = ruleDetectionTaskOutput{RuleKey: ruleKey, Errors: []error{err}}
+		}
+		documentIndex := index / len(compiledRuleSet.Rules)
+		ruleDetectionTaskOutputs[documentIndex] = append(ruleDetectionTaskOutputs[documentIndex], output)
+		errs = append(errs, output.Errors...)
+		isRuleMatched[output.RuleKey] = isRuleMatched[output.RuleKey] || output.IsMatched || len(output.Errors) > 0
+	}
+	for _, rule := range compiledRuleSet.Rules {
+		if !isRuleMatched[rule.Key] {
+			result.Report.Merge(Report{UnmatchedRules: []UnmatchedRule{{FileName: inputFileName, RuleKey: rule.Key}}})
+		}
+	}
+	replacementMaps := make([]map[string]jsonValueReplacement, len(documents))
+	for documentIndex := range documents {
+		replacementMap, report := mergeRuleReplacements(ruleDetectionTaskOutputs[documentIndex], inputFileName)
+		result.Report.Merge(report)
+		replacementMaps[documentIndex] = replacementMap
+	}
+	sanitizedContent, err := parsedContent.setValues(replacementMaps)
+	if err != nil {
+		sanitizer.logError(err)
+		errs = append(errs, err)
+	}
+	sanitizedContentBytes, err := parsedContent.format(sanitizedContent, sanitizer.config.OutputFormat, sanitizer.config.OutputIndent)
+	if err != nil {
+		sanitizer.logError(err)
+		return result, err
+	}
+	result.DiffPatchText, result.IsDiffEmpty = getDiff(content, inputFileName, string(sanitizedContentBytes), outputFileName)
+	if !isDryRun {
+		result.Content = string(sanitizedContentBytes)
+	}
+	return result, newPartialSanitizationError(errs)
+}
+
+// mergeRuleReplacements merges the replacements of the rules (in the order of their precedence) into a single replacement map.
+// When multiple rules match the same value, the replacement of the rule that takes precedence is applied, and a
+// conflict is reported if the replacements differ. Likewise, the spans of text content overlapping a span that is
+// replaced by a rule that takes precedence are skipped, and reported as conflicts. Replacements within a value that is
+// replaced or deleted as a whole are dropped. It returns the replacement map, and the report of the findings of the
+// applied replacements and the conflicts.
+func mergeRuleReplacements(ruleDetectionTaskOutputs []ruleDetectionTaskOutput, fileName string) (map[string]jsonValueReplacement, Report) {
+	replacementMap := map[string]jsonValueReplacement{}
+	appliedFindings := map[string]Finding{}
+	// appliedTextSpans are the text span paths of the applied findings, keyed by their line.
+	appliedTextSpans := map[int][]string{}
+	report := Report{}
+	for _, ruleDetectionTaskOutput := range ruleDetectionTaskOutputs {
+		for _, finding := range ruleDetectionTaskOutput.Findings {
+			replacement := ruleDetectionTaskOutput.Replacements[finding.JsonPath]
+			appliedFinding, isPresent := appliedFindings[finding.JsonPath]
+			if !isPresent {
+				overlappingSpanIndex := slices.IndexFunc(appliedTextSpans[finding.Line], func(textSpanPath string) bool {
+					return isOverlappingTextSpan(textSpanPath, finding.JsonPath)
+				})
+				if overlappingSpanIndex >= 0 {
+					appliedFinding = appliedFindings[appliedTextSpans[finding.Line][overlappingSpanIndex]]
+					report.Conflicts = append(report.Conflicts, RuleConflict{
+						FileName:          fileName,
+						JsonPath:          finding.JsonPath,
+						Line:              finding.Line,
+						RuleKey:           appliedFinding.RuleKey,
+						Action:            appliedFinding.Action,
+						OverriddenRuleKey: finding.RuleKey,
+						OverriddenAction:  finding.Action,
+					})
+					continue
+				}
+				if _, _, _, isTextSpan := parseTextSpanPath(finding.JsonPath); isTextSpan {
+					appliedTextSpans[finding.Line] = append(appliedTextSpans[finding.Line], finding.JsonPath)
+				}
+				replacementMap[finding.JsonPath] = replacement
+				appliedFindings[finding.JsonPath] = finding
+			} else if replacementMap[finding.JsonPath] != replacement {
+				report.Conflicts = append(report.Conflicts, RuleConflict{
+					FileName:          fileName,
+					JsonPath:          finding.JsonPath,
+					Line:              appliedFinding.Line,
+					RuleKey:           appliedFinding.RuleKey,
+					Action:            appliedFinding.Action,
+					OverriddenRuleKey: finding.RuleKey,
+					OverriddenAction:  finding.Action,
+				})
+			}
+		}
+	}
+
+	// The paths within a value sort right after it, as they have its path as a prefix.
+	jsonPaths := make([]string, 0, len(replacementMap))
+	for jsonPath := range replacementMap {
+		jsonPaths = append(jsonPaths, jsonPath)
+	}
+	slices.Sort(jsonPaths)
+	coveringJsonPath := ""
+	for _, jsonPath := range jsonPaths {
+		if coveringJsonPath != "" && strings.HasPrefix(jsonPath, coveringJsonPath+"[") {
+			delete(replacementMap, jsonPath)
+			delete(appliedFindings, jsonPath)
+		} else {
+			coveringJsonPath = jsonPath
+		}
+	}
+	for _, finding := range appliedFindings {
+		report.Findings = append(report.Findings, finding)
+	}
+	report.Merge(Report{})
+	return replacementMap, report
+}
+
+// getCompiledRuleSet returns the compiled rule set for the file extension, or an UnsupportedFormatError if there's none.
+func (sanitizer *Sanitizer) getCompiledRuleSet(fileExtension string) (*CompiledRuleSet, error) {
+	compiledRuleSet, isPresent := sanitizer.compiledRuleSets[fileExtension]
+	if !isPresent || !slices.Contains(sanitizer.config.SupportedFileExtensions, fileExtension) {
+		return nil, &UnsupportedFormatError{FileExtension: fileExtension, SupportedFileExtensions: sanitizer.config.SupportedFileExtensions}
+	}
+	return compiledRuleSet, nil
+}
+
+// Desanitize restores the original values behind the contextual replacements in the content, using the vault.
+// Replacements that aren't present in the vault are retained.
+// It returns the desanitized content, the unified diff between the content and the desanitized content and whether the diff is empty.
+// Errors are reported like SanitizeWithReplacementContext.
+func (sanitizer *Sanitizer) Desanitize(content string, fileExtension string, inputFileName string, outputFileName string, vault *Vault) (string, string, bool, error) {
+	compiledRuleSet, err := sanitizer.getCompiledRuleSet(fileExtension)
+	if err != nil {
+		sanitizer.logError(err)
+		return "", "", true, err
+	}
+	format, err := compiledRuleSet.RuleSet.getFormat()
+	if err != nil {
+		sanitizer.logError(err)
+		return "", "", true, err
+	}
+	parsedContent, err := parseContent(content, format, compiledRuleSet.RuleSet)
+	if err != nil {
+		invalidInputErr := &InvalidInputError{FileName: inputFileName, Err: err}
+		sanitizer.logError(invalidInputErr)
+		return "", "", true, invalidInputErr
+	}
+
+	replacementPattern := regexp.MustCompile(regexp.QuoteMeta(sanitizer.config.SecretPrefix) + "_[0-9a-f]{64}")
+	documents := parsedContent.getDocuments()
+	replacementMaps := make([]map[string]jsonValueReplacement, len(documents))
+	for documentIndex, document := range documents {
+		replacementMap := map[string]jsonValueReplacement{}
+		for jsonPath, value := range findPatternMatches(document.getValues(), replacementPattern) {
+			valueStr := value.(string)
+			originalValue, _ := replacePatternMatches(valueStr, replacementPattern, func(secretReplacement string) (string, error) {
+				if secret, isPresent := vault.Get(secretReplacement); isPresent {
+					return secret, nil
+				}
+				sanitizer.getLogger().Debug("Replacement not found in the vault.", "jsonPath", jsonPath, "replacement", secretReplacement)
+				return secretReplacement, nil
+			})
+			if originalValue != valueStr {
+				rawValue, err := toJsonString(originalValue)
+				if err != nil {
+					return "", "", true, err
+				}
+				replacementMap[jsonPath] = jsonValueReplacement{RawValue: rawValue}
+			}
+		}
+		replacementMaps[documentIndex] = replacementMap
+	}
+
+	desanitizedContent, err := parsedContent.setValues(replacementMaps)
+	errs := []error{err}
+	if err != nil {
+		sanitizer.logError(err)
+	}
+	desanitizedContentBytes, err := parsedContent.format(desanitizedContent, sanitizer.config.OutputFormat, sanitizer.config.OutputIndent)
+	if err != nil {
+		sanitizer.logError(err)
+		return "", "", true, err
+	}
+	desanitizedContent = string(desanitizedContentBytes)
+	diffPatchText, isDiffEmpty := getDiff(content, inputFileName, desanitizedContent, outputFileName)
+	return desanitizedContent, diffPatchText, isDiffEmpty, newPartialSanitizationError(errs)
+}
@@ -0,0 +1,746 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kinds of JSON tokens, other than the delimiters ({, }, [, ], : and ,) which are their own kind.
+const (
+	jsonTokenKindEOF     = 0
+	jsonTokenKindString  = '"'
+	jsonTokenKindLiteral = 'l'
+	// jsonTokenKindValue is the text of a whole JSON value written by jsonTokenWriter, rather than a token.
+	jsonTokenKindValue = 'v'
+)
+
+// streamCancellationInterval is the number of tokens read between the checks of the context while streaming.
+const streamCancellationInterval = 4096
+
+const streamBufferSize = 64 * 1024
+
+// jsonToken is a token of JSON content, along with the whitespace before it.
+type jsonToken struct {
+	Kind       byte
+	Whitespace []byte
+	// Raw is the token's text in the content. Strings include their quotes.
+	Raw []byte
+	// Offset is the offset of the token in the content.
+	Offset int64
+}
+
+// jsonTokenReader reads the tokens of JSON content from a reader, one at a time.
+type jsonTokenReader struct {
+	ctx         context.Context
+	reader      *bufio.Reader
+	offset      int64
+	tokensCount int
+}
+
+func newJsonTokenReader(ctx context.Context, reader io.Reader) *jsonTokenReader {
+	return &jsonTokenReader{ctx: ctx, reader: bufio.NewReaderSize(reader, streamBufferSize)}
+}
+
+func (tokenReader *jsonTokenReader) readByte() (byte, error) {
+	b, err := tokenReader.reader.ReadByte()
+	if err == nil {
+		tokenReader.offset++
+	}
+	return b, err
+}
+
+// next returns the next token. At the end of the content, a token of kind jsonTokenKindEOF is returned with the
+// trailing whitespace. Invalid tokens are returned as an InvalidInputError (without the file name).
+func (tokenReader *jsonTokenReader) next() (jsonToken, error) {
+	if tokenReader.tokensCount%streamCancellationInterval == 0 {
+		if err := tokenReader.ctx.Err(); err != nil {
+			return jsonToken{}, err
+		}
+	}
+	tokenReader.tokensCount++
+	token := jsonToken{}
+	for {
+		b, err := tokenReader.readByte()
+		if err == io.EOF {
+			token.Kind = jsonTokenKindEOF
+			token.Offset = tokenReader.offset
+			return token, nil
+		} else if err != nil {
+			return token, err
+		}
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			token.Whitespace = append(token.Whitespace, b)
+			continue
+		}
+		token.Offset = tokenReader.offset - 1
+		switch b {
+		case '{', '}', '[', ']', ':', ',':
+			token.Kind = b
+			token.Raw = []byte{b}
+			return token, nil
+		case '"':
+			token.Kind = jsonTokenKindString
+			token.Raw, err = tokenReader.readString()
+		default:
+			token.Kind = jsonTokenKindLiteral
+			token.Raw, err = tokenReader.readLiteral(b)
+		}
+		if err == nil && !json.Valid(token.Raw) {
+			err = &InvalidInputError{Err: fmt.Errorf("invalid value at offset %d", token.Offset)}
+		}
+		return token, err
+	}
+}
+
+// readString reads the rest of a string after its opening quote, returning the string with its quotes.
+func (tokenReader *jsonTokenReader) readString() ([]byte, error) {
+	raw := []byte{'"'}
+	isEscaped := false
+	for {
+		b, err := tokenReader.readByte()
+		if err == io.EOF {
+			return raw, &InvalidInputError{Err: fmt.Errorf("unterminated string at offset %d", tokenReader.offset)}
+		} else if err != nil {
+			return raw, err
+		}
+		raw = append(raw, b)
+		if isEscaped {
+			isEscaped = false
+		} else if b == '\\' {
+			isEscaped = true
+		} else if b == '"' {
+			return raw, nil
+		}
+	}
+}
+
+// readLiteral reads the rest of a number, boolean or null after its first byte.
+func (tokenReader *jsonTokenReader) readLiteral(first byte) ([]byte, error) {
+	raw := []byte{first}
+	for {
+		b, err := tokenReader.readByte()
+		if err == io.EOF {
+			return raw, nil
+		} else if err != nil {
+			return raw, err
+		}
+		switch b {
+		case ' ', '\t', '\n', '\r', '{', '}', '[', ']', ':', ',', '"':
+			tokenReader.offset--
+			return raw, tokenReader.reader.UnreadByte()
+		}
+		raw = append(raw, b)
+	}
+}
+
+// jsonTokenWriter writes JSON tokens as per the output format (see Config.OutputFormat). The pretty output is
+// identical to indenting the whole content with json.Indent.
+type jsonTokenWriter struct {
+	writer       *bufio.Writer
+	outputFormat string
+	indent       string
+	depth        int
+	// needIndent is set after an opening bracket, until it's known whether the object or array is empty.
+	needIndent bool
+}
+
+func newJsonTokenWriter(writer io.Writer, outputFormat string, indent string) (*jsonTokenWriter, error) {
+	switch outputFormat {
+	case OutputFormatPreserve, OutputFormatMinify:
+	case OutputFormatPretty, "":
+		outputFormat = OutputFormatPretty
+		if indent == "" {
+			indent = defaultOutputIndent
+		}
+	default:
+		return nil, errors.New("unsupported output format (" + outputFormat + ")")
+	}
+	return &jsonTokenWriter{writer: bufio.NewWriterSize(writer, streamBufferSize), outputFormat: outputFormat, indent: indent}, nil
+}
+
+func (tokenWriter *jsonTokenWriter) writeNewline() {
+	_ = tokenWriter.writer.WriteByte('\n')
+	for range tokenWriter.depth {
+		_, _ = tokenWriter.writer.WriteString(tokenWriter.indent)
+	}
+}
+
+// write writes the token of the kind, preceded by the whitespace if the formatting is preserved. The text of whole
+// values (of kind jsonTokenKindValue) is formatted as per the output format.
+func (tokenWriter *jsonTokenWriter) write(kind byte, whitespace []byte, raw []byte) error {
+	switch tokenWriter.outputFormat {
+	case OutputFormatPreserve:
+		_, _ = tokenWriter.writer.Write(whitespace)
+		_, err := tokenWriter.writer.Write(raw)
+		return err
+	case OutputFormatMinify:
+		if kind == jsonTokenKindValue {
+			var out bytes.Buffer
+			if err := json.Compact(&out, raw); err != nil {
+				return err
+			}
+			raw = out.Bytes()
+		}
+		_, err := tokenWriter.writer.Write(raw)
+		return err
+	}
+
+	if tokenWriter.needIndent && kind != '}' && kind != ']' {
+		tokenWriter.needIndent = false
+		tokenWriter.depth++
+		tokenWriter.writeNewline()
+	}
+	switch kind {
+	case '{', '[':
+		tokenWriter.needIndent = true
+	case ',':
+		_ = tokenWriter.writer.WriteByte(',')
+		tokenWriter.writeNewline()
+		return nil
+	case ':':
+		_, err := tokenWriter.writer.WriteString(": ")
+		return err
+	case '}', ']':
+		if tokenWriter.needIndent {
+			// The object or array is empty.
+			tokenWriter.needIndent = false
+		} else {
+			tokenWriter.depth--
+			tokenWriter.writeNewline()
+		}
+	case jsonTokenKindValue:
+		var out bytes.Buffer
+		if err := json.Indent(&out, raw, strings.Repeat(tokenWriter.indent, tokenWriter.depth), tokenWriter.indent); err != nil {
+			return err
+		}
+		raw = out.Bytes()
+	}
+	_, err := tokenWriter.writer.Write(raw)
+	return err
+}
+
+// writeTrailingWhitespace writes the whitespace after the root value, which is only dropped by the minify format.
+func (tokenWriter *jsonTokenWriter) writeTrailingWhitespace(whitespace []byte) error {
+	if tokenWriter.outputFormat != OutputFormatMinify {
+		_, _ = tokenWriter.writer.Write(whitespace)
+	}
+	return tokenWriter.writer.Flush()
+}
+
+// streamStateActive is the state of a rule that applies to every value within the value it matched: the values in the
+// scope of a pattern rule, or the values within the objects and arrays matched by a recursive rule.
+const streamStateActive = -1
+
+// streamRuleState is the state of matching a rule's JSON path at a value.
+type streamRuleState struct {
+	RuleIndex int
+	// State is the index of the next segment of the rule's path pattern to match, or streamStateActive.
+	State int
+}
+
+// jsonStreamWalker walks the tokens of JSON content, writing the sanitized content as they're read.
+type jsonStreamWalker struct {
+	sanitizer          *Sanitizer
+	ctx                context.Context
+	replacementContext *ReplacementContext
+	fileName           string
+	rules              []*CompiledRule
+	tokenReader        *jsonTokenReader
+	tokenWriter        *jsonTokenWriter
+	report             Report
+	// findingHandler is passed the findings instead of recording them in the report, if it's set.
+	findingHandler func(Finding)
+	errs           []error
+	// matchedRuleKeys are the keys of the rules that matched any values, including the ones that are already sanitized.
+	matchedRuleKeys map[string]bool
+	// failedRuleKeys are the keys of the rules that couldn't be applied to some of the values they matched.
+	failedRuleKeys map[string]bool
+}
+
+// CanStream returns whether the content of the file extension can be sanitized with SanitizeStream, i.e. its rule set
+// has the ContentFormatJson format.
+func (sanitizer *Sanitizer) CanStream(fileExtension string) bool {
+	compiledRuleSet, err := sanitizer.getCompiledRuleSet(fileExtension)
+	if err != nil {
+		return false
+	}
+	format, err := compiledRuleSet.RuleSet.getFormat()
+	return err == nil && format == ContentFormatJson
+}
+
+// SanitizeStream sanitizes the JSON content read from the reader like SanitizeContext, writing the sanitized content
+// (formatted as per the config's output format) to the writer as it's read. It returns the report of the sanitized
+// values. There's no diff, as the content isn't retained.
+//
+// If the finding handler is set, the findings are passed to it in the order of the content instead of being recorded in
+// the report, so the memory used doesn't grow with the number of findings.
+//
+// The rules' JSON paths are matched while streaming, so only the values a rule has to be evaluated against as a whole
+// are held in memory: the objects and arrays matched by non-recursive rules, and the values where a rule's JSON path
+// has a segment other than a key, an index, a wildcard or a descendant key (Ex: a filter). For such segments, the rest
+// of the JSON path is evaluated against the value, so a filter early in a JSON path holds more of the content in
+// memory.
+//
+// Errors are returned like SanitizeWithReport. If the content is invalid or the context is done while streaming, the
+// sanitized content written until then is incomplete and must be discarded. Only the rule sets of ContentFormatJson
+// can be streamed.
+func (sanitizer *Sanitizer) SanitizeStream(ctx context.Context, replacementContext *ReplacementContext, reader io.Reader, writer io.Writer, fileExtension string, inputFileName string, findingHandler func(Finding)) (Report, error) {
+	if replacementContext.err != nil {
+		sanitizer.logError(replacementContext.err)
+		return Report{}, replacementContext.err
+	}
+	if sanitizer.config.SecretKeyMode == SecretKeyModeUser && replacementContext.secretKey == nil {
+		sanitizer.logError(ErrSecretKeyRequired)
+		return Report{}, ErrSecretKeyRequired
+	}
+	compiledRuleSet, err := sanitizer.getCompiledRuleSet(fileExtension)
+	if err != nil {
+		sanitizer.logError(err)
+		return Report{}, err
+	}
+	if format, err := compiledRuleSet.RuleSet.getFormat(); err != nil {
+		sanitizer.logError(err)
+		return Report{}, err
+	} else if format != ContentFormatJson {
+		err = fmt.Errorf("streaming isn't supported for the %s format", format)
+		sanitizer.logError(err)
+		return Report{}, err
+	}
+	tokenWriter, err := newJsonTokenWriter(writer, sanitizer.config.OutputFormat, sanitizer.config.OutputIndent)
+	if err != nil {
+		sanitizer.logError(err)
+		return Report{}, err
+	}
+
+	walker := &jsonStreamWalker{
+		sanitizer:          sanitizer,
+		ctx:                ctx,
+		replacementContext: replacementContext,
+		fileName:           inputFileName,
+		rules:              compiledRuleSet.Rules,
+		tokenReader:        newJsonTokenReader(ctx, reader),
+		tokenWriter:        tokenWriter,
+		findingHandler:     findingHandler,
+		matchedRuleKeys:    map[string]bool{},
+		failedRuleKeys:     map[string]bool{},
+		// The rules that failed to compile are reported along with the errors of the applied rules.
+		errs: slices.Clone(compiledRuleSet.Errors),
+	}
+	rootStates := make([]streamRuleState, 0, len(compiledRuleSet.Rules))
+	for ruleIndex := range compiledRuleSet.Rules {
+		rootStates = append(rootStates, streamRuleState{RuleIndex: ruleIndex})
+	}
+
+	if err = walker.walkRoot(rootStates); err != nil {
+		invalidInputErr := &InvalidInputError{}
+		if errors.As(err, &invalidInputErr) {
+			invalidInputErr.FileName = inputFileName
+		}
+		sanitizer.logError(err)
+		return walker.report, err
+	}
+	for _, rule := range walker.rules {
+		if !walker.matchedRuleKeys[rule.Key] && !walker.failedRuleKeys[rule.Key] {
+			walker.report.UnmatchedRules = append(walker.report.UnmatchedRules, UnmatchedRule{FileName: inputFileName, RuleKey: rule.Key})
+		}
+	}
+	// The findings are sorted once, as sorting them after each value is slow for large inputs.
+	walker.report.Merge(Report{})
+	return walker.report, newPartialSanitizationError(walker.errs)
+}
+
+// next returns the next token, and an InvalidInputError if it isn't one of the expected kinds.
+func (walker *jsonStreamWalker) next(expectedKinds string) (jsonToken, error) {
+	token, err := walker.tokenReader.next()
+	if err != nil || strings.ContainsRune(expectedKinds, rune(token.Kind)) {
+		return token, err
+	}
+	if token.Kind == jsonTokenKindEOF {
+		return token, &InvalidInputError{Err: fmt.Errorf("unexpected end of content at offset %d", token.Offset)}
+	}
+	// The strings and literals aren't quoted in the error, as they can be secrets.
+	unexpectedToken := string(token.Raw)
+	if token.Kind == jsonTokenKindString {
+		unexpectedToken = "string"
+	} else if token.Kind == jsonTokenKindLiteral {
+		unexpectedToken = "literal"
+	}
+	return token, &InvalidInputError{Err: fmt.Errorf("unexpected %s at offset %d", unexpectedToken, token.Offset)}
+}
+
+// valueStartKinds are the kinds of tokens a value starts with.
+const valueStartKinds = "{[\"l"
+
+func (walker *jsonStreamWalker) walkRoot(rootStates []streamRuleState) error {
+	token, err := walker.next(valueStartKinds)
+	if err != nil {
+		return err
+	}
+	text, _, isStreamed, err := walker.resolveValue("$", rootStates, token)
+	if err != nil {
+		return err
+	}
+	if err = walker.writeValue(token.Whitespace, token, text, isStreamed, "$", rootStates); err != nil {
+		return err
+	}
+	token, err = walker.next(string(rune(jsonTokenKindEOF)))
+	if err != nil {
+		return err
+	}
+	return walker.tokenWriter.writeTrailingWhitespace(token.Whitespace)
+}
+
+// writeValue writes the value starting with the token, preceded by the whitespace. The value is either streamed, or
+// written as the text resolved by resolveValue.
+func (walker *jsonStreamWalker) writeValue(whitespace []byte, token jsonToken, text []byte, isStreamed bool, jsonPath string, states []streamRuleState) error {
+	if !isStreamed {
+		kind := token.Kind
+		if kind == '{' || kind == '[' {
+			kind = jsonTokenKindValue
+		}
+		return walker.tokenWriter.write(kind, whitespace, text)
+	}
+	if err := walker.tokenWriter.write(token.Kind, whitespace, token.Raw); err != nil {
+		return err
+	}
+	return walker.walkContainer(jsonPath, states, token)
+}
+
+// walkContainer walks the members of the object or array after its opening bracket, writing them and its closing
+// bracket. The separators of deleted members are removed like setJsonValues.
+func (walker *jsonStreamWalker) walkContainer(jsonPath string, states []streamRuleState, openToken jsonToken) error {
+	isObject := openToken.Kind == '{'
+	closeKind := byte(']')
+	firstMemberKinds := "]" + valueStartKinds
+	if isObject {
+		closeKind = '}'
+		firstMemberKinds = "}\""
+	}
+	token, err := walker.next(firstMemberKinds)
+	if err != nil {
+		return err
+	}
+	if token.Kind == closeKind {
+		return walker.tokenWriter.write(closeKind, token.Whitespace, token.Raw)
+	}
+
+	// pendingWhitespace is written before the next member that isn't deleted, and pendingComma (the comma after the last
+	// member that isn't deleted) before the ones after it.
+	pendingWhitespace := token.Whitespace
+	var pendingComma *jsonToken = nil
+	hasKeptMembers := false
+	for index := 0; ; index++ {
+		segment := strconv.Itoa(index)
+		var keyToken, colonToken jsonToken
+		if isObject {
+			keyToken = token
+			if err = json.Unmarshal(keyToken.Raw, &segment); err != nil {
+				return &InvalidInputError{Err: err}
+			}
+			if colonToken, err = walker.next(":"); err != nil {
+				return err
+			}
+			if token, err = walker.next(valueStartKinds); err != nil {
+				return err
+			}
+		}
+		childJsonPath := appendJsonPathSegment(jsonPath, segment)
+		childStates := walker.getChildStates(states, segment)
+		valueToken := token
+		text, isDeleted, isStreamed, err := walker.resolveValue(childJsonPath, childStates, valueToken)
+		if err != nil {
+			return err
+		}
+		if !isDeleted {
+			if pendingComma != nil {
+				if err = walker.tokenWriter.write(',', pendingComma.Whitespace, pendingComma.Raw); err != nil {
+					return err
+				}
+			}
+			valueWhitespace := valueToken.Whitespace
+			if isObject {
+				if err = walker.tokenWriter.write(jsonTokenKindString, pendingWhitespace, keyToken.Raw); err != nil {
+					return err
+				}
+				if err = walker.tokenWriter.write(':', colonToken.Whitespace, colonToken.Raw); err != nil {
+					return err
+				}
+			} else {
+				valueWhitespace = pendingWhitespace
+			}
+			if err = walker.writeValue(valueWhitespace, valueToken, text, isStreamed, childJsonPath, childStates); err != nil {
+				return err
+			}
+			hasKeptMembers = true
+		}
+
+		token, err = walker.next(string([]byte{',', closeKind}))
+		if err != nil {
+			return err
+		}
+		if token.Kind == closeKind {
+			if !hasKeptMembers {
+				// All the members are deleted.
+				return walker.tokenWriter.write(closeKind, nil, token.Raw)
+			}
+			return walker.tokenWriter.write(closeKind, token.Whitespace, token.Raw)
+		}
+		commaToken := token
+		nextMemberKinds := valueStartKinds
+		if isObject {
+			nextMemberKinds = "\""
+		}
+		if token, err = walker.next(nextMemberKinds); err != nil {
+			return err
+		}
+		if !isDeleted {
+			pendingComma = &commaToken
+			pendingWhitespace = token.Whitespace
+		}
+	}
+}
+
+// getChildStates returns the states of the rules at the child with the key (or array index) of a value in the states.
+func (walker *jsonStreamWalker) getChildStates(states []streamRuleState, key string) []streamRuleState {
+	childStates := make([]streamRuleState, 0, len(states))
+	nextStates := make([]int, 0, 2)
+	for _, state := range states {
+		rule := walker.rules[state.RuleIndex]
+		if state.State == streamStateActive || walker.isActivated(rule, state.State) {
+			childStates = appendStreamRuleState(childStates, streamRuleState{RuleIndex: state.RuleIndex, State: streamStateActive})
+			continue
+		}
+		for _, nextState := range rule.pathMatcher.pattern.advance(state.State, key, nextStates[:0]) {
+			childStates = appendStreamRuleState(childStates, streamRuleState{RuleIndex: state.RuleIndex, State: nextState})
+		}
+	}
+	return childStates
+}
+
+// isActivated checks if the rule in the state applies to every value within the value: it's a pattern rule whose scope
+// matched the value, or a recursive rule that matched it.
+func (walker *jsonStreamWalker) isActivated(rule *CompiledRule, state int) bool {
+	return walker.isFullMatch(rule, state) && (rule.pattern != nil || rule.Info.Recursive)
+}
+
+// isFullMatch checks if the rule's path pattern matched the value in the state.
+func (walker *jsonStreamWalker) isFullMatch(rule *CompiledRule, state int) bool {
+	return state == len(rule.pathMatcher.pattern.Segments) && rule.pathMatcher.pattern.Remainder == ""
+}
+
+// appendStreamRuleState appends the state to the states, unless it's already present. A rule that's active isn't
+// matched any further, as it already applies to every value.
+func appendStreamRuleState(states []streamRuleState, state streamRuleState) []streamRuleState {
+	for index := len(states) - 1; index >= 0 && states[index].RuleIndex == state.RuleIndex; index-- {
+		if states[index].State == state.State || states[index].State == streamStateActive {
+			return states
+		}
+		if state.State == streamStateActive {
+			states = states[:index]
+		}
+	}
+	return append(states, state)
+}
+
+// resolveValue resolves the value starting with the token: it's either streamed (for objects and arrays that don't
+// have to be evaluated as a whole), deleted, or replaced with the returned text (its sanitized text).
+func (walker *jsonStreamWalker) resolveValue(jsonPath string, states []streamRuleState, token jsonToken) ([]byte, bool, bool, error) {
+	isContainer := token.Kind == '{' || token.Kind == '['
+	isBuffered := false
+	for _, state := range states {
+		if state.State == streamStateActive {
+			continue
+		}
+		rule := walker.rules[state.RuleIndex]
+		if state.State == len(rule.pathMatcher.pattern.Segments) && rule.pathMatcher.pattern.Remainder != "" {
+			isBuffered = true
+		} else if isContainer && walker.isFullMatch(rule, state.State) && !walker.isActivated(rule, state.State) {
+			isBuffered = true
+		}
+	}
+	if isContainer && !isBuffered {
+		return nil, false, true, nil
+	}
+	raw := token.Raw
+	if isContainer {
+		var err error
+		if raw, err = walker.readValue(token); err != nil {
+			return nil, false, false, err
+		}
+	}
+	if isBuffered {
+		text, isDeleted, err := walker.sanitizeBufferedValue(jsonPath, states, raw)
+		return text, isDeleted, false, err
+	}
+	text, isDeleted := walker.sanitizeScalar(jsonPath, states, token)
+	return text, isDeleted, false, nil
+}
+
+// readValue reads the rest of the object or array starting with the token, returning its text.
+func (walker *jsonStreamWalker) readValue(token jsonToken) ([]byte, error) {
+	raw := bytes.Clone(token.Raw)
+	depth := 1
+	for depth > 0 {
+		token, err := walker.next("{}[]:,\"l")
+		if err != nil {
+			return nil, err
+		}
+		switch token.Kind {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		}
+		raw = append(raw, token.Whitespace...)
+		raw = append(raw, token.Raw...)
+	}
+	return raw, nil
+}
+
+// sanitizeBufferedValue evaluates the rest of the JSON paths of the rules in the states against the value, and returns
+// its sanitized text, or whether it's deleted.
+func (walker *jsonStreamWalker) sanitizeBufferedValue(jsonPath string, states []streamRuleState, raw []byte) ([]byte, bool, error) {
+	walker.sanitizer.getLogger().Debug("Evaluating the rules against a buffered value.", "jsonPath", jsonPath)
+	document, err := parseJsonDocument(string(raw))
+	if err != nil {
+		return nil, false, &InvalidInputError{Err: fmt.Errorf("invalid value at %s: %w", jsonPath, err)}
+	}
+	ruleDetectionTaskOutputs := make([]ruleDetectionTaskOutput, 0, len(states))
+	for _, state := range states {
+		rule := walker.rules[state.RuleIndex]
+		output, err := walker.sanitizer.runRuleDetectionTask(walker.ctx, ruleDetectionTaskInput{
+			Document:           document,
+			Rule:               rule,
+			PathState:          state.State,
+			ReplacementContext: walker.replacementContext,
+			FileName:           walker.fileName,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		ruleDetectionTaskOutputs = append(ruleDetectionTaskOutputs, output)
+	}
+	text, isDeleted := walker.applyReplacements(jsonPath, raw, ruleDetectionTaskOutputs)
+	return text, isDeleted, nil
+}
+
+// sanitizeScalar applies the rules in the states that match the string, number, boolean or null in the token, and
+// returns its sanitized text, or whether it's deleted.
+func (walker *jsonStreamWalker) sanitizeScalar(jsonPath string, states []streamRuleState, token jsonToken) ([]byte, bool) {
+	var document *jsonDocument = nil
+	ruleDetectionTaskOutputs := make([]ruleDetectionTaskOutput, 0)
+	lastRuleIndex := -1
+	for _, state := range states {
+		rule := walker.rules[state.RuleIndex]
+		if state.RuleIndex == lastRuleIndex || (state.State != streamStateActive && !walker.isFullMatch(rule, state.State)) {
+			continue
+		}
+		if rule.pattern != nil && token.Kind != jsonTokenKindString {
+			continue
+		}
+		if document == nil {
+			var err error
+			if document, err = parseJsonDocument(string(token.Raw)); err != nil {
+				// The token is already validated.
+				return token.Raw, false
+			}
+		}
+		value := document.tree
+		if (rule.pattern != nil && !rule.pattern.MatchString(value.(string))) || (rule.Info.Recursive && value == nil) {
+			continue
+		}
+		lastRuleIndex = state.RuleIndex
+		output := ruleDetectionTaskOutput{RuleKey: rule.Key, IsMatched: true, Replacements: map[string]jsonValueReplacement{}}
+		replacement, isReplaced, err := walker.sanitizer.getValueReplacement(walker.replacementContext, document, "$", value, rule.Key, rule.Info, rule.pattern)
+		if err != nil {
+			output.Errors = append(output.Errors, &RuleError{RuleKey: rule.Key, JsonPath: "$", Err: err})
+		} else if isReplaced {
+			output.Replacements["$"] = replacement
+			output.Findings = append(output.Findings, newFinding(walker.replacementContext, walker.fileName, "$", getFindingValueText(document, "$", value), rule.Key, rule.Info, replacement))
+		}
+		ruleDetectionTaskOutputs = append(ruleDetectionTaskOutputs, output)
+	}
+	if len(ruleDetectionTaskOutputs) == 0 {
+		return token.Raw, false
+	}
+	return walker.applyReplacements(jsonPath, token.Raw, ruleDetectionTaskOutputs)
+}
+
+// applyReplacements merges the replacements of the rules in the value at the JSON path like sanitize, recording the
+// findings, conflicts and errors with the JSON paths relative to the value rebased on its JSON path. It returns the
+// sanitized text of the value, or whether it's deleted.
+func (walker *jsonStreamWalker) applyReplacements(jsonPath string, raw []byte, ruleDetectionTaskOutputs []ruleDetectionTaskOutput) ([]byte, bool) {
+	for _, output := range ruleDetectionTaskOutputs {
+		if output.IsMatched {
+			walker.matchedRuleKeys[output.RuleKey] = true
+		}
+		if len(output.Errors) > 0 {
+			walker.failedRuleKeys[output.RuleKey] = true
+		}
+		for _, err := range output.Errors {
+			err = rebaseErrorJsonPaths(err, jsonPath)
+			walker.sanitizer.logError(err)
+			walker.errs = append(walker.errs, err)
+		}
+	}
+	replacementMap, report := mergeRuleReplacements(ruleDetectionTaskOutputs, walker.fileName)
+	for _, finding := range report.Findings {
+		finding.JsonPath = rebaseJsonPath(finding.JsonPath, jsonPath)
+		if walker.findingHandler != nil {
+			walker.findingHandler(finding)
+		} else {
+			walker.report.Findings = append(walker.report.Findings, finding)
+		}
+	}
+	for _, conflict := range report.Conflicts {
+		conflict.JsonPath = rebaseJsonPath(conflict.JsonPath, jsonPath)
+		walker.report.Conflicts = append(walker.report.Conflicts, conflict)
+	}
+	if len(replacementMap) == 0 {
+		return raw, false
+	}
+	if replacementMap["$"].Delete && jsonPath != "$" {
+		return nil, true
+	}
+	sanitizedText, err := setJsonValues(string(raw), replacementMap)
+	if err != nil {
+		err = rebaseErrorJsonPaths(err, jsonPath)
+		walker.sanitizer.logError(err)
+		walker.errs = append(walker.errs, err)
+	}
+	return []byte(sanitizedText), false
+}
+
+// rebaseJsonPath returns the JSON path of a value within the value at the base JSON path, relative to the content.
+func rebaseJsonPath(relativeJsonPath string, baseJsonPath string) string {
+	return baseJsonPath + strings.TrimPrefix(relativeJsonPath, "$")
+}
+
+// rebaseErrorJsonPaths rebases the JSON paths of the RuleError and PathWriteError (or the errors joined in it) on the
+// base JSON path.
+func rebaseErrorJsonPaths(err error, baseJsonPath string) error {
+	var ruleErr *RuleError
+	var pathWriteErr *PathWriteError
+	if joinedErr, isJoined := err.(interface{ Unwrap() []error }); isJoined {
+		errs := make([]error, 0)
+		for _, wrappedErr := range joinedErr.Unwrap() {
+			errs = append(errs, rebaseErrorJsonPaths(wrappedErr, baseJsonPath))
+		}
+		return errors.Join(errs...)
+	} else if errors.As(err, &ruleErr) {
+		return &RuleError{RuleKey: ruleErr.RuleKey, JsonPath: rebaseJsonPath(ruleErr.JsonPath, baseJsonPath), Err: ruleErr.Err}
+	} else if errors.As(err, &pathWriteErr) {
+		return &PathWriteError{JsonPath: rebaseJsonPath(pathWriteErr.JsonPath, baseJsonPath), Err: pathWriteErr.Err}
+	}
+	return err
+}
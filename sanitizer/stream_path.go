@@ -0,0 +1,277 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"github.com/PaesslerAG/gval"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// streamPathSegment is a segment of a JSON path that can be matched while streaming, without the value it selects
+// from.
+type streamPathSegment struct {
+	// Key is the member key (or array index) selected by the segment. Ignored if IsWildcard is set.
+	Key        string
+	IsWildcard bool
+	// IsDescendant matches the segment at any depth below the previous segment (i.e. the .. operator).
+	IsDescendant bool
+	// Text is the segment in the JSON path.
+	Text string
+}
+
+// streamPathPattern is a JSON path split into the segments matched while streaming, and the rest of it.
+type streamPathPattern struct {
+	Segments []streamPathSegment
+	// Remainder is the rest of the JSON path from its first segment that can't be matched while streaming
+	// (Ex: a filter). It's evaluated against the values matched by the Segments.
+	Remainder string
+}
+
+// parseStreamPathPattern splits the JSON path into the segments that can be matched while streaming: the child
+// (.name, ["name"], [0]), wildcard (.*, [*]) and descendant (..name, ..["name"], ..*) segments.
+// The rest of the JSON path from the first other segment is the pattern's Remainder.
+func parseStreamPathPattern(jsonPath string) streamPathPattern {
+	pattern := streamPathPattern{}
+	if !strings.HasPrefix(jsonPath, "$") {
+		pattern.Remainder = jsonPath
+		return pattern
+	}
+	remainingPath := jsonPath[1:]
+	for remainingPath != "" {
+		segment, length := parseStreamPathSegment(remainingPath)
+		if length == 0 {
+			pattern.Remainder = remainingPath
+			break
+		}
+		segment.Text = remainingPath[:length]
+		pattern.Segments = append(pattern.Segments, segment)
+		remainingPath = remainingPath[length:]
+	}
+	return pattern
+}
+
+// parseStreamPathSegment parses the segment at the start of the JSON path, returning its length, which is 0 if it
+// can't be matched while streaming.
+func parseStreamPathSegment(jsonPath string) (streamPathSegment, int) {
+	segment := streamPathSegment{}
+	offset := 0
+	if strings.HasPrefix(jsonPath, "..") {
+		segment.IsDescendant = true
+		offset = 2
+	} else if strings.HasPrefix(jsonPath, ".") {
+		offset = 1
+	}
+	remainingPath := jsonPath[offset:]
+	if strings.HasPrefix(remainingPath, "[") {
+		// A [ can't follow a single dot.
+		if offset == 1 {
+			return segment, 0
+		}
+		length := parseStreamPathBracket(remainingPath, &segment)
+		if length == 0 {
+			return segment, 0
+		}
+		return segment, offset + length
+	}
+	if offset == 0 {
+		return segment, 0
+	}
+	if strings.HasPrefix(remainingPath, "*") {
+		segment.IsWildcard = true
+		return segment, offset + 1
+	}
+	length := strings.IndexFunc(remainingPath, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if length < 0 {
+		length = len(remainingPath)
+	}
+	if length == 0 {
+		return segment, 0
+	}
+	segment.Key = remainingPath[:length]
+	return segment, offset + length
+}
+
+// parseStreamPathBracket parses the bracket segment at the start of the JSON path into the segment, returning its
+// length, which is 0 if it isn't a wildcard, a single key or a non-negative index.
+func parseStreamPathBracket(jsonPath string, segment *streamPathSegment) int {
+	endIndex := 0
+	switch {
+	case strings.HasPrefix(jsonPath, "[*]"):
+		segment.IsWildcard = true
+		return 3
+	case strings.HasPrefix(jsonPath, "[\""):
+		// Find the closing quote, skipping escaped characters.
+		endIndex = 2
+		for endIndex < len(jsonPath) && jsonPath[endIndex] != '"' {
+			if jsonPath[endIndex] == '\\' {
+				endIndex++
+			}
+			endIndex++
+		}
+		key, err := strconv.Unquote(jsonPath[1:min(endIndex+1, len(jsonPath))])
+		if err != nil {
+			return 0
+		}
+		segment.Key = key
+		endIndex++
+	case strings.HasPrefix(jsonPath, "['"):
+		// Single quoted keys with escaped characters are left to the JSON path library.
+		endIndex = strings.IndexAny(jsonPath[2:], "'\\") + 2
+		if endIndex < 2 || jsonPath[endIndex] != '\'' {
+			return 0
+		}
+		segment.Key = jsonPath[2:endIndex]
+		endIndex++
+	default:
+		endIndex = 1
+		for endIndex < len(jsonPath) && jsonPath[endIndex] >= '0' && jsonPath[endIndex] <= '9' {
+			endIndex++
+		}
+		if endIndex == 1 {
+			return 0
+		}
+		segment.Key = strings.TrimLeft(jsonPath[1:endIndex], "0")
+		if segment.Key == "" {
+			segment.Key = "0"
+		}
+	}
+	if endIndex >= len(jsonPath) || jsonPath[endIndex] != ']' {
+		return 0
+	}
+	return endIndex + 1
+}
+
+// advance appends the states of the pattern after matching the child with the key (or array index) from the state,
+// to the states. The state is the index of the next segment to match.
+func (pattern streamPathPattern) advance(state int, key string, states []int) []int {
+	if state >= len(pattern.Segments) {
+		return states
+	}
+	segment := pattern.Segments[state]
+	if segment.IsDescendant {
+		states = appendStreamState(states, state)
+	}
+	if segment.IsWildcard || segment.Key == key {
+		states = appendStreamState(states, state+1)
+	}
+	return states
+}
+
+// appendStreamState appends the state to the states, unless it's already present.
+func appendStreamState(states []int, state int) []int {
+	for _, existingState := range states {
+		if existingState == state {
+			return states
+		}
+	}
+	return append(states, state)
+}
+
+// jsonPathMatcher matches a JSON path against a tree. The segments of its pattern are matched by walking the tree like
+// Sanitizer.SanitizeStream, and the pattern's Remainder is evaluated against the values they reach by the JSON path
+// library, one descendant (..) segment at a time, as the library returns wrong paths for the values matched by
+// multiple descendant segments (Ex: $..b..d).
+type jsonPathMatcher struct {
+	pattern streamPathPattern
+	// remainder is the pattern's Remainder up to its next descendant segment, or nil if there's no Remainder.
+	remainder gval.Evaluable
+	// next matches the rest of the Remainder, from its next descendant segment, against the values selected by the
+	// remainder. It's nil if there's none.
+	next *jsonPathMatcher
+}
+
+// compileJsonPathMatcher compiles the matcher of the JSON path, which is compiled to the evaluable. If the JSON path
+// can't be split, the evaluable is its remainder, so the JSON path is evaluated as a whole.
+func compileJsonPathMatcher(jsonPath string, evaluable gval.Evaluable) *jsonPathMatcher {
+	matcher := &jsonPathMatcher{pattern: parseStreamPathPattern(jsonPath)}
+	if matcher.pattern.Remainder == "" {
+		return matcher
+	}
+	remainder, nextJsonPath := splitJsonPathRemainder(matcher.pattern.Remainder)
+	var err error
+	if matcher.remainder, err = compileJsonPath("$" + remainder); err == nil && nextJsonPath != "" {
+		var nextEvaluable gval.Evaluable
+		if nextEvaluable, err = compileJsonPath("$" + nextJsonPath); err == nil {
+			matcher.next = compileJsonPathMatcher("$"+nextJsonPath, nextEvaluable)
+		}
+	}
+	if err != nil {
+		return &jsonPathMatcher{pattern: streamPathPattern{Remainder: jsonPath}, remainder: evaluable}
+	}
+	return matcher
+}
+
+// splitJsonPathRemainder splits the remainder of a JSON path at its next descendant (..) segment, after the one it
+// starts with, if any. The dots in quoted keys, brackets and parentheses (Ex: filters) aren't segments.
+func splitJsonPathRemainder(remainder string) (string, string) {
+	depth := 0
+	var quote byte = 0
+	for index := 0; index < len(remainder); index++ {
+		character := remainder[index]
+		switch {
+		case quote != 0:
+			if character == '\\' {
+				index++
+			} else if character == quote {
+				quote = 0
+			}
+		case character == '"' || character == '\'':
+			quote = character
+		case character == '[' || character == '(':
+			depth++
+		case character == ']' || character == ')':
+			depth--
+		case depth == 0 && index > 0 && strings.HasPrefix(remainder[index:], ".."):
+			return remainder[:index], remainder[index:]
+		}
+	}
+	return remainder, ""
+}
+
+// collectValues collects the values selected by the JSON path within the value at the JSON path, which is reached in
+// the states of the pattern. It returns the evaluation errors of the remainder appended to the errors.
+func (matcher *jsonPathMatcher) collectValues(jsonPath string, value interface{}, states []int, values map[string]interface{}, errs []error) []error {
+	for _, state := range states {
+		if state < len(matcher.pattern.Segments) {
+			continue
+		} else if matcher.remainder == nil {
+			values[jsonPath] = value
+			continue
+		}
+		remainderValues, err := findValues(matcher.remainder, value)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for remainderJsonPath, remainderValue := range remainderValues {
+			remainderJsonPath = rebaseJsonPath(remainderJsonPath, jsonPath)
+			if matcher.next == nil {
+				values[remainderJsonPath] = remainderValue
+			} else {
+				errs = matcher.next.collectValues(remainderJsonPath, remainderValue, []int{0}, values, errs)
+			}
+		}
+	}
+	collectChild := func(segment string, childValue interface{}) {
+		childStates := make([]int, 0, len(states))
+		for _, state := range states {
+			childStates = matcher.pattern.advance(state, segment, childStates)
+		}
+		if len(childStates) > 0 {
+			errs = matcher.collectValues(appendJsonPathSegment(jsonPath, segment), childValue, childStates, values, errs)
+		}
+	}
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, childValue := range typedValue {
+			collectChild(key, childValue)
+		}
+	case []interface{}:
+		for index, childValue := range typedValue {
+			collectChild(strconv.Itoa(index), childValue)
+		}
+	}
+	return errs
+}
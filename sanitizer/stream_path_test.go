@@ -0,0 +1,34 @@
+package sanitizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStreamPathPattern(t *testing.T) {
+	pattern := parseStreamPathPattern(`$["log"].entries[*]..['headers'][0][?(@.name == "Cookie")].value`)
+	assert.Equal(t, []streamPathSegment{
+		{Key: "log", Text: `["log"]`},
+		{Key: "entries", Text: ".entries"},
+		{IsWildcard: true, Text: "[*]"},
+		{Key: "headers", IsDescendant: true, Text: "..['headers']"},
+		{Key: "0", Text: "[0]"},
+	}, pattern.Segments)
+	assert.Equal(t, `[?(@.name == "Cookie")].value`, pattern.Remainder)
+
+	assert.Empty(t, parseStreamPathPattern("$").Segments)
+	assert.Equal(t, streamPathPattern{Remainder: `[1:3]`}, parseStreamPathPattern(`$[1:3]`))
+	assert.Equal(t, streamPathPattern{Remainder: `["a","b"]`}, parseStreamPathPattern(`$["a","b"]`))
+	assert.Equal(t, streamPathPattern{Remainder: `['a\'b']`}, parseStreamPathPattern(`$['a\'b']`))
+	assert.Equal(t, []streamPathSegment{{IsWildcard: true, IsDescendant: true, Text: "..*"}}, parseStreamPathPattern(`$..*`).Segments)
+}
+
+func TestStreamPathPatternAdvance(t *testing.T) {
+	pattern := parseStreamPathPattern(`$..["a"]["b"]`)
+	assert.Equal(t, []int{0}, pattern.advance(0, "x", nil))
+	assert.Equal(t, []int{0, 1}, pattern.advance(0, "a", nil))
+	assert.Equal(t, []int{2}, pattern.advance(1, "b", nil))
+	assert.Empty(t, pattern.advance(1, "c", nil))
+	assert.Empty(t, pattern.advance(2, "b", nil))
+}
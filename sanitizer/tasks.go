@@ -0,0 +1,126 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type ruleDetectionTaskInput struct {
+	// Document is the parsed content, shared by the rules.
+	Document document
+	Rule     *CompiledRule
+	// PathState is the state of the rule's pathPattern the Document is reached in, when it's a value within the content
+	// (see Sanitizer.SanitizeStream), or streamStateActive if it's within a value the rule matched. It's 0 for the whole
+	// content.
+	PathState          int
+	ReplacementContext *ReplacementContext
+	// FileName is the name of the file the content is from, used in the findings.
+	FileName string
+}
+
+type ruleDetectionTaskOutput struct {
+	RuleKey string
+	// IsMatched is set if the rule matched any values, including the ones that are already sanitized.
+	IsMatched bool
+	// Replacements are keyed by the JSON paths of the values.
+	Replacements map[string]jsonValueReplacement
+	Findings     []Finding
+	Errors       []error
+}
+
+// TaskOptions configures how RunTasks runs the tasks.
+type TaskOptions struct {
+	// Concurrency is the maximum number of tasks run at a time. Defaults to runtime.GOMAXPROCS(0).
+	Concurrency int
+	// Timeout is the maximum duration of each task. There's no timeout if it's zero.
+	Timeout time.Duration
+}
+
+// TaskResult is the result of running a task with RunTasks.
+type TaskResult[O any] struct {
+	Output O
+	// Err is the error returned by the task. If the task panicked, it's the error of the panic. If the task didn't
+	// complete before the context was done or its timeout, it's the context's error.
+	Err error
+}
+
+// RunTasks is a generic method to run tasks in parallel, with at most TaskOptions.Concurrency tasks at a time.
+// It returns the results of the tasks in the order of their inputs.
+//
+// Once the context is done, the pending tasks aren't started, and the results of the running tasks have the context's
+// error. The context passed to a task is done when the task times out, so long-running tasks should return early once
+// it's done. A task that's done keeps its place until it returns, so no more than TaskOptions.Concurrency tasks run at a
+// time, even if they time out.
+func RunTasks[I any, O any](ctx context.Context, task func(context.Context, I) (O, error), taskInputs []I, options TaskOptions) []TaskResult[O] {
+	taskResults := make([]TaskResult[O], len(taskInputs))
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	concurrency = min(concurrency, len(taskInputs))
+
+	taskIndexes := make(chan int, len(taskInputs))
+	for taskIndex := range taskInputs {
+		taskIndexes <- taskIndex
+	}
+	close(taskIndexes)
+	waitGroup := sync.WaitGroup{}
+	for range concurrency {
+		// We add 1 to the wait group. Each worker will decrease it by 1 once there are no more tasks.
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for taskIndex := range taskIndexes {
+				if err := ctx.Err(); err != nil {
+					taskResults[taskIndex] = TaskResult[O]{Err: err}
+					continue
+				}
+				taskResults[taskIndex] = runTask(ctx, task, taskInputs[taskIndex], options.Timeout)
+			}
+		}()
+	}
+	// Now we wait for all workers to finish.
+	waitGroup.Wait()
+	return taskResults
+}
+
+// runTask runs the task in a goroutine, and returns its result, or the context's error if the context is done before
+// the task completes. It returns once the task returns, after cancelling its context.
+func runTask[I any, O any](ctx context.Context, task func(context.Context, I) (O, error), taskInput I, timeout time.Duration) TaskResult[O] {
+	taskCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		taskCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	taskResultChannel := make(chan TaskResult[O], 1)
+	go func() {
+		defer func() {
+			// Report panics as errors instead of crashing (the WASM runtime doesn't recover from panics).
+			if recovered := recover(); recovered != nil {
+				taskResultChannel <- TaskResult[O]{Err: fmt.Errorf("unexpected error: %v", recovered)}
+			}
+		}()
+		output, err := task(taskCtx, taskInput)
+		taskResultChannel <- TaskResult[O]{Output: output, Err: err}
+	}()
+	select {
+	case taskResult := <-taskResultChannel:
+		return taskResult
+	case <-taskCtx.Done():
+		select {
+		case taskResult := <-taskResultChannel:
+			// The task completed along with the context.
+			return taskResult
+		default:
+			err := taskCtx.Err()
+			cancel()
+			// The task isn't abandoned, so it doesn't run along with the next tasks.
+			<-taskResultChannel
+			return TaskResult[O]{Err: err}
+		}
+	}
+}
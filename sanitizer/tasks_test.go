@@ -0,0 +1,129 @@
+package sanitizer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTasksReturnsResultsInInputOrder(t *testing.T) {
+	taskInputs := []int{5, 1, 4, 2, 3}
+	taskResults := RunTasks(context.Background(), func(_ context.Context, taskInput int) (int, error) {
+		// Later inputs complete first.
+		time.Sleep(time.Duration(taskInput) * time.Millisecond)
+		if taskInput == 4 {
+			return 0, errors.New("error 4")
+		}
+		return taskInput * 10, nil
+	}, taskInputs, TaskOptions{})
+	require.Len(t, taskResults, len(taskInputs))
+	assert.Equal(t, TaskResult[int]{Output: 50}, taskResults[0])
+	assert.Equal(t, TaskResult[int]{Output: 10}, taskResults[1])
+	assert.EqualError(t, taskResults[2].Err, "error 4")
+	assert.Equal(t, TaskResult[int]{Output: 20}, taskResults[3])
+	assert.Equal(t, TaskResult[int]{Output: 30}, taskResults[4])
+
+	assert.Empty(t, RunTasks(context.Background(), func(_ context.Context, taskInput int) (int, error) {
+		return taskInput, nil
+	}, []int{}, TaskOptions{}))
+}
+
+func TestRunTasksLimitsConcurrency(t *testing.T) {
+	runningTasks := atomic.Int32{}
+	maxRunningTasks := atomic.Int32{}
+	taskResults := RunTasks(context.Background(), func(_ context.Context, taskInput int) (int, error) {
+		running := runningTasks.Add(1)
+		defer runningTasks.Add(-1)
+		for {
+			maximum := maxRunningTasks.Load()
+			if running <= maximum || maxRunningTasks.CompareAndSwap(maximum, running) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return taskInput, nil
+	}, make([]int, 12), TaskOptions{Concurrency: 3})
+	assert.Len(t, taskResults, 12)
+	assert.LessOrEqual(t, maxRunningTasks.Load(), int32(3))
+	assert.Positive(t, maxRunningTasks.Load())
+}
+
+func TestRunTasksRecoversPanics(t *testing.T) {
+	taskResults := RunTasks(context.Background(), func(_ context.Context, taskInput string) (string, error) {
+		if taskInput == "panic" {
+			panic("unexpected value")
+		}
+		return taskInput, nil
+	}, []string{"a", "panic"}, TaskOptions{})
+	assert.Equal(t, TaskResult[string]{Output: "a"}, taskResults[0])
+	assert.EqualError(t, taskResults[1].Err, "unexpected error: unexpected value")
+}
+
+func TestRunTasksCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	startedTasks := atomic.Int32{}
+	taskResults := RunTasks(ctx, func(ctx context.Context, taskInput int) (int, error) {
+		startedTasks.Add(1)
+		if taskInput == 0 {
+			cancel()
+		}
+		<-ctx.Done()
+		return taskInput, nil
+	}, make([]int, 5), TaskOptions{Concurrency: 1})
+	assert.Equal(t, int32(1), startedTasks.Load())
+	for _, taskResult := range taskResults[1:] {
+		assert.ErrorIs(t, taskResult.Err, context.Canceled)
+	}
+
+	// Tasks that don't return in time get the context's error.
+	timedTaskResults := RunTasks(context.Background(), func(ctx context.Context, taskInput time.Duration) (time.Duration, error) {
+		select {
+		case <-time.After(taskInput):
+		case <-ctx.Done():
+		}
+		return taskInput, nil
+	}, []time.Duration{0, time.Second}, TaskOptions{Timeout: 20 * time.Millisecond})
+	assert.NoError(t, timedTaskResults[0].Err)
+	assert.ErrorIs(t, timedTaskResults[1].Err, context.DeadlineExceeded)
+}
+
+func TestRunTasksLimitsConcurrencyOnTimeouts(t *testing.T) {
+	runningTasks := atomic.Int32{}
+	maxRunningTasks := atomic.Int32{}
+	taskResults := RunTasks(context.Background(), func(_ context.Context, taskInput int) (int, error) {
+		running := runningTasks.Add(1)
+		defer runningTasks.Add(-1)
+		for {
+			maximum := maxRunningTasks.Load()
+			if running <= maximum || maxRunningTasks.CompareAndSwap(maximum, running) {
+				break
+			}
+		}
+		// The task outlives its timeout, as it doesn't check its context.
+		time.Sleep(20 * time.Millisecond)
+		return taskInput, nil
+	}, make([]int, 8), TaskOptions{Concurrency: 2, Timeout: time.Millisecond})
+	for _, taskResult := range taskResults {
+		assert.ErrorIs(t, taskResult.Err, context.DeadlineExceeded)
+	}
+	assert.Equal(t, int32(2), maxRunningTasks.Load())
+	assert.Zero(t, runningTasks.Load())
+}
+
+func TestSanitizeContextCancellation(t *testing.T) {
+	sanitizer := newTestSanitizer(map[string]RuleInfo{`$["a"]`: {Action: ActionRemove}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := sanitizer.SanitizeContext(ctx, sanitizer.NewReplacementContext(), `{"a": "x"}`, "json", "a.json", "a_sanitized.json")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, result.Content)
+
+	result, err = sanitizer.SanitizeContext(context.Background(), sanitizer.NewReplacementContext(), `{"a": "x"}`, "json", "a.json", "a_sanitized.json")
+	require.NoError(t, err)
+	assert.Contains(t, result.Content, "<REMOVED>")
+}
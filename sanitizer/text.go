@@ -0,0 +1,221 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// textLine is a line of text content, without its line ending.
+type textLine struct {
+	// start is the offset of the line in the content.
+	start int
+	text  string
+}
+
+// textContent is text content (Ex: logs), which is a single document.
+//
+// The rules are regexes matched against each line, and the values are the substrings of the lines to replace (see
+// getPatternSpans), keyed by their text span paths (see getTextSpanPath). The rest of the content, including the line
+// endings, is left as is.
+type textContent struct {
+	content  string
+	document *textDocument
+}
+
+// textDocument is the lines of text content, which the rules' patterns are matched against.
+type textDocument struct {
+	stringDocument
+	lines []textLine
+}
+
+// parseTextContent splits the text content into its lines.
+func parseTextContent(content string) (*textContent, error) {
+	if !utf8.ValidString(content) {
+		return nil, errors.New("the content isn't valid UTF-8 text")
+	}
+	lines := []textLine{}
+	for start := 0; start < len(content); {
+		end := strings.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start
+		}
+		lines = append(lines, textLine{start: start, text: strings.TrimSuffix(content[start:end], "\r")})
+		start = end + 1
+	}
+	return &textContent{content: content, document: &textDocument{lines: lines}}, nil
+}
+
+// getTextSpanPath returns the path of the span of the line that values are keyed by, with the 1-based columns (in
+// characters) of its first and last characters. Ex: 12:5-40
+func getTextSpanPath(lineNumber int, line string, start int, end int) string {
+	startColumn := utf8.RuneCountInString(line[:start]) + 1
+	endColumn := startColumn + utf8.RuneCountInString(line[start:end]) - 1
+	return strconv.Itoa(lineNumber) + ":" + strconv.Itoa(startColumn) + "-" + strconv.Itoa(endColumn)
+}
+
+// parseTextSpanPath returns the line number and the columns of the span at the text span path, and whether it's a text
+// span path.
+func parseTextSpanPath(path string) (int, int, int, bool) {
+	lineNumberText, columnsText, hasColumns := strings.Cut(path, ":")
+	startColumnText, endColumnText, hasEndColumn := strings.Cut(columnsText, "-")
+	lineNumber, lineNumberErr := strconv.Atoi(lineNumberText)
+	startColumn, startColumnErr := strconv.Atoi(startColumnText)
+	endColumn, endColumnErr := strconv.Atoi(endColumnText)
+	if !hasColumns || !hasEndColumn || lineNumberErr != nil || startColumnErr != nil || endColumnErr != nil {
+		return 0, 0, 0, false
+	}
+	return lineNumber, startColumn, endColumn, true
+}
+
+// isOverlappingTextSpan returns whether the text span paths are different spans sharing characters of the same line.
+func isOverlappingTextSpan(path string, otherPath string) bool {
+	lineNumber, startColumn, endColumn, isTextSpan := parseTextSpanPath(path)
+	otherLineNumber, otherStartColumn, otherEndColumn, isOtherTextSpan := parseTextSpanPath(otherPath)
+	return isTextSpan && isOtherTextSpan && path != otherPath && lineNumber == otherLineNumber &&
+		startColumn <= otherEndColumn && otherStartColumn <= endColumn
+}
+
+// findTextValues returns the substrings of the lines matching the line filter (if any) to replace for the pattern's
+// matches, keyed by their text span paths.
+func findTextValues(lines []textLine, pattern *regexp.Regexp, lineFilter *regexp.Regexp) map[string]interface{} {
+	values := map[string]interface{}{}
+	for index, line := range lines {
+		if lineFilter != nil && !lineFilter.MatchString(line.text) {
+			continue
+		}
+		for _, span := range getPatternSpans(line.text, pattern) {
+			values[getTextSpanPath(index+1, line.text, span[0], span[1])] = line.text[span[0]:span[1]]
+		}
+	}
+	return values
+}
+
+// findValues returns the substrings of the lines to replace for the rule's pattern. They're replaced whole, so there's
+// no pattern to match them against.
+func (document *textDocument) findValues(rule *CompiledRule, _ int) (map[string]interface{}, *regexp.Regexp, []error) {
+	return findTextValues(document.lines, rule.pattern, rule.lineFilter), nil, nil
+}
+
+// getValues returns the lines (other than the empty ones), keyed by their text span paths.
+func (document *textDocument) getValues() map[string]interface{} {
+	values := map[string]interface{}{}
+	for index, line := range document.lines {
+		if line.text != "" {
+			values[getTextSpanPath(index+1, line.text, 0, len(line.text))] = line.text
+		}
+	}
+	return values
+}
+
+// getLine returns the line of the span at the text span path.
+func (document *textDocument) getLine(path string) int {
+	lineNumber, _, _, _ := parseTextSpanPath(path)
+	return lineNumber
+}
+
+func (content *textContent) getDocuments() []document {
+	return []document{content.document}
+}
+
+func (content *textContent) setValues(replacementMaps []map[string]jsonValueReplacement) (string, error) {
+	var errs []error
+	edits := make([]jsonEdit, 0, len(replacementMaps[0]))
+	for path, replacement := range replacementMaps[0] {
+		edit, err := content.getReplacementEdit(path, replacement)
+		if err != nil {
+			errs = append(errs, &PathWriteError{JsonPath: path, Err: err})
+			continue
+		}
+		edits = append(edits, edit)
+	}
+	// The overlapping spans of other rules are skipped when merging the replacements, so the edits don't overlap.
+	slices.SortFunc(edits, func(a jsonEdit, b jsonEdit) int {
+		return a.Start - b.Start
+	})
+	for index := 1; index < len(edits); index++ {
+		if edits[index].Start < edits[index-1].End {
+			return content.content, errors.Join(append(errs, errors.New("overlapping replacements"))...)
+		}
+	}
+	return applyJsonEdits(content.content, edits), errors.Join(errs...)
+}
+
+// getReplacementEdit returns the edit writing the replacement in place of the span at the text span path.
+func (content *textContent) getReplacementEdit(path string, replacement jsonValueReplacement) (jsonEdit, error) {
+	lineNumber, startColumn, endColumn, isTextSpan := parseTextSpanPath(path)
+	lines := content.document.lines
+	if !isTextSpan || lineNumber < 1 || lineNumber > len(lines) {
+		return jsonEdit{}, errors.New("no text at the path")
+	}
+	value := ""
+	if replacement.Delete || json.Unmarshal([]byte(replacement.RawValue), &value) != nil {
+		return jsonEdit{}, fmt.Errorf("the replacement (%s) isn't a string", replacement.RawValue)
+	}
+	line := lines[lineNumber-1]
+	edit := jsonEdit{Start: -1, End: -1, Text: value}
+	column := 1
+	for offset := range line.text {
+		if column == startColumn {
+			edit.Start = line.start + offset
+		}
+		if column == endColumn+1 {
+			edit.End = line.start + offset
+		}
+		column++
+	}
+	if column == endColumn+1 {
+		edit.End = line.start + len(line.text)
+	}
+	if edit.Start < 0 || edit.End < edit.Start {
+		return jsonEdit{}, errors.New("no text at the path")
+	}
+	return edit, nil
+}
+
+// format returns the sanitized content as is, as text content has no formatting.
+func (content *textContent) format(sanitizedContent string, outputFormat string, indent string) ([]byte, error) {
+	return formatUnstructuredContent(sanitizedContent, outputFormat)
+}
+
+// formatUnstructuredContent returns the sanitized content as is for every output format, for the formats whose
+// layout can't be changed without changing their values (Ex: ContentFormatText).
+func formatUnstructuredContent(sanitizedContent string, outputFormat string) ([]byte, error) {
+	if !slices.Contains([]string{OutputFormatPreserve, OutputFormatPretty, OutputFormatMinify, ""}, outputFormat) {
+		return nil, errors.New("unsupported output format (" + outputFormat + ")")
+	}
+	return []byte(sanitizedContent), nil
+}
+
+// compileTextRule checks that the rule can be applied to text content with the supported actions, and compiles its
+// pattern and line filter. The rules of text content are pattern rules without a scope.
+func compileTextRule(ruleKey string, ruleInfo RuleInfo, supportedActions []string) (*CompiledRule, error) {
+	if !slices.Contains(supportedActions, ruleInfo.Action) {
+		return nil, fmt.Errorf("unsupported action (%s)", ruleInfo.Action)
+	} else if isStructuralAction(ruleInfo.Action) {
+		return nil, fmt.Errorf("action (%s) isn't supported for text content", ruleInfo.Action)
+	} else if ruleInfo.Pattern == "" {
+		return nil, errors.New("the rules of text content must have a pattern")
+	} else if ruleInfo.Scope != "" {
+		return nil, errors.New("the rules of text content can't have a scope")
+	}
+	compiledRule := &CompiledRule{Key: ruleKey, Info: ruleInfo}
+	var err error
+	if compiledRule.pattern, err = regexp.Compile(ruleInfo.Pattern); err != nil {
+		return nil, err
+	}
+	if ruleInfo.LineFilter != "" {
+		if compiledRule.lineFilter, err = regexp.Compile(ruleInfo.LineFilter); err != nil {
+			return nil, err
+		}
+	}
+	return compiledRule, nil
+}
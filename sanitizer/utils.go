@@ -0,0 +1,49 @@
+package sanitizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ToPrettyJson indents the provided JSON content with 2 spaces.
+func ToPrettyJson(b []byte) ([]byte, error) {
+	var out bytes.Buffer
+	err := json.Indent(&out, b, "", "  ")
+	return out.Bytes(), err
+}
+
+// FormatJson formats the provided JSON content as per the output format (see Config.OutputFormat).
+// The indent is only used to pretty print the content, and defaults to 2 spaces.
+func FormatJson(b []byte, outputFormat string, indent string) ([]byte, error) {
+	var out bytes.Buffer
+	var err error
+	switch outputFormat {
+	case OutputFormatPreserve:
+		err = json.Unmarshal(b, &json.RawMessage{})
+		return b, err
+	case OutputFormatMinify:
+		err = json.Compact(&out, b)
+	case OutputFormatPretty, "":
+		if indent == "" {
+			indent = defaultOutputIndent
+		}
+		err = json.Indent(&out, b, "", indent)
+	default:
+		err = errors.New("unsupported output format (" + outputFormat + ")")
+	}
+	return out.Bytes(), err
+}
+
+// GenerateSanitizedFileName returns the name of the sanitized file. Ex: a.har => a_sanitized.har
+func GenerateSanitizedFileName(filePath string) string {
+	splitIndex := strings.LastIndex(filePath, ".")
+	return filePath[:splitIndex] + "_sanitized." + filePath[splitIndex+1:]
+}
+
+// GenerateDesanitizedFileName returns the name of the desanitized file. Ex: a_sanitized.har => a_desanitized.har
+func GenerateDesanitizedFileName(filePath string) string {
+	splitIndex := strings.LastIndex(filePath, ".")
+	return strings.TrimSuffix(filePath[:splitIndex], "_sanitized") + "_desanitized." + filePath[splitIndex+1:]
+}
@@ -0,0 +1,129 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonTypeName returns the JSON type name of a value decoded by encoding/json.
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// findLeafValues returns the non null scalar values (keyed by their JSON path) within the provided values.
+// Objects and arrays are searched recursively.
+func findLeafValues(values map[string]interface{}) map[string]interface{} {
+	leaves := map[string]interface{}{}
+	for jsonPath, value := range values {
+		collectLeafValues(jsonPath, value, leaves)
+	}
+	return leaves
+}
+
+func collectLeafValues(jsonPath string, value interface{}, leaves map[string]interface{}) {
+	switch typedValue := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for key, childValue := range typedValue {
+			collectLeafValues(appendJsonPathSegment(jsonPath, key), childValue, leaves)
+		}
+	case []interface{}:
+		for index, childValue := range typedValue {
+			collectLeafValues(appendJsonPathSegment(jsonPath, strconv.Itoa(index)), childValue, leaves)
+		}
+	default:
+		leaves[jsonPath] = typedValue
+	}
+}
+
+// getJsonValueText returns the text the actions are applied to for a non string value.
+// Numbers and booleans use their text in the content, so large numbers don't lose precision.
+// Objects and arrays use their compact JSON with sorted keys, so identical values get the same replacement irrespective
+// of their formatting.
+func getJsonValueText(document document, jsonPath string, value interface{}) (string, error) {
+	switch value.(type) {
+	case float64, bool:
+		return document.getScalarText(jsonPath)
+	case map[string]interface{}, []interface{}:
+		valueJson, err := json.Marshal(value)
+		return string(valueJson), err
+	default:
+		return "", fmt.Errorf("unsupported value type %s at %s", jsonTypeName(value), jsonPath)
+	}
+}
+
+// getFindingValueText returns the text of the value fingerprinted in findings.
+func getFindingValueText(document document, jsonPath string, value interface{}) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return typedValue
+	}
+	valueText, err := getJsonValueText(document, jsonPath, value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return valueText
+}
+
+// getTypedReplacement converts the string replacement of a non string value into a JSON value of the same type.
+//   - Numbers are replaced with an integer derived from the digest for the contextual_replacement and hash actions, 0
+//     for the remove action, and the masked or truncated number for the mask and truncate actions.
+//   - Booleans are replaced with a boolean derived from the digest for the contextual_replacement and hash actions, and
+//     false for the remove action.
+//   - Objects and arrays are replaced with an empty object or array for the remove action.
+//
+// The other combinations can't keep the type and return an error.
+func getTypedReplacement(value interface{}, action string, replacement string) (string, error) {
+	digest := replacement[strings.LastIndex(replacement, "_")+1:]
+	isDigestAction := action == ActionContextualReplacement || action == ActionHash
+	switch value.(type) {
+	case float64:
+		if isDigestAction {
+			// 13 hex digits (52 bits) fit in a float64 without losing precision.
+			number, err := strconv.ParseUint(digest[:min(len(digest), 13)], 16, 64)
+			return strconv.FormatUint(number, 10), err
+		} else if action == ActionRemove {
+			return "0", nil
+		} else if action == ActionMask || action == ActionTruncate {
+			if number, err := strconv.ParseFloat(replacement, 64); err == nil {
+				return strconv.FormatFloat(number, 'f', -1, 64), nil
+			}
+		}
+	case bool:
+		if isDigestAction {
+			number, err := strconv.ParseUint(digest[len(digest)-1:], 16, 64)
+			return strconv.FormatBool(number%2 == 1), err
+		} else if action == ActionRemove {
+			return "false", nil
+		}
+	case map[string]interface{}:
+		if action == ActionRemove {
+			return "{}", nil
+		}
+	case []interface{}:
+		if action == ActionRemove {
+			return "[]", nil
+		}
+	}
+	return "", fmt.Errorf("cannot keep the type of %s values with the %s action (replacement %q)", jsonTypeName(value), action, replacement)
+}
@@ -0,0 +1,78 @@
+package sanitizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindLeafValues(t *testing.T) {
+	leaves := findLeafValues(map[string]interface{}{
+		`$["a"]`: map[string]interface{}{"b": []interface{}{1.0, "x", nil}, "c": true},
+		`$["d"]`: "y",
+	})
+	assert.Equal(t, map[string]interface{}{
+		`$["a"]["b"]["0"]`: 1.0,
+		`$["a"]["b"]["1"]`: "x",
+		`$["a"]["c"]`:      true,
+		`$["d"]`:           "y",
+	}, leaves)
+}
+
+func TestGetJsonValueText(t *testing.T) {
+	document, err := parseJsonDocument(`{"n": 1.50, "b": false, "o": {"z": 1, "a": [true]}}`)
+	require.NoError(t, err)
+	text, err := getJsonValueText(document, `$["n"]`, 1.5)
+	require.NoError(t, err)
+	assert.Equal(t, "1.50", text)
+
+	text, err = getJsonValueText(document, `$["b"]`, false)
+	require.NoError(t, err)
+	assert.Equal(t, "false", text)
+
+	text, err = getJsonValueText(document, `$["o"]`, map[string]interface{}{"z": 1.0, "a": []interface{}{true}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[true],"z":1}`, text)
+
+	_, err = getJsonValueText(document, `$["missing"]`, 1.0)
+	assert.Error(t, err)
+}
+
+func TestGetTypedReplacement(t *testing.T) {
+	digest := hashValue("1")
+	for _, testCase := range []struct {
+		value       interface{}
+		action      string
+		replacement string
+		expected    string
+	}{
+		{1.0, ActionContextualReplacement, "secret_" + digest, "1891620219777871"},
+		{1.0, ActionHash, digest, "1891620219777871"},
+		{1.0, ActionRemove, "<REMOVED>", "0"},
+		{12345.0, ActionMask, "00045", "45"},
+		{12345.0, ActionTruncate, "123", "123"},
+		{true, ActionHash, digest, "true"},
+		{true, ActionRemove, "<REMOVED>", "false"},
+		{map[string]interface{}{}, ActionRemove, "<REMOVED>", "{}"},
+		{[]interface{}{}, ActionRemove, "<REMOVED>", "[]"},
+	} {
+		rawValue, err := getTypedReplacement(testCase.value, testCase.action, testCase.replacement)
+		require.NoError(t, err, testCase)
+		assert.Equal(t, testCase.expected, rawValue, testCase)
+	}
+
+	for _, testCase := range []struct {
+		value       interface{}
+		action      string
+		replacement string
+	}{
+		{12345.0, ActionMask, "***45"},
+		{true, ActionTruncate, "t"},
+		{map[string]interface{}{}, ActionHash, digest},
+		{[]interface{}{}, ActionMask, "**"},
+	} {
+		_, err := getTypedReplacement(testCase.value, testCase.action, testCase.replacement)
+		assert.Error(t, err, testCase)
+	}
+}
@@ -0,0 +1,145 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"maps"
+	"sync"
+)
+
+const vaultFileVersion = 1
+
+// MinimumVaultPassphraseLength is the minimum length of the passphrase a vault can be exported with.
+const MinimumVaultPassphraseLength = 8
+
+// scrypt parameters recommended for interactive logins, along with the sizes of the salt and the derived AES-256 key.
+const (
+	vaultScryptN  = 32768
+	vaultScryptR  = 8
+	vaultScryptP  = 1
+	vaultSaltSize = 16
+	vaultKeySize  = 32
+)
+
+// Vault records the original values behind the contextual replacements (Ex: secret_<hash>), so that sanitized content
+// can be desanitized later. It's safe for concurrent use.
+type Vault struct {
+	mutex    sync.RWMutex
+	mappings map[string]string
+}
+
+// vaultFile is the exported (encrypted) form of a Vault.
+type vaultFile struct {
+	Version    int    `json:"Version"`
+	Salt       []byte `json:"Salt"`
+	Nonce      []byte `json:"Nonce"`
+	Ciphertext []byte `json:"Ciphertext"`
+}
+
+// NewVault creates an empty vault.
+func NewVault() *Vault {
+	return &Vault{mappings: map[string]string{}}
+}
+
+// OpenVault decrypts a vault exported with Vault.Export using the passphrase.
+func OpenVault(data []byte, passphrase []byte) (*Vault, error) {
+	file := vaultFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid vault file: %w", err)
+	}
+	if file.Version != vaultFileVersion {
+		return nil, fmt.Errorf("unsupported vault file version (%d)", file.Version)
+	}
+	aead, err := getVaultCipher(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid vault file nonce")
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("cannot decrypt the vault, the passphrase is incorrect or the vault file is corrupted")
+	}
+	vault := NewVault()
+	if err = json.Unmarshal(plaintext, &vault.mappings); err != nil {
+		return nil, fmt.Errorf("invalid vault content: %w", err)
+	}
+	return vault, nil
+}
+
+// Export encrypts the vault with a key derived from the passphrase (using scrypt) with AES-GCM.
+func (vault *Vault) Export(passphrase []byte) ([]byte, error) {
+	if len(passphrase) < MinimumVaultPassphraseLength {
+		return nil, fmt.Errorf("the vault passphrase must have at least %d characters", MinimumVaultPassphraseLength)
+	}
+	vault.mutex.RLock()
+	plaintext, err := json.Marshal(vault.mappings)
+	vault.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	file := vaultFile{Version: vaultFileVersion, Salt: make([]byte, vaultSaltSize)}
+	if _, err = rand.Read(file.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := getVaultCipher(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(file.Nonce); err != nil {
+		return nil, err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// Add records the original value behind the replacement.
+func (vault *Vault) Add(replacement string, original string) {
+	vault.mutex.Lock()
+	defer vault.mutex.Unlock()
+	vault.mappings[replacement] = original
+}
+
+// Get returns the original value behind the replacement, and whether it's present in the vault.
+func (vault *Vault) Get(replacement string) (string, bool) {
+	vault.mutex.RLock()
+	defer vault.mutex.RUnlock()
+	original, isPresent := vault.mappings[replacement]
+	return original, isPresent
+}
+
+// Merge adds all the mappings in the other vault to this vault.
+func (vault *Vault) Merge(other *Vault) {
+	other.mutex.RLock()
+	defer other.mutex.RUnlock()
+	vault.mutex.Lock()
+	defer vault.mutex.Unlock()
+	maps.Copy(vault.mappings, other.mappings)
+}
+
+// Len returns the number of mappings in the vault.
+func (vault *Vault) Len() int {
+	vault.mutex.RLock()
+	defer vault.mutex.RUnlock()
+	return len(vault.mappings)
+}
+
+func getVaultCipher(passphrase []byte, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, vaultScryptN, vaultScryptR, vaultScryptP, vaultKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
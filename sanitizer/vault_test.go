@@ -0,0 +1,37 @@
+package sanitizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultExportAndOpen(t *testing.T) {
+	vault := NewVault()
+	vault.Add("secret_1", "password")
+	vault.Add("secret_2", "cookie")
+
+	vaultBytes, err := vault.Export([]byte("passphrase"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(vaultBytes), "password")
+
+	openedVault, err := OpenVault(vaultBytes, []byte("passphrase"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, openedVault.Len())
+	original, isPresent := openedVault.Get("secret_1")
+	assert.True(t, isPresent)
+	assert.Equal(t, "password", original)
+
+	_, err = OpenVault(vaultBytes, []byte("wrong passphrase"))
+	assert.Error(t, err)
+	_, err = OpenVault([]byte("{}"), []byte("passphrase"))
+	assert.Error(t, err)
+	_, err = vault.Export([]byte("short"))
+	assert.Error(t, err)
+
+	otherVault := NewVault()
+	otherVault.Add("secret_3", "token")
+	openedVault.Merge(otherVault)
+	assert.Equal(t, 3, openedVault.Len())
+}
@@ -0,0 +1,648 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/antchfx/xpath"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// xmlNamespaceUrl is the namespace bound to the xml prefix (Ex: xml:space).
+const xmlNamespaceUrl = "http://www.w3.org/XML/1998/namespace"
+
+// xmlNode is a node of XML content, which the rules' XPaths are evaluated against.
+// The namespace declarations aren't nodes (like in XPath), and neither are the processing instructions and directives, so
+// they're left as is.
+type xmlNode struct {
+	nodeType     xpath.NodeType
+	prefix       string
+	localName    string
+	namespaceUrl string
+	// value is the decoded text of a text, comment or attribute node.
+	value      string
+	parent     *xmlNode
+	children   []*xmlNode
+	attributes []*xmlNode
+	// index is the index of the node in its parent's children or attributes.
+	index int
+	// path is the location path of the node (Ex: /settings[1]/servers[1]/server[2]/password[1]/text()[1]), which the
+	// values are keyed by instead of a JSON path.
+	path string
+	line int
+	// start and end are the offsets of the value of a text, comment or attribute node in the content: the text
+	// (including the markers of a CDATA section), the text of the comment, or the attribute's value within its quotes.
+	start int
+	end   int
+	// isCData is set if the text node only consists of CDATA sections.
+	isCData bool
+	// quote is the quote of the attribute's value in the content.
+	quote byte
+}
+
+func (node *xmlNode) getQualifiedName() string {
+	if node.prefix == "" {
+		return node.localName
+	}
+	return node.prefix + ":" + node.localName
+}
+
+// getText returns the text of the node, which is the concatenated text within it for the root and elements.
+func (node *xmlNode) getText() string {
+	if node.nodeType != xpath.RootNode && node.nodeType != xpath.ElementNode {
+		return node.value
+	}
+	var text strings.Builder
+	for _, child := range node.children {
+		if child.nodeType != xpath.CommentNode {
+			text.WriteString(child.getText())
+		}
+	}
+	return text.String()
+}
+
+func (node *xmlNode) hasChildElements() bool {
+	return slices.ContainsFunc(node.children, func(child *xmlNode) bool {
+		return child.nodeType == xpath.ElementNode
+	})
+}
+
+// collectTextValues collects the values of the attributes and the text nodes (other than whitespace) within the node,
+// keyed by their paths.
+func (node *xmlNode) collectTextValues(values map[string]interface{}) {
+	switch node.nodeType {
+	case xpath.AttributeNode:
+		values[node.path] = node.value
+	case xpath.TextNode:
+		if strings.TrimSpace(node.value) != "" {
+			values[node.path] = node.value
+		}
+	}
+	for _, attribute := range node.attributes {
+		attribute.collectTextValues(values)
+	}
+	for _, child := range node.children {
+		child.collectTextValues(values)
+	}
+}
+
+// xmlNavigator navigates the XML nodes for the XPaths.
+type xmlNavigator struct {
+	root *xmlNode
+	node *xmlNode
+}
+
+func (navigator *xmlNavigator) NodeType() xpath.NodeType {
+	return navigator.node.nodeType
+}
+
+func (navigator *xmlNavigator) LocalName() string {
+	return navigator.node.localName
+}
+
+func (navigator *xmlNavigator) Prefix() string {
+	return navigator.node.prefix
+}
+
+// NamespaceURL returns the namespace of the node, which the prefixed names in the XPaths are matched against if their
+// prefix is bound in the rule set's namespaces (see RuleSet.Namespaces).
+func (navigator *xmlNavigator) NamespaceURL() string {
+	return navigator.node.namespaceUrl
+}
+
+func (navigator *xmlNavigator) Value() string {
+	return navigator.node.getText()
+}
+
+func (navigator *xmlNavigator) Copy() xpath.NodeNavigator {
+	navigatorCopy := *navigator
+	return &navigatorCopy
+}
+
+func (navigator *xmlNavigator) MoveToRoot() {
+	navigator.node = navigator.root
+}
+
+func (navigator *xmlNavigator) MoveToParent() bool {
+	if navigator.node.parent == nil {
+		return false
+	}
+	navigator.node = navigator.node.parent
+	return true
+}
+
+func (navigator *xmlNavigator) MoveToNextAttribute() bool {
+	node := navigator.node
+	if node.nodeType == xpath.ElementNode && len(node.attributes) > 0 {
+		navigator.node = node.attributes[0]
+		return true
+	} else if node.nodeType == xpath.AttributeNode && node.index+1 < len(node.parent.attributes) {
+		navigator.node = node.parent.attributes[node.index+1]
+		return true
+	}
+	return false
+}
+
+func (navigator *xmlNavigator) MoveToChild() bool {
+	if navigator.node.nodeType == xpath.AttributeNode || len(navigator.node.children) == 0 {
+		return false
+	}
+	navigator.node = navigator.node.children[0]
+	return true
+}
+
+func (navigator *xmlNavigator) MoveToFirst() bool {
+	return navigator.moveToSibling(0)
+}
+
+func (navigator *xmlNavigator) MoveToNext() bool {
+	return navigator.moveToSibling(navigator.node.index + 1)
+}
+
+func (navigator *xmlNavigator) MoveToPrevious() bool {
+	return navigator.moveToSibling(navigator.node.index - 1)
+}
+
+func (navigator *xmlNavigator) moveToSibling(index int) bool {
+	node := navigator.node
+	if node.nodeType == xpath.AttributeNode || node.parent == nil || index == node.index || index < 0 || index >= len(node.parent.children) {
+		return false
+	}
+	navigator.node = node.parent.children[index]
+	return true
+}
+
+func (navigator *xmlNavigator) MoveTo(other xpath.NodeNavigator) bool {
+	otherNavigator, isXmlNavigator := other.(*xmlNavigator)
+	if !isXmlNavigator || otherNavigator.root != navigator.root {
+		return false
+	}
+	navigator.node = otherNavigator.node
+	return true
+}
+
+// xmlContent is XML content, which is a single document.
+//
+// The rules' keys are XPaths (see compileXmlRule), and the values are the text of the attributes, text nodes and
+// comments selected by them, keyed by their location paths. Only the sanitized values are rewritten, so the
+// namespaces, the order of the nodes and the rest of the markup are preserved.
+type xmlContent struct {
+	content  string
+	document *xmlDocument
+	// nodes are the attributes, text nodes and comments of the content, keyed by their paths.
+	nodes map[string]*xmlNode
+}
+
+// parseXmlContent parses the XML content into its nodes.
+func parseXmlContent(content string) (*xmlContent, error) {
+	root := &xmlNode{nodeType: xpath.RootNode, path: "/"}
+	xmlContent := &xmlContent{content: content, nodes: map[string]*xmlNode{}}
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	lineCounter := &xmlLineCounter{content: content, line: 1}
+	// The namespaces in scope of each open element, from the root.
+	namespaces := []map[string]string{{"xml": xmlNamespaceUrl}}
+	parent := root
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		end := int(decoder.InputOffset())
+		switch typedToken := token.(type) {
+		case xml.StartElement:
+			if parent == root && slices.ContainsFunc(root.children, func(child *xmlNode) bool {
+				return child.nodeType == xpath.ElementNode
+			}) {
+				return nil, fmt.Errorf("multiple root elements (line %d)", lineCounter.getLine(start))
+			}
+			elementNamespaces := map[string]string{}
+			for prefix, namespaceUrl := range namespaces[len(namespaces)-1] {
+				elementNamespaces[prefix] = namespaceUrl
+			}
+			for _, attr := range typedToken.Attr {
+				if attr.Name.Space == "xmlns" {
+					elementNamespaces[attr.Name.Local] = attr.Value
+				} else if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					elementNamespaces[""] = attr.Value
+				}
+			}
+			namespaces = append(namespaces, elementNamespaces)
+			element := &xmlNode{
+				nodeType:     xpath.ElementNode,
+				prefix:       typedToken.Name.Space,
+				localName:    typedToken.Name.Local,
+				namespaceUrl: elementNamespaces[typedToken.Name.Space],
+				line:         lineCounter.getLine(start),
+			}
+			if typedToken.Name.Space != "" && element.namespaceUrl == "" {
+				return nil, fmt.Errorf("undeclared namespace prefix %s (line %d)", typedToken.Name.Space, element.line)
+			}
+			if err := parseXmlAttributes(content[start:end], start, typedToken.Attr, element, elementNamespaces, lineCounter); err != nil {
+				return nil, err
+			}
+			addXmlChild(parent, element)
+			parent = element
+		case xml.EndElement:
+			if parent == root || typedToken.Name.Space != parent.prefix || typedToken.Name.Local != parent.localName {
+				return nil, fmt.Errorf("unexpected end element </%s> (line %d)", getXmlQualifiedName(typedToken.Name), lineCounter.getLine(start))
+			}
+			namespaces = namespaces[:len(namespaces)-1]
+			parent = parent.parent
+		case xml.CharData:
+			if parent == root {
+				if strings.TrimSpace(string(typedToken)) != "" {
+					return nil, fmt.Errorf("text outside the root element (line %d)", lineCounter.getLine(start))
+				}
+				continue
+			}
+			isCData := strings.HasPrefix(content[start:end], "<![CDATA[")
+			// Adjacent text and CDATA sections are a single text node (like in XPath), so a value split across them is
+			// matched as a whole.
+			if len(parent.children) > 0 {
+				if previous := parent.children[len(parent.children)-1]; previous.nodeType == xpath.TextNode && previous.end == start {
+					previous.value += string(typedToken)
+					previous.end = end
+					previous.isCData = previous.isCData && isCData
+					continue
+				}
+			}
+			addXmlChild(parent, &xmlNode{nodeType: xpath.TextNode, value: string(typedToken), line: lineCounter.getLine(start), start: start, end: end, isCData: isCData})
+		case xml.Comment:
+			addXmlChild(parent, &xmlNode{nodeType: xpath.CommentNode, value: string(typedToken), line: lineCounter.getLine(start), start: start + len("<!--"), end: end - len("-->")})
+		}
+	}
+	if parent != root {
+		return nil, fmt.Errorf("unclosed element <%s>", parent.getQualifiedName())
+	} else if !root.hasChildElements() {
+		return nil, errors.New("no root element")
+	}
+
+	valueLines := map[string]int{}
+	setXmlPaths(root, xmlContent.nodes, valueLines)
+	xmlContent.document = &xmlDocument{stringDocument: stringDocument{valueLines: valueLines}, root: root}
+	return xmlContent, nil
+}
+
+func getXmlQualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// parseXmlAttributes adds the attributes (other than the namespace declarations) of the start tag to the element, with
+// the offsets of their values located in the start tag's text.
+func parseXmlAttributes(startTag string, startTagOffset int, attrs []xml.Attr, element *xmlNode, namespaces map[string]string, lineCounter *xmlLineCounter) error {
+	offset := strings.IndexAny(startTag, " \t\r\n/>")
+	for _, attr := range attrs {
+		// Skip to the attribute's value, as the attributes are in the order of the start tag.
+		equalsOffset := strings.IndexByte(startTag[offset:], '=')
+		if equalsOffset < 0 {
+			return fmt.Errorf("attribute %s not found in the start tag (line %d)", getXmlQualifiedName(attr.Name), element.line)
+		}
+		offset += equalsOffset + 1
+		offset += len(startTag[offset:]) - len(strings.TrimLeft(startTag[offset:], " \t\r\n"))
+		quote := startTag[offset]
+		valueEnd := strings.IndexByte(startTag[offset+1:], quote)
+		if (quote != '"' && quote != '\'') || valueEnd < 0 {
+			return fmt.Errorf("invalid value of attribute %s (line %d)", getXmlQualifiedName(attr.Name), element.line)
+		}
+		start := startTagOffset + offset + 1
+		offset += valueEnd + 2
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		attribute := &xmlNode{
+			nodeType:  xpath.AttributeNode,
+			prefix:    attr.Name.Space,
+			localName: attr.Name.Local,
+			value:     attr.Value,
+			parent:    element,
+			index:     len(element.attributes),
+			line:      lineCounter.getLine(start),
+			start:     start,
+			end:       start + valueEnd,
+			quote:     quote,
+		}
+		if attr.Name.Space != "" {
+			if attribute.namespaceUrl = namespaces[attr.Name.Space]; attribute.namespaceUrl == "" {
+				return fmt.Errorf("undeclared namespace prefix %s (line %d)", attr.Name.Space, element.line)
+			}
+		}
+		element.attributes = append(element.attributes, attribute)
+	}
+	return nil
+}
+
+func addXmlChild(parent *xmlNode, child *xmlNode) {
+	child.parent = parent
+	child.index = len(parent.children)
+	parent.children = append(parent.children, child)
+}
+
+// setXmlPaths sets the location paths of the nodes within the node, collecting the attributes, text nodes and comments
+// along with their lines.
+func setXmlPaths(node *xmlNode, nodes map[string]*xmlNode, valueLines map[string]int) {
+	parentPath := strings.TrimSuffix(node.path, "/")
+	for _, attribute := range node.attributes {
+		attribute.path = parentPath + "/@" + attribute.getQualifiedName()
+		nodes[attribute.path] = attribute
+		valueLines[attribute.path] = attribute.line
+	}
+	positions := map[string]int{}
+	for _, child := range node.children {
+		step := child.getQualifiedName()
+		switch child.nodeType {
+		case xpath.TextNode:
+			step = "text()"
+		case xpath.CommentNode:
+			step = "comment()"
+		}
+		positions[step]++
+		child.path = parentPath + "/" + step + "[" + strconv.Itoa(positions[step]) + "]"
+		if child.nodeType == xpath.ElementNode {
+			setXmlPaths(child, nodes, valueLines)
+		} else {
+			nodes[child.path] = child
+			valueLines[child.path] = child.line
+		}
+	}
+}
+
+// xmlLineCounter counts the lines of the content up to increasing offsets.
+type xmlLineCounter struct {
+	content string
+	offset  int
+	line    int
+}
+
+func (lineCounter *xmlLineCounter) getLine(offset int) int {
+	if offset > lineCounter.offset {
+		lineCounter.line += strings.Count(lineCounter.content[lineCounter.offset:offset], "\n")
+		lineCounter.offset = offset
+	}
+	return lineCounter.line
+}
+
+func (content *xmlContent) getDocuments() []document {
+	return []document{content.document}
+}
+
+func (content *xmlContent) setValues(replacementMaps []map[string]jsonValueReplacement) (string, error) {
+	var errs []error
+	edits := make([]jsonEdit, 0, len(replacementMaps[0]))
+	for path, replacement := range replacementMaps[0] {
+		edit, err := content.getReplacementEdit(path, replacement)
+		if err != nil {
+			errs = append(errs, &PathWriteError{JsonPath: path, Err: err})
+			continue
+		}
+		edits = append(edits, edit)
+	}
+	return applyJsonEdits(content.content, edits), errors.Join(errs...)
+}
+
+// getReplacementEdit returns the edit writing the replacement in place of the value of the node at the path, escaped as
+// per the node.
+func (content *xmlContent) getReplacementEdit(path string, replacement jsonValueReplacement) (jsonEdit, error) {
+	node, isPresent := content.nodes[path]
+	if !isPresent {
+		return jsonEdit{}, errors.New("no attribute, text or comment at the path")
+	}
+	value := ""
+	if replacement.Delete || json.Unmarshal([]byte(replacement.RawValue), &value) != nil {
+		return jsonEdit{}, fmt.Errorf("the replacement (%s) isn't a string", replacement.RawValue)
+	}
+	edit := jsonEdit{Start: node.start, End: node.end}
+	switch {
+	case node.nodeType == xpath.AttributeNode:
+		edit.Text = escapeXmlText(value, node.quote)
+	case node.nodeType == xpath.CommentNode:
+		if strings.Contains(value, "--") || strings.HasSuffix(value, "-") {
+			return jsonEdit{}, fmt.Errorf("the replacement (%s) can't be written in a comment", value)
+		}
+		edit.Text = value
+	case node.isCData && !strings.Contains(value, "]]>"):
+		edit.Text = "<![CDATA[" + value + "]]>"
+	default:
+		edit.Text = escapeXmlText(value, 0)
+	}
+	return edit, nil
+}
+
+// escapeXmlText escapes the text to write it as character data, or as an attribute's value if the quote is set.
+// The carriage returns (and the tabs and newlines of attributes) are escaped, so they aren't normalized when parsed.
+func escapeXmlText(text string, quote byte) string {
+	var escapedText strings.Builder
+	for _, character := range text {
+		switch {
+		case character == '&':
+			escapedText.WriteString("&amp;")
+		case character == '<':
+			escapedText.WriteString("&lt;")
+		case character == '>':
+			escapedText.WriteString("&gt;")
+		case character == '\r':
+			escapedText.WriteString("&#xD;")
+		case quote != 0 && character == rune(quote):
+			escapedText.WriteString(map[byte]string{'"': "&quot;", '\'': "&apos;"}[quote])
+		case quote != 0 && character == '\n':
+			escapedText.WriteString("&#xA;")
+		case quote != 0 && character == '\t':
+			escapedText.WriteString("&#x9;")
+		default:
+			escapedText.WriteRune(character)
+		}
+	}
+	return escapedText.String()
+}
+
+// format formats the sanitized content. The pretty output format indents the elements that only contain elements, and
+// the minify output format removes the whitespace between them. The content of elements with text (or with
+// xml:space="preserve") is left as is.
+func (content *xmlContent) format(sanitizedContent string, outputFormat string, indent string) ([]byte, error) {
+	if outputFormat == OutputFormatPreserve {
+		return []byte(sanitizedContent), nil
+	} else if outputFormat != OutputFormatPretty && outputFormat != OutputFormatMinify && outputFormat != "" {
+		return nil, errors.New("unsupported output format (" + outputFormat + ")")
+	}
+	if indent == "" {
+		indent = defaultOutputIndent
+	}
+	tokens, err := parseXmlTokens(sanitizedContent)
+	if err != nil {
+		return nil, err
+	}
+	var formattedContent strings.Builder
+	for index, token := range tokens {
+		if token.isPreserved {
+			formattedContent.WriteString(token.text)
+			continue
+		} else if token.isWhitespace || token.text == "" {
+			continue
+		}
+		isEmptyElementEnd := token.isEndElement && index > 0 && tokens[index-1].isStartElement
+		if outputFormat != OutputFormatMinify && formattedContent.Len() > 0 && !isEmptyElementEnd {
+			formattedContent.WriteString("\n" + strings.Repeat(indent, token.depth))
+		}
+		formattedContent.WriteString(token.text)
+	}
+	if outputFormat != OutputFormatMinify {
+		formattedContent.WriteString("\n")
+	}
+	return []byte(formattedContent.String()), nil
+}
+
+// xmlToken is a token of XML content, with its text in the content.
+type xmlToken struct {
+	text           string
+	depth          int
+	isStartElement bool
+	isEndElement   bool
+	isWhitespace   bool
+	// isPreserved is set if the token is within an element that has text (other than whitespace) or
+	// xml:space="preserve", or is such an element's end, so it's left as is when formatting.
+	isPreserved bool
+}
+
+// parseXmlTokens parses the XML content into its tokens, marking the ones whose whitespace is significant.
+func parseXmlTokens(content string) ([]*xmlToken, error) {
+	var tokens []*xmlToken
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	// The index of the start token of each open element, and whether its content is preserved.
+	var openElements []int
+	isPreserved := []bool{false}
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		xmlToken := &xmlToken{text: content[start:decoder.InputOffset()], depth: len(openElements), isPreserved: isPreserved[len(isPreserved)-1]}
+		tokens = append(tokens, xmlToken)
+		switch typedToken := token.(type) {
+		case xml.StartElement:
+			xmlToken.isStartElement = true
+			openElements = append(openElements, len(tokens)-1)
+			isElementPreserved := xmlToken.isPreserved || slices.ContainsFunc(typedToken.Attr, func(attr xml.Attr) bool {
+				return attr.Name.Space == "xml" && attr.Name.Local == "space" && attr.Value == "preserve"
+			})
+			isPreserved = append(isPreserved, isElementPreserved)
+		case xml.EndElement:
+			if len(openElements) == 0 {
+				return nil, fmt.Errorf("unexpected end element </%s>", getXmlQualifiedName(typedToken.Name))
+			}
+			xmlToken.isEndElement = true
+			xmlToken.depth--
+			xmlToken.isPreserved = isPreserved[len(isPreserved)-1]
+			openElements = openElements[:len(openElements)-1]
+			isPreserved = isPreserved[:len(isPreserved)-1]
+		case xml.CharData:
+			xmlToken.isWhitespace = strings.TrimSpace(string(typedToken)) == "" && !strings.HasPrefix(xmlToken.text, "<![CDATA[")
+			if !xmlToken.isWhitespace && len(openElements) > 0 && !xmlToken.isPreserved {
+				// The element has text, so its content (from its start) is preserved.
+				elementIndex := openElements[len(openElements)-1]
+				for _, elementToken := range tokens[elementIndex+1:] {
+					elementToken.isPreserved = true
+				}
+				for depth := len(openElements); depth < len(isPreserved); depth++ {
+					isPreserved[depth] = true
+				}
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// compileXmlRule checks that the rule can be applied to XML content with the supported actions, and compiles its XPath
+// with the rule set's namespaces. The key of a rule is an XPath, and the scope of a pattern rule is an XPath that
+// defaults to the whole document (/).
+func compileXmlRule(ruleKey string, ruleInfo RuleInfo, namespaces map[string]string, supportedActions []string) (*CompiledRule, error) {
+	if !slices.Contains(supportedActions, ruleInfo.Action) {
+		return nil, fmt.Errorf("unsupported action (%s)", ruleInfo.Action)
+	} else if isStructuralAction(ruleInfo.Action) {
+		return nil, fmt.Errorf("action (%s) isn't supported for XML content", ruleInfo.Action)
+	}
+	compiledRule := &CompiledRule{Key: ruleKey, Info: ruleInfo}
+	expression := ruleKey
+	if ruleInfo.Pattern != "" {
+		expression = cmp.Or(ruleInfo.Scope, "/")
+	}
+	var err error
+	if compiledRule.xpath, err = xpath.CompileWithNS(expression, namespaces); err != nil {
+		return nil, err
+	}
+	if ruleInfo.Pattern != "" {
+		if compiledRule.pattern, err = regexp.Compile(ruleInfo.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	return compiledRule, nil
+}
+
+// xmlDocument is the nodes of XML content, which the rules' XPaths are evaluated against.
+type xmlDocument struct {
+	stringDocument
+	root *xmlNode
+}
+
+// findValues returns the values of the nodes selected by the rule's XPath (see findXmlValues).
+func (document *xmlDocument) findValues(rule *CompiledRule, _ int) (map[string]interface{}, *regexp.Regexp, []error) {
+	values, errs := findXmlValues(rule.xpath, document.root, rule.Key, rule.Info.Recursive || rule.pattern != nil)
+	return values, rule.pattern, errs
+}
+
+// getValues returns the values of the attributes and the text nodes, keyed by their location paths.
+func (document *xmlDocument) getValues() map[string]interface{} {
+	values := map[string]interface{}{}
+	document.root.collectTextValues(values)
+	return values
+}
+
+// findXmlValues returns the values of the nodes selected by the XPath, keyed by their paths:
+//   - The attributes, text nodes and comments are selected as is.
+//   - The text nodes of elements without child elements are selected.
+//   - If the rule is expanded (i.e. it's recursive or a pattern rule), the attributes and text nodes (other than
+//     whitespace) within the selected elements are selected instead.
+//
+// The elements with child elements can't be sanitized as a whole, so they're returned as RuleErrors if the rule isn't
+// expanded.
+func findXmlValues(expression *xpath.Expr, root *xmlNode, ruleKey string, isExpanded bool) (map[string]interface{}, []error) {
+	values := map[string]interface{}{}
+	result := expression.Evaluate(&xmlNavigator{root: root, node: root})
+	iterator, isNodeSet := result.(*xpath.NodeIterator)
+	if !isNodeSet {
+		return values, []error{&RuleError{RuleKey: ruleKey, JsonPath: root.path, Err: fmt.Errorf("the XPath evaluates to %v instead of nodes", result)}}
+	}
+	var errs []error
+	for iterator.MoveNext() {
+		node := iterator.Current().(*xmlNavigator).node
+		switch {
+		case node.nodeType == xpath.AttributeNode || node.nodeType == xpath.TextNode || node.nodeType == xpath.CommentNode:
+			values[node.path] = node.value
+		case isExpanded:
+			node.collectTextValues(values)
+		case node.nodeType == xpath.ElementNode && !node.hasChildElements():
+			for _, child := range node.children {
+				if child.nodeType == xpath.TextNode {
+					values[child.path] = child.value
+				}
+			}
+		default:
+			errs = append(errs, &RuleError{RuleKey: ruleKey, JsonPath: node.path, Err: errors.New("the element has child elements, set recursive to sanitize the attributes and text within it")})
+		}
+	}
+	return values, errs
+}
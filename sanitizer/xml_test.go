@@ -0,0 +1,172 @@
+package sanitizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeXml(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- Maven settings. -->
+<settings xmlns="http://maven.apache.org/SETTINGS/1.0.0">
+  <servers>
+    <server id="central" token='a"b'>
+      <username>deployer</username>
+      <password><![CDATA[p<ss]]></password>
+    </server>
+    <server id="mirror">
+      <password>p&amp;ss</password>
+    </server>
+  </servers>
+</settings>
+`
+	sanitizer := newTestRuleSetSanitizer(t, "xml", RuleSet{Format: ContentFormatXml, Rules: map[string]RuleInfo{
+		"//server/password": {Action: ActionContextualReplacement},
+		"//server/@token":   {Action: ActionRemove},
+		"//username":        {Action: ActionMask, KeepLast: 2},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "xml", "a.xml", "a_sanitized.xml")
+	require.NoError(t, err)
+	replacement := result.Report.Findings[2].Replacement
+	assert.Regexp(t, "^secret_[0-9a-f]{64}$", replacement)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<!-- Maven settings. -->
+<settings xmlns="http://maven.apache.org/SETTINGS/1.0.0">
+  <servers>
+    <server id="central" token='&lt;REMOVED&gt;'>
+      <username>******er</username>
+      <password><![CDATA[`+replacement+`]]></password>
+    </server>
+    <server id="mirror">
+      <password>`+result.Report.Findings[3].Replacement+`</password>
+    </server>
+  </servers>
+</settings>
+`, result.Content)
+
+	paths := make([]string, 0, len(result.Report.Findings))
+	lines := make([]int, 0, len(result.Report.Findings))
+	for _, finding := range result.Report.Findings {
+		paths = append(paths, finding.JsonPath)
+		lines = append(lines, finding.Line)
+	}
+	assert.Equal(t, []string{
+		"/settings[1]/servers[1]/server[1]/@token",
+		"/settings[1]/servers[1]/server[1]/username[1]/text()[1]",
+		"/settings[1]/servers[1]/server[1]/password[1]/text()[1]",
+		"/settings[1]/servers[1]/server[2]/password[1]/text()[1]",
+	}, paths)
+	assert.Equal(t, []int{5, 6, 7, 10}, lines)
+}
+
+func TestSanitizeXmlNamespaces(t *testing.T) {
+	content := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:w="urn:wsse">
+  <soap:Header><w:Security><w:Password w:Type="text">hunter2</w:Password></w:Security></soap:Header>
+  <soap:Body><login xmlns="urn:app"><apiKey>k1</apiKey></login></soap:Body>
+</soap:Envelope>`
+	sanitizer := newTestRuleSetSanitizer(t, "xml", RuleSet{Format: ContentFormatXml, Namespaces: map[string]string{"wsse": "urn:wsse", "app": "urn:app"}, Rules: map[string]RuleInfo{
+		// The prefixes are matched against the namespaces, irrespective of their prefixes in the content.
+		"//wsse:Password":            {Action: ActionRemove},
+		"//wsse:Password/@wsse:Type": {Action: ActionHash},
+		"//app:login/app:apiKey":     {Action: ActionRemove},
+	}})
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "xml", "a.xml", "a_sanitized.xml")
+	require.NoError(t, err)
+	assert.Equal(t, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:w="urn:wsse">
+  <soap:Header><w:Security><w:Password w:Type="`+hashValue("text")+`">&lt;REMOVED&gt;</w:Password></w:Security></soap:Header>
+  <soap:Body><login xmlns="urn:app"><apiKey>&lt;REMOVED&gt;</apiKey></login></soap:Body>
+</soap:Envelope>`, sanitizedContent)
+
+	// Without the namespaces, the prefixes are matched against the prefixes in the content.
+	sanitizer = newTestRuleSetSanitizer(t, "xml", RuleSet{Format: ContentFormatXml, Rules: map[string]RuleInfo{"//w:Password": {Action: ActionRemove}, "//login/apiKey": {Action: ActionRemove}}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "xml", "a.xml", "a_sanitized.xml")
+	require.NoError(t, err)
+	assert.Len(t, result.Report.Findings, 2)
+}
+
+func TestSanitizeXmlPatternAndRecursiveRules(t *testing.T) {
+	content := `<log><entry auth="Bearer abc">GET /?token=x <!-- Bearer def --></entry><secrets><a k="1">2</a><b>3</b></secrets></log>`
+	sanitizer := newTestRuleSetSanitizer(t, "xml", RuleSet{Format: ContentFormatXml, Rules: map[string]RuleInfo{
+		"bearer_token": {Action: ActionRemove, Pattern: `Bearer (\w+)`, Partial: true},
+		"token":        {Action: ActionRemove, Pattern: `token=(\w+)`, Partial: true, Scope: "//entry"},
+		"//secrets":    {Action: ActionTruncate, MaxLength: 0, Recursive: true},
+	}})
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "xml", "a.xml", "a_sanitized.xml")
+	require.NoError(t, err)
+	// The pattern rules aren't applied to the comments, unless they select them.
+	assert.Equal(t, `<log><entry auth="Bearer &lt;REMOVED&gt;">GET /?token=&lt;REMOVED&gt; <!-- Bearer def --></entry><secrets><a k=""></a><b></b></secrets></log>`, sanitizedContent)
+}
+
+func TestSanitizeXmlTextSplitAcrossCData(t *testing.T) {
+	content := `<log><entry>token=ab<![CDATA[cd]]> ok</entry><key><![CDATA[x]]><![CDATA[y]]></key><!-- a --><note>a<!-- b -->c</note></log>`
+	sanitizer := newTestRuleSetSanitizer(t, "xml", RuleSet{Format: ContentFormatXml, Rules: map[string]RuleInfo{
+		"token":  {Action: ActionRemove, Pattern: `token=(\w+)`, Partial: true},
+		"//key":  {Action: ActionRemove},
+		"//note": {Action: ActionRemove},
+	}})
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "xml", "a.xml", "a_sanitized.xml")
+	require.NoError(t, err)
+	// The text and CDATA sections are matched as a single text node, unless they're separated by a comment.
+	assert.Equal(t, `<log><entry>token=&lt;REMOVED&gt; ok</entry><key><![CDATA[<REMOVED>]]></key><!-- a --><note>&lt;REMOVED&gt;<!-- b -->&lt;REMOVED&gt;</note></log>`, sanitizedContent)
+}
+
+func TestSanitizeXmlErrors(t *testing.T) {
+	sanitizer := newTestRuleSetSanitizer(t, "xml", RuleSet{Format: ContentFormatXml, Rules: map[string]RuleInfo{
+		"//a":         {Action: ActionRemove},
+		"count(//a)":  {Action: ActionRemove},
+		"//comment()": {Action: ActionContextualReplacement},
+	}})
+	sanitizedContent, _, _, err := sanitizer.Sanitize("<a><b>x</b><!--c--></a>", "xml", "a.xml", "a_sanitized.xml")
+	var partialSanitizationErr *PartialSanitizationError
+	require.ErrorAs(t, err, &partialSanitizationErr)
+	assert.Len(t, partialSanitizationErr.Errors, 2)
+	assert.Regexp(t, `^<a><b>x</b><!--secret_[0-9a-f]{64}--></a>$`, sanitizedContent)
+
+	for _, content := range []string{"", "<a>", "<a></b>", "<a/><b/>", "text<a/>", "<x:a/>"} {
+		_, _, _, err = sanitizer.Sanitize(content, "xml", "a.xml", "a_sanitized.xml")
+		var invalidInputErr *InvalidInputError
+		assert.ErrorAs(t, err, &invalidInputErr, content)
+	}
+
+	compiledRuleSet, err := CompileRuleSet(RuleSet{Format: ContentFormatXml, Rules: map[string]RuleInfo{
+		"//a[":  {Action: ActionRemove},
+		"//b":   {Action: ActionDelete},
+		"//c":   {Action: ActionNull},
+		"valid": {Action: ActionRemove, Pattern: "x"},
+	}}, sanitizer.config)
+	assert.Error(t, err)
+	assert.Len(t, compiledRuleSet.Errors, 3)
+}
+
+func TestSanitizeXmlOutputFormats(t *testing.T) {
+	content := "<?xml version=\"1.0\"?>\n<a>\n      <b   k=\"v\">x</b><c/>\n<d xml:space=\"preserve\"> <e/> </d><f>text <g/> </f>\n</a>\n"
+	sanitizer := newTestRuleSetSanitizer(t, "xml", RuleSet{Format: ContentFormatXml, Rules: map[string]RuleInfo{"//b": {Action: ActionRemove}}})
+	for outputFormat, expectedContent := range map[string]string{
+		OutputFormatPreserve: "<?xml version=\"1.0\"?>\n<a>\n      <b   k=\"v\">&lt;REMOVED&gt;</b><c/>\n<d xml:space=\"preserve\"> <e/> </d><f>text <g/> </f>\n</a>\n",
+		OutputFormatPretty:   "<?xml version=\"1.0\"?>\n<a>\n  <b   k=\"v\">&lt;REMOVED&gt;</b>\n  <c/>\n  <d xml:space=\"preserve\"> <e/> </d>\n  <f>text <g/> </f>\n</a>\n",
+		OutputFormatMinify:   "<?xml version=\"1.0\"?><a><b   k=\"v\">&lt;REMOVED&gt;</b><c/><d xml:space=\"preserve\"> <e/> </d><f>text <g/> </f></a>",
+	} {
+		sanitizer.config.OutputFormat = outputFormat
+		sanitizedContent, _, _, err := sanitizer.Sanitize(content, "xml", "a.xml", "a_sanitized.xml")
+		require.NoError(t, err, outputFormat)
+		assert.Equal(t, expectedContent, sanitizedContent, outputFormat)
+	}
+}
+
+func TestDesanitizeXml(t *testing.T) {
+	content := `<a token="t&quot;1"><b>x &lt; y</b><c><![CDATA[z]]></c></a>`
+	sanitizer := newTestRuleSetSanitizer(t, "xml", RuleSet{Format: ContentFormatXml, Rules: map[string]RuleInfo{
+		"//@token":  {Action: ActionContextualReplacement},
+		"//b | //c": {Action: ActionContextualReplacement},
+	}})
+	vault := NewVault()
+	sanitizer.SetVault(vault)
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "xml", "a.xml", "a_sanitized.xml")
+	require.NoError(t, err)
+	assert.NotContains(t, sanitizedContent, "y<")
+	desanitizedContent, _, _, err := sanitizer.Desanitize(sanitizedContent, "xml", "a_sanitized.xml", "a_desanitized.xml", vault)
+	require.NoError(t, err)
+	assert.Equal(t, content, desanitizedContent)
+}
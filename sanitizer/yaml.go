@@ -0,0 +1,851 @@
+package sanitizer
+
+//goland:noinspection GoUnsortedImport
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxYamlMergeDepth is the maximum depth of the mappings merged into a mapping (with <<) through other merged mappings.
+const maxYamlMergeDepth = 64
+
+// yamlValue is a value of a YAML document.
+type yamlValue struct {
+	// entry is the node of the value in its mapping or sequence, which is an alias node if the value is an alias.
+	entry *yaml.Node
+	// node is the node of the value, with the alias resolved.
+	node *yaml.Node
+}
+
+// yamlContent is YAML content, with a document for each document of the stream.
+//
+// The rules are evaluated against the documents decoded like JSON: mappings are objects keyed by the text of their keys
+// (including the keys merged with <<), and aliases are replaced with the values of their anchors. A value reached
+// through an alias is replaced where its anchor is defined, so every alias of it gets the replacement, and the
+// sanitized content retains the comments, anchors and key order of the content.
+type yamlContent struct {
+	content string
+	// nodes are the document nodes of the stream.
+	nodes     []*yaml.Node
+	documents []document
+	// values are the values of each document, keyed by their JSON paths.
+	values []map[string]yamlValue
+}
+
+// parseYamlContent parses the YAML content into a document for each document of the stream.
+func parseYamlContent(content string) (*yamlContent, error) {
+	nodes, err := parseYamlNodes(content)
+	if err != nil {
+		return nil, err
+	}
+	yamlContent := &yamlContent{content: content, nodes: nodes}
+	for _, node := range nodes {
+		converter := newYamlConverter(content)
+		converter.values = map[string]yamlValue{}
+		converter.scalarTexts = map[string]string{}
+		converter.valueLines = map[string]int{}
+		tree, err := converter.convert(node, "$")
+		if err != nil {
+			return nil, err
+		}
+		document := &jsonDocument{content: content, tree: tree, valueLines: converter.valueLines}
+		// The texts of the scalars are collected along with the tree.
+		document.scalarTextsOnce.Do(func() {
+			document.scalarTexts = converter.scalarTexts
+		})
+		yamlContent.documents = append(yamlContent.documents, document)
+		yamlContent.values = append(yamlContent.values, converter.values)
+	}
+	return yamlContent, nil
+}
+
+// parseYamlNodes parses the document nodes of the YAML stream.
+func parseYamlNodes(content string) ([]*yaml.Node, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	nodes := make([]*yaml.Node, 0, 1)
+	for {
+		node := &yaml.Node{}
+		if err := decoder.Decode(node); errors.Is(err, io.EOF) {
+			return nodes, nil
+		} else if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+func (content *yamlContent) getDocuments() []document {
+	return content.documents
+}
+
+// setValues writes the replacements in the content, editing only the text of the replaced and deleted values. If the
+// edited content doesn't decode to the expected values (Ex: a multi-line plain scalar that can't be located in the
+// text), the documents are encoded again with the replacements instead, which retains their comments but not all of
+// their formatting.
+func (content *yamlContent) setValues(replacementMaps []map[string]jsonValueReplacement) (string, error) {
+	var errs []error
+	replacedNodes := make([]*yaml.Node, 0)
+	rawValues := map[*yaml.Node]string{}
+	deletedEntries := make([]*yaml.Node, 0)
+	for documentIndex, replacementMap := range replacementMaps {
+		jsonPaths := make([]string, 0, len(replacementMap))
+		for jsonPath := range replacementMap {
+			jsonPaths = append(jsonPaths, jsonPath)
+		}
+		slices.Sort(jsonPaths)
+		for _, jsonPath := range jsonPaths {
+			replacement := replacementMap[jsonPath]
+			value, isPresent := content.values[documentIndex][jsonPath]
+			if !isPresent {
+				errs = append(errs, &PathWriteError{JsonPath: jsonPath, Err: errors.New("no value at the JSON path")})
+			} else if replacement.Delete && jsonPath == "$" {
+				errs = append(errs, &PathWriteError{JsonPath: jsonPath, Err: errors.New("cannot delete the root value")})
+			} else if replacement.Delete {
+				if !slices.Contains(deletedEntries, value.entry) {
+					deletedEntries = append(deletedEntries, value.entry)
+				}
+			} else if _, isReplaced := rawValues[value.node]; !isReplaced {
+				// A value reached through multiple aliases gets the replacement of its first JSON path.
+				replacedNodes = append(replacedNodes, value.node)
+				rawValues[value.node] = replacement.RawValue
+			}
+		}
+	}
+	if len(replacedNodes) == 0 && len(deletedEntries) == 0 {
+		return content.content, errors.Join(errs...)
+	}
+
+	text := newYamlText(content.content, content.nodes)
+	// Deleting all the entries of a mapping or sequence would leave it empty (i.e. null), so it's replaced with an empty
+	// mapping or sequence instead.
+	deletedEntryCounts := map[*yaml.Node]int{}
+	for _, entry := range deletedEntries {
+		deletedEntryCounts[text.parents[entry]]++
+	}
+	deletedEntries = slices.DeleteFunc(deletedEntries, func(entry *yaml.Node) bool {
+		parent := text.parents[entry]
+		entryCount := len(parent.Content)
+		if parent.Kind == yaml.MappingNode {
+			entryCount /= 2
+		}
+		if deletedEntryCounts[parent] < entryCount {
+			return false
+		}
+		if _, isReplaced := rawValues[parent]; !isReplaced {
+			replacedNodes = append(replacedNodes, parent)
+			rawValues[parent] = map[yaml.Kind]string{yaml.MappingNode: "{}", yaml.SequenceNode: "[]"}[parent.Kind]
+		}
+		return true
+	})
+
+	edits, isEdited := text.getEdits(replacedNodes, rawValues, deletedEntries)
+	for _, node := range replacedNodes {
+		if err := setYamlNodeValue(node, rawValues[node]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, entry := range deletedEntries {
+		deleteYamlEntry(text.parents[entry], entry)
+	}
+	if isEdited {
+		editedContent := applyJsonEdits(content.content, edits)
+		if isEqual, err := isYamlContentEqual(editedContent, content.nodes); err == nil && isEqual {
+			return editedContent, errors.Join(errs...)
+		}
+	}
+	encodedContent, err := encodeYamlNodes(content.nodes, detectYamlIndent(content.nodes))
+	if err != nil {
+		return content.content, errors.Join(append(errs, err)...)
+	}
+	return string(encodedContent), errors.Join(errs...)
+}
+
+// format formats the sanitized content. The pretty and minify output formats encode the documents again, with the
+// indent or in the flow style (without the comments) respectively.
+func (content *yamlContent) format(sanitizedContent string, outputFormat string, indent string) ([]byte, error) {
+	if outputFormat == OutputFormatPreserve {
+		return []byte(sanitizedContent), nil
+	} else if outputFormat != OutputFormatPretty && outputFormat != OutputFormatMinify && outputFormat != "" {
+		return nil, errors.New("unsupported output format (" + outputFormat + ")")
+	}
+	nodes, err := parseYamlNodes(sanitizedContent)
+	if err != nil {
+		return nil, err
+	}
+	if outputFormat == OutputFormatMinify {
+		for _, node := range nodes {
+			setYamlFlowStyle(node)
+		}
+	}
+	if indent == "" {
+		indent = defaultOutputIndent
+	}
+	if strings.Trim(indent, " ") != "" {
+		return nil, fmt.Errorf("YAML content can only be indented with spaces (indent %q)", indent)
+	}
+	return encodeYamlNodes(nodes, len(indent))
+}
+
+// yamlConverter converts the nodes of a YAML document into values like the ones decoded by encoding/json.
+type yamlConverter struct {
+	// values, scalarTexts and valueLines are keyed by the JSON paths of the values, and are only recorded if they're set.
+	values      map[string]yamlValue
+	scalarTexts map[string]string
+	valueLines  map[string]int
+	// aliases are the aliases being resolved, to detect the ones within their anchor.
+	aliases []*yaml.Node
+	// remainingValueCount limits the number of values the aliases expand to.
+	remainingValueCount int
+}
+
+func newYamlConverter(content string) *yamlConverter {
+	return &yamlConverter{remainingValueCount: 100*len(content) + 10000}
+}
+
+// convert converts the node, which is at the JSON path.
+func (converter *yamlConverter) convert(entry *yaml.Node, jsonPath string) (interface{}, error) {
+	if converter.remainingValueCount--; converter.remainingValueCount < 0 {
+		return nil, errors.New("the aliases expand to too many values")
+	}
+	if entry.Kind == yaml.DocumentNode {
+		if len(entry.Content) == 0 {
+			return nil, nil
+		}
+		entry = entry.Content[0]
+	}
+	node := entry
+	if node.Kind == yaml.AliasNode {
+		if slices.Contains(converter.aliases, node) {
+			return nil, fmt.Errorf("the alias at line %d is within its anchor", node.Line)
+		}
+		converter.aliases = append(converter.aliases, node)
+		defer func() { converter.aliases = converter.aliases[:len(converter.aliases)-1] }()
+		node = node.Alias
+	}
+	if converter.values != nil {
+		converter.values[jsonPath] = yamlValue{entry: entry, node: node}
+		converter.valueLines[jsonPath] = node.Line
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, err := getYamlScalarValue(node)
+		if _, isString := value.(string); !isString && value != nil && converter.scalarTexts != nil {
+			converter.scalarTexts[jsonPath] = node.Value
+		}
+		return value, err
+	case yaml.MappingNode:
+		entries, err := getYamlMappingEntries(node, 0)
+		if err != nil {
+			return nil, err
+		}
+		object := make(map[string]interface{}, len(entries)/2)
+		for index := 0; index < len(entries); index += 2 {
+			keyNode := resolveYamlAlias(entries[index])
+			if keyNode.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("unsupported key at line %d, only scalar keys are supported", keyNode.Line)
+			}
+			// The first entry of a key takes precedence, like the mapping's entries over the merged ones.
+			if _, isPresent := object[keyNode.Value]; isPresent {
+				continue
+			}
+			if object[keyNode.Value], err = converter.convert(entries[index+1], appendJsonPathSegment(jsonPath, keyNode.Value)); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	case yaml.SequenceNode:
+		array := make([]interface{}, len(node.Content))
+		for index, item := range node.Content {
+			var err error
+			if array[index], err = converter.convert(item, appendJsonPathSegment(jsonPath, strconv.Itoa(index))); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	}
+	return nil, fmt.Errorf("unsupported node at line %d", node.Line)
+}
+
+// getYamlScalarValue returns the value of the scalar node. Integers and floats are float64 values, and the values of
+// the other tags (Ex: !!timestamp) are their text.
+func getYamlScalarValue(node *yaml.Node) (interface{}, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var value bool
+		err := node.Decode(&value)
+		return value, err
+	case "!!int", "!!float":
+		var value float64
+		err := node.Decode(&value)
+		return value, err
+	}
+	return node.Value, nil
+}
+
+// getYamlMappingEntries returns the key and value nodes of the mapping's entries, followed by the entries of the
+// mappings merged into it (with <<) in their order.
+func getYamlMappingEntries(mapping *yaml.Node, depth int) ([]*yaml.Node, error) {
+	if depth > maxYamlMergeDepth {
+		return nil, fmt.Errorf("too many merged mappings at line %d", mapping.Line)
+	}
+	entries := make([]*yaml.Node, 0, len(mapping.Content))
+	mergedNodes := make([]*yaml.Node, 0)
+	for index := 0; index+1 < len(mapping.Content); index += 2 {
+		if mapping.Content[index].Kind == yaml.ScalarNode && mapping.Content[index].Tag == "!!merge" {
+			mergedNodes = append(mergedNodes, resolveYamlAlias(mapping.Content[index+1]))
+		} else {
+			entries = append(entries, mapping.Content[index], mapping.Content[index+1])
+		}
+	}
+	for _, mergedNode := range mergedNodes {
+		sources := []*yaml.Node{mergedNode}
+		if mergedNode.Kind == yaml.SequenceNode {
+			sources = mergedNode.Content
+		}
+		for _, source := range sources {
+			source = resolveYamlAlias(source)
+			if source.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("the value merged at line %d isn't a mapping", source.Line)
+			}
+			sourceEntries, err := getYamlMappingEntries(source, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, sourceEntries...)
+		}
+	}
+	return entries, nil
+}
+
+func resolveYamlAlias(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		return node.Alias
+	}
+	return node
+}
+
+// isYamlContentEqual returns whether the content decodes to the same values as the document nodes.
+func isYamlContentEqual(content string, nodes []*yaml.Node) (bool, error) {
+	contentNodes, err := parseYamlNodes(content)
+	if err != nil || len(contentNodes) != len(nodes) {
+		return false, err
+	}
+	for index := range nodes {
+		contentTree, err := newYamlConverter(content).convert(contentNodes[index], "$")
+		if err != nil {
+			return false, err
+		}
+		tree, err := newYamlConverter(content).convert(nodes[index], "$")
+		if err != nil || !reflect.DeepEqual(contentTree, tree) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// setYamlNodeValue replaces the node with the raw JSON value, retaining its anchor and comments.
+// Strings retain the quoting style of the node, if it's a quoted string.
+func setYamlNodeValue(node *yaml.Node, rawValue string) error {
+	var value interface{}
+	if err := json.Unmarshal([]byte(rawValue), &value); err != nil {
+		return err
+	}
+	replacementNode := yaml.Node{
+		Kind:        yaml.ScalarNode,
+		Value:       rawValue,
+		Anchor:      node.Anchor,
+		HeadComment: node.HeadComment,
+		LineComment: node.LineComment,
+		FootComment: node.FootComment,
+		Line:        node.Line,
+		Column:      node.Column,
+	}
+	switch typedValue := value.(type) {
+	case string:
+		replacementNode.Tag = "!!str"
+		replacementNode.Value = typedValue
+		if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!str" {
+			replacementNode.Style = node.Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+		}
+	case nil:
+		replacementNode.Tag = "!!null"
+	case bool:
+		replacementNode.Tag = "!!bool"
+	case float64:
+		replacementNode.Tag = "!!float"
+		if _, err := strconv.ParseInt(rawValue, 10, 64); err == nil {
+			replacementNode.Tag = "!!int"
+		}
+	case map[string]interface{}:
+		replacementNode = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle, Anchor: node.Anchor, LineComment: node.LineComment}
+	case []interface{}:
+		replacementNode = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle, Anchor: node.Anchor, LineComment: node.LineComment}
+	}
+	*node = replacementNode
+	return nil
+}
+
+// deleteYamlEntry deletes the value node (along with its key in a mapping) from the mapping or sequence.
+func deleteYamlEntry(parent *yaml.Node, entry *yaml.Node) {
+	index := slices.Index(parent.Content, entry)
+	if index < 0 {
+		// The parent is replaced.
+		return
+	}
+	if parent.Kind == yaml.MappingNode {
+		parent.Content = slices.Delete(parent.Content, index-1, index+1)
+	} else {
+		parent.Content = slices.Delete(parent.Content, index, index+1)
+	}
+}
+
+// setYamlFlowStyle sets the flow style of the collections under the node, and removes their comments as they can't be
+// written within a flow collection.
+func setYamlFlowStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style |= yaml.FlowStyle
+	}
+	if node.Kind != yaml.DocumentNode {
+		node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	}
+	for _, child := range node.Content {
+		setYamlFlowStyle(child)
+	}
+}
+
+// detectYamlIndent returns the smallest indent of the block mappings within block mappings, or 2 if there are none.
+func detectYamlIndent(nodes []*yaml.Node) int {
+	indent := 0
+	var detect func(node *yaml.Node)
+	detect = func(node *yaml.Node) {
+		for index, child := range node.Content {
+			if node.Kind == yaml.MappingNode && index%2 == 1 && child.Kind == yaml.MappingNode && child.Style&yaml.FlowStyle == 0 {
+				if childIndent := child.Column - node.Content[index-1].Column; childIndent > 0 && (indent == 0 || childIndent < indent) {
+					indent = childIndent
+				}
+			}
+			detect(child)
+		}
+	}
+	for _, node := range nodes {
+		detect(node)
+	}
+	if indent == 0 {
+		return 2
+	}
+	return indent
+}
+
+func encodeYamlNodes(nodes []*yaml.Node, indent int) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(indent)
+	for _, node := range nodes {
+		if err := encoder.Encode(node); err != nil {
+			return nil, err
+		}
+	}
+	err := encoder.Close()
+	return out.Bytes(), err
+}
+
+// yamlText locates the nodes of YAML content in its text, to edit it.
+type yamlText struct {
+	content string
+	// lineStarts are the offsets of the lines of the content.
+	lineStarts []int
+	// parents are the mappings or sequences of the nodes, or their document for the root nodes.
+	parents map[*yaml.Node]*yaml.Node
+}
+
+func newYamlText(content string, nodes []*yaml.Node) *yamlText {
+	text := &yamlText{content: content, lineStarts: []int{0}, parents: map[*yaml.Node]*yaml.Node{}}
+	for index := range len(content) {
+		if content[index] == '\n' {
+			text.lineStarts = append(text.lineStarts, index+1)
+		}
+	}
+	var recordParents func(node *yaml.Node)
+	recordParents = func(node *yaml.Node) {
+		for _, child := range node.Content {
+			text.parents[child] = node
+			recordParents(child)
+		}
+	}
+	for _, node := range nodes {
+		recordParents(node)
+	}
+	return text
+}
+
+// getEdits returns the edits replacing the nodes with their raw JSON values and deleting the entries, and whether they
+// could all be located in the text. Edits within a replaced or deleted value are dropped.
+func (text *yamlText) getEdits(replacedNodes []*yaml.Node, rawValues map[*yaml.Node]string, deletedEntries []*yaml.Node) ([]jsonEdit, bool) {
+	edits := make([]jsonEdit, 0, len(replacedNodes)+len(deletedEntries))
+	for _, node := range replacedNodes {
+		edit, isLocated := text.getReplacementEdit(node, rawValues[node])
+		if !isLocated {
+			return nil, false
+		}
+		edits = append(edits, edit)
+	}
+	for _, entry := range deletedEntries {
+		edit, isLocated := text.getDeletionEdit(entry)
+		if !isLocated {
+			return nil, false
+		}
+		edits = append(edits, edit)
+	}
+	slices.SortFunc(edits, func(a jsonEdit, b jsonEdit) int {
+		if a.Start != b.Start {
+			return a.Start - b.Start
+		}
+		return b.End - a.End
+	})
+	nonOverlappingEdits := make([]jsonEdit, 0, len(edits))
+	for _, edit := range edits {
+		if len(nonOverlappingEdits) == 0 || edit.Start >= nonOverlappingEdits[len(nonOverlappingEdits)-1].End {
+			nonOverlappingEdits = append(nonOverlappingEdits, edit)
+		} else if edit.End > nonOverlappingEdits[len(nonOverlappingEdits)-1].End {
+			return nil, false
+		}
+	}
+	return nonOverlappingEdits, true
+}
+
+// getReplacementEdit returns the edit replacing the node with the raw JSON value, which is valid YAML.
+// Strings are written in the style of the node if possible. String replacements of string scalars retain the node's
+// properties (its anchor and tag), and the other replacements only retain its anchor.
+func (text *yamlText) getReplacementEdit(node *yaml.Node, rawValue string) (jsonEdit, bool) {
+	start, valueStart, isLocated := text.getStart(node)
+	if !isLocated {
+		return jsonEdit{}, false
+	}
+	isInFlow := text.isInFlow(node)
+	end, isLocated := text.getEnd(node, isInFlow)
+	if !isLocated {
+		return jsonEdit{}, false
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(rawValue), &value); err != nil {
+		return jsonEdit{}, false
+	}
+	replacementText := rawValue
+	if stringValue, isString := value.(string); isString {
+		replacementText = formatYamlString(stringValue, rawValue, node.Style, isInFlow)
+		if node.Kind == yaml.ScalarNode && (node.ShortTag() == "!!str" || !strings.HasPrefix(node.ShortTag(), "!!")) {
+			return jsonEdit{Start: valueStart, End: end, Text: replacementText}, true
+		}
+	}
+	if node.Anchor != "" {
+		replacementText = "&" + node.Anchor + " " + replacementText
+	}
+	if start != valueStart || node.Kind == yaml.ScalarNode || node.Style&yaml.FlowStyle != 0 {
+		return jsonEdit{Start: start, End: end, Text: replacementText}, true
+	}
+
+	// A block mapping or sequence without properties starts at its first entry, so it's replaced from the indicator of
+	// its entry in the parent.
+	parent := text.parents[node]
+	switch parent.Kind {
+	case yaml.DocumentNode:
+		return jsonEdit{Start: start, End: end, Text: replacementText}, true
+	case yaml.MappingNode:
+		keyEnd, isLocated := text.getEnd(parent.Content[slices.Index(parent.Content, node)-1], false)
+		colonIndex := keyEnd + len(text.content[keyEnd:]) - len(strings.TrimLeft(text.content[keyEnd:], " \t"))
+		if !isLocated || !strings.HasPrefix(text.content[colonIndex:], ":") {
+			return jsonEdit{}, false
+		}
+		return jsonEdit{Start: colonIndex + 1, End: end, Text: " " + replacementText}, true
+	case yaml.SequenceNode:
+		dashIndex := len(strings.TrimRight(text.content[:start], " \t")) - 1
+		if dashIndex < 0 || text.content[dashIndex] != '-' {
+			return jsonEdit{}, false
+		}
+		return jsonEdit{Start: dashIndex + 1, End: end, Text: " " + replacementText}, true
+	}
+	return jsonEdit{}, false
+}
+
+// getDeletionEdit returns the edit deleting the entry from its mapping (along with its key) or sequence.
+// Entries of block mappings and sequences are deleted along with their lines.
+func (text *yamlText) getDeletionEdit(entry *yaml.Node) (jsonEdit, bool) {
+	parent := text.parents[entry]
+	index := slices.Index(parent.Content, entry)
+	firstNode := entry
+	previousEntryIndex := index - 1
+	if parent.Kind == yaml.MappingNode {
+		firstNode = parent.Content[index-1]
+		previousEntryIndex = index - 2
+	}
+	start, _, isStartLocated := text.getStart(firstNode)
+	end, isEndLocated := text.getEnd(entry, parent.Style&yaml.FlowStyle != 0)
+	if !isStartLocated || !isEndLocated {
+		return jsonEdit{}, false
+	}
+	nextStart := -1
+	if index+1 < len(parent.Content) {
+		nextStart, _, _ = text.getStart(parent.Content[index+1])
+	}
+
+	if parent.Style&yaml.FlowStyle != 0 {
+		if nextStart >= 0 {
+			return jsonEdit{Start: start, End: nextStart}, true
+		} else if previousEntryIndex < 0 {
+			return jsonEdit{}, false
+		}
+		previousEnd, isLocated := text.getEnd(parent.Content[previousEntryIndex], true)
+		return jsonEdit{Start: previousEnd, End: end}, isLocated
+	}
+	lineStart := text.lineStarts[firstNode.Line-1]
+	linePrefix := strings.TrimSpace(text.content[lineStart:start])
+	if linePrefix == "" || (parent.Kind == yaml.SequenceNode && linePrefix == "-") {
+		lineEnd, isLocated := text.getLineEnd(end)
+		return jsonEdit{Start: lineStart, End: lineEnd}, isLocated
+	} else if parent.Kind == yaml.MappingNode && nextStart >= 0 {
+		// The first entry of a mapping in a sequence (Ex: - a: 1) is followed by the next entry.
+		return jsonEdit{Start: start, End: nextStart}, true
+	}
+	return jsonEdit{}, false
+}
+
+// isInFlow returns whether the node is within a flow mapping or sequence.
+func (text *yamlText) isInFlow(node *yaml.Node) bool {
+	parent := text.parents[node]
+	return parent != nil && parent.Kind != yaml.DocumentNode && parent.Style&yaml.FlowStyle != 0
+}
+
+// getOffset returns the offset of the line and (1 based) column in the content.
+func (text *yamlText) getOffset(line int, column int) (int, bool) {
+	if line < 1 || line > len(text.lineStarts) {
+		return 0, false
+	}
+	offset := text.lineStarts[line-1]
+	for range column - 1 {
+		if offset >= len(text.content) || text.content[offset] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRuneInString(text.content[offset:])
+		offset += size
+	}
+	return offset, true
+}
+
+// getStart returns the offsets of the node (from its properties, if it has any) and of its value in the content.
+func (text *yamlText) getStart(node *yaml.Node) (int, int, bool) {
+	start, isLocated := text.getOffset(node.Line, node.Column)
+	if !isLocated {
+		return 0, 0, false
+	}
+	valueStart := start
+	for valueStart < len(text.content) && (text.content[valueStart] == '&' || text.content[valueStart] == '!') {
+		propertyLength := strings.IndexAny(text.content[valueStart:], " \t\r\n,[]{}")
+		if strings.HasPrefix(text.content[valueStart:], "!<") {
+			propertyLength = strings.IndexByte(text.content[valueStart:], '>') + 1
+		}
+		if propertyLength <= 0 {
+			propertyLength = len(text.content) - valueStart
+		}
+		valueStart += propertyLength
+		for valueStart < len(text.content) && (text.content[valueStart] == ' ' || text.content[valueStart] == '\t') {
+			valueStart++
+		}
+	}
+	return start, valueStart, true
+}
+
+// getEnd returns the offset of the end of the node in the content.
+func (text *yamlText) getEnd(node *yaml.Node, isInFlow bool) (int, bool) {
+	_, valueStart, isLocated := text.getStart(node)
+	if !isLocated {
+		return 0, false
+	}
+	content := text.content
+	switch {
+	case node.Kind == yaml.AliasNode:
+		return valueStart + 1 + len(node.Value), strings.HasPrefix(content[valueStart:], "*"+node.Value)
+	case node.Kind != yaml.ScalarNode && node.Style&yaml.FlowStyle != 0:
+		return text.getFlowCollectionEnd(valueStart)
+	case node.Kind != yaml.ScalarNode:
+		if len(node.Content) == 0 {
+			return 0, false
+		}
+		return text.getEnd(node.Content[len(node.Content)-1], false)
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		return getYamlQuotedEnd(content, valueStart, '"')
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		return getYamlQuotedEnd(content, valueStart, '\'')
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return text.getBlockScalarEnd(valueStart)
+	}
+	// Plain scalars end before a comment, the end of the line or an indicator. Multi-line plain scalars aren't located.
+	end := valueStart
+	for ; end < len(content) && content[end] != '\n' && content[end] != '\r'; end++ {
+		isNextWhitespace := end+1 == len(content) || strings.IndexByte(" \t\r\n", content[end+1]) >= 0
+		if (content[end] == ' ' || content[end] == '\t') && end+1 < len(content) && content[end+1] == '#' {
+			break
+		} else if content[end] == ':' && (isNextWhitespace || (isInFlow && strings.IndexByte(",[]{}", content[end+1]) >= 0)) {
+			break
+		} else if isInFlow && strings.IndexByte(",[]{}", content[end]) >= 0 {
+			break
+		}
+	}
+	end = valueStart + len(strings.TrimRight(content[valueStart:end], " \t"))
+	return end, content[valueStart:end] == node.Value
+}
+
+// getYamlQuotedEnd returns the offset after the closing quote of the quoted scalar at the start.
+func getYamlQuotedEnd(content string, start int, quote byte) (int, bool) {
+	if start >= len(content) || content[start] != quote {
+		return 0, false
+	}
+	for offset := start + 1; offset < len(content); offset++ {
+		if quote == '"' && content[offset] == '\\' {
+			offset++
+		} else if content[offset] == quote && quote == '\'' && offset+1 < len(content) && content[offset+1] == '\'' {
+			offset++
+		} else if content[offset] == quote {
+			return offset + 1, true
+		}
+	}
+	return 0, false
+}
+
+// getBlockScalarEnd returns the offset of the end of the last non-empty line of the literal or folded scalar at the
+// start, whose content lines are indented more than the line of its header.
+func (text *yamlText) getBlockScalarEnd(start int) (int, bool) {
+	content := text.content
+	if start >= len(content) || (content[start] != '|' && content[start] != '>') {
+		return 0, false
+	}
+	headerEnd := strings.IndexByte(content[start:], '\n')
+	if headerEnd < 0 {
+		return len(content), true
+	}
+	headerEnd += start
+	if header, _, _ := strings.Cut(content[start:headerEnd], " "); strings.ContainsAny(header, "123456789") {
+		// Explicit indentation indicators aren't supported.
+		return 0, false
+	}
+	headerLineStart := strings.LastIndexByte(content[:start], '\n') + 1
+	headerIndent := len(content[headerLineStart:start]) - len(strings.TrimLeft(content[headerLineStart:start], " "))
+	end := start + len(strings.TrimRight(content[start:headerEnd], "\r"))
+	indent := -1
+	for lineStart := headerEnd + 1; lineStart < len(content); {
+		lineEnd := strings.IndexByte(content[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += lineStart
+		}
+		line := strings.TrimRight(content[lineStart:lineEnd], "\r")
+		lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.TrimSpace(line) != "" {
+			if indent < 0 {
+				indent = lineIndent
+			}
+			if lineIndent < indent || lineIndent <= headerIndent {
+				break
+			}
+			end = lineStart + len(line)
+		}
+		lineStart = lineEnd + 1
+	}
+	return end, true
+}
+
+// getFlowCollectionEnd returns the offset after the closing bracket of the flow mapping or sequence at the start.
+func (text *yamlText) getFlowCollectionEnd(start int) (int, bool) {
+	content := text.content
+	if start >= len(content) || (content[start] != '[' && content[start] != '{') {
+		return 0, false
+	}
+	depth := 0
+	// previousChar is the previous character other than whitespace, as quotes only start a scalar after an indicator.
+	previousChar := byte(0)
+	for offset := start; offset < len(content); offset++ {
+		char := content[offset]
+		switch {
+		case char == '[' || char == '{':
+			depth++
+		case char == ']' || char == '}':
+			if depth--; depth == 0 {
+				return offset + 1, true
+			}
+		case (char == '"' || char == '\'') && strings.IndexByte("[{,:", previousChar) >= 0:
+			end, isLocated := getYamlQuotedEnd(content, offset, char)
+			if !isLocated {
+				return 0, false
+			}
+			offset = end - 1
+		case char == '#' && strings.IndexByte(" \t\r\n", content[offset-1]) >= 0:
+			if lineEnd := strings.IndexByte(content[offset:], '\n'); lineEnd >= 0 {
+				offset += lineEnd
+			} else {
+				offset = len(content)
+			}
+			continue
+		}
+		if strings.IndexByte(" \t\r\n", char) < 0 {
+			previousChar = char
+		}
+	}
+	return 0, false
+}
+
+// getLineEnd returns the offset after the line break of the line, if it only has whitespace and a comment after the
+// offset.
+func (text *yamlText) getLineEnd(offset int) (int, bool) {
+	rest := strings.TrimLeft(text.content[offset:], " \t")
+	if strings.HasPrefix(rest, "#") {
+		commentEnd := strings.IndexByte(rest, '\n')
+		if commentEnd < 0 {
+			commentEnd = len(rest)
+		}
+		rest = rest[commentEnd:]
+	}
+	rest = strings.TrimPrefix(rest, "\r")
+	if rest == "" {
+		return len(text.content), true
+	} else if rest[0] != '\n' {
+		return 0, false
+	}
+	return len(text.content) - len(rest) + 1, true
+}
+
+// formatYamlString returns the YAML text of the string, in the style of the replaced node if possible: plain if the
+// string is read back as the same string, single-quoted, or as the double-quoted JSON string.
+func formatYamlString(value string, jsonString string, style yaml.Style, isInFlow bool) string {
+	if style&yaml.DoubleQuotedStyle != 0 || strings.ContainsFunc(value, func(char rune) bool {
+		return char < ' ' || char == 0x7f || char == utf8.RuneError
+	}) {
+		return jsonString
+	}
+	if style&yaml.SingleQuotedStyle == 0 && isYamlPlainString(value, isInFlow) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// isYamlPlainString returns whether the string can be written as a plain scalar, which is read back as the same string.
+func isYamlPlainString(value string, isInFlow bool) bool {
+	if value == "" || value != strings.TrimSpace(value) || strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", value[0]) >= 0 ||
+		strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.HasSuffix(value, ":") ||
+		(isInFlow && strings.ContainsAny(value, ",[]{}")) {
+		return false
+	}
+	var decodedValue interface{}
+	return yaml.Unmarshal([]byte(value), &decodedValue) == nil && decodedValue == value
+}
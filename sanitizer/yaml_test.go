@@ -0,0 +1,213 @@
+package sanitizer
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeYamlPreservesFormatting(t *testing.T) {
+	content := `# Deployment settings.
+name: app # The name.
+db:
+  user: admin
+  password: "hunter2" # Rotated monthly.
+  port: 5432
+tokens:
+  - abc
+  - 'def'
+note: |
+  keep
+  this
+`
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{
+		`$["db"]["password"]`: {Action: ActionRemove},
+		`$["db"]["port"]`:     {Action: ActionNull},
+		`$["tokens"][*]`:      {Action: ActionMask},
+		`$["note"]`:           {Action: ActionRemove},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "yaml", "a.yaml", "a_sanitized.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, `# Deployment settings.
+name: app # The name.
+db:
+  user: admin
+  password: "<REMOVED>" # Rotated monthly.
+  port: null
+tokens:
+  - '***'
+  - '***'
+note: <REMOVED>
+`, result.Content)
+
+	lines := map[string]int{}
+	for _, finding := range result.Report.Findings {
+		lines[finding.JsonPath] = finding.Line
+	}
+	assert.Equal(t, map[string]int{
+		`$["db"]["password"]`: 5,
+		`$["db"]["port"]`:     6,
+		`$["tokens"]["0"]`:    8,
+		`$["tokens"]["1"]`:    9,
+		`$["note"]`:           10,
+	}, lines)
+}
+
+func TestSanitizeYamlAnchorsAndAliases(t *testing.T) {
+	content := `defaults: &defaults
+  password: secret
+  retries: 3
+prod:
+  <<: *defaults
+  host: prod
+token: &token abc
+copy: *token
+`
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{
+		`$..password`: {Action: ActionRemove},
+		`$["copy"]`:   {Action: ActionMask},
+	}})
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "yaml", "a.yaml", "a_sanitized.yaml")
+	require.NoError(t, err)
+	// The replacements are written at the anchors, so they apply to the aliases too.
+	assert.Equal(t, `defaults: &defaults
+  password: <REMOVED>
+  retries: 3
+prod:
+  <<: *defaults
+  host: prod
+token: &token '***'
+copy: *token
+`, sanitizedContent)
+}
+
+func TestSanitizeYamlDeletions(t *testing.T) {
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{
+		`$..secret`:    {Action: ActionDelete},
+		`$["list"][1]`: {Action: ActionDelete},
+	}})
+	for content, expectedContent := range map[string]string{
+		"a: 1\nsecret: x # Comment.\nb: 2\n":                       "a: 1\nb: 2\n",
+		"a:\n  secret:\n    nested: x\n  b: 2\n":                   "a:\n  b: 2\n",
+		"a: {secret: x, b: 2}\n":                                   "a: {b: 2}\n",
+		"a: {b: 2, secret: x}\n":                                   "a: {b: 2}\n",
+		"a:\n  secret: x\nb: 1\n":                                  "a: {}\nb: 1\n",
+		"list:\n  - a\n  - b\n  - c\n":                             "list:\n  - a\n  - c\n",
+		"list: [a, b, c]\n":                                        "list: [a, c]\n",
+		"items:\n  - secret: x\n    a: 1\n  - {b: 2, secret: y}\n": "items:\n  - a: 1\n  - {b: 2}\n",
+	} {
+		sanitizedContent, _, _, err := sanitizer.Sanitize(content, "yaml", "a.yaml", "a_sanitized.yaml")
+		require.NoError(t, err, content)
+		assert.Equal(t, expectedContent, sanitizedContent, content)
+	}
+}
+
+func TestSanitizeYamlScalarStyles(t *testing.T) {
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{
+		"token": {Action: ActionRemove, Pattern: `tok_\w+`, Partial: true},
+	}})
+	content := `a: tok_1
+b: "x tok_2"
+c: 'it''s tok_3'
+d: !!str tok_4
+e: >
+  folded tok_5
+f: 1
+`
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "yaml", "a.yaml", "a_sanitized.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, `a: <REMOVED>
+b: "x <REMOVED>"
+c: 'it''s <REMOVED>'
+d: !!str <REMOVED>
+e: "folded <REMOVED>\n"
+f: 1
+`, sanitizedContent)
+}
+
+func TestSanitizeYamlMultipleDocuments(t *testing.T) {
+	content := `token: a
+---
+# Second document.
+token: b
+other: 1
+`
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{
+		`$["token"]`: {Action: ActionContextualReplacement},
+		`$["other"]`: {Action: ActionRemove},
+		`$["none"]`:  {Action: ActionRemove},
+	}})
+	result, err := sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), content, "yaml", "a.yaml", "a_sanitized.yaml")
+	require.NoError(t, err)
+	require.Len(t, result.Report.Findings, 3)
+	assert.Equal(t, []int{1, 4, 5}, []int{result.Report.Findings[0].Line, result.Report.Findings[1].Line, result.Report.Findings[2].Line})
+	assert.Contains(t, result.Content, "\n---\n# Second document.\ntoken: secret_")
+	assert.Equal(t, []UnmatchedRule{{"a.yaml", `$["none"]`}}, result.Report.UnmatchedRules)
+
+	// The rules are evaluated against each document, so identical values get the same replacement.
+	result, err = sanitizer.SanitizeWithReport(sanitizer.NewReplacementContext(), "token: a\n---\ntoken: a\n", "yaml", "a.yaml", "a_sanitized.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, result.Report.Findings[0].Replacement, result.Report.Findings[1].Replacement)
+}
+
+func TestSanitizeYamlReencodesWhenEditsDontApply(t *testing.T) {
+	// A plain scalar spanning multiple lines can't be located exactly, so the document is encoded again.
+	content := "a: first\n  second\nb: 1\n"
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{`$["a"]`: {Action: ActionRemove}}})
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "yaml", "a.yaml", "a_sanitized.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "a: <REMOVED>\nb: 1\n", sanitizedContent)
+}
+
+func TestSanitizeYamlOutputFormats(t *testing.T) {
+	content := "a:   # Comment.\n    b: x\n    c: [1,   2]\n"
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{`$["a"]["b"]`: {Action: ActionRemove}}})
+	for outputFormat, expectedContent := range map[string]string{
+		OutputFormatPreserve: "a:   # Comment.\n    b: <REMOVED>\n    c: [1,   2]\n",
+		OutputFormatPretty:   "a: # Comment.\n  b: <REMOVED>\n  c: [1, 2]\n",
+		OutputFormatMinify:   "{a: {b: <REMOVED>, c: [1, 2]}}\n",
+	} {
+		sanitizer.config.OutputFormat = outputFormat
+		sanitizedContent, _, _, err := sanitizer.Sanitize(content, "yaml", "a.yaml", "a_sanitized.yaml")
+		require.NoError(t, err, outputFormat)
+		assert.Equal(t, expectedContent, sanitizedContent, outputFormat)
+	}
+}
+
+func TestDesanitizeYaml(t *testing.T) {
+	content := "# Comment.\ntoken: abc\nurl: https://x?key=def\n"
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{
+		`$["token"]`: {Action: ActionContextualReplacement},
+		"key":        {Action: ActionContextualReplacement, Pattern: `key=(\w+)`, Partial: true},
+	}})
+	vault := NewVault()
+	sanitizer.SetVault(vault)
+	sanitizedContent, _, _, err := sanitizer.Sanitize(content, "yaml", "a.yaml", "a_sanitized.yaml")
+	require.NoError(t, err)
+	assert.NotContains(t, sanitizedContent, "abc")
+	desanitizedContent, _, _, err := sanitizer.Desanitize(sanitizedContent, "yaml", "a_sanitized.yaml", "a_desanitized.yaml", vault)
+	require.NoError(t, err)
+	assert.Equal(t, content, desanitizedContent)
+}
+
+func TestSanitizeYamlErrors(t *testing.T) {
+	sanitizer := newTestRuleSetSanitizer(t, "yaml", RuleSet{Format: ContentFormatYaml, Rules: map[string]RuleInfo{`$`: {Action: ActionDelete}}})
+	_, _, _, err := sanitizer.Sanitize("a: [1\n", "yaml", "a.yaml", "a_sanitized.yaml")
+	var invalidInputErr *InvalidInputError
+	assert.ErrorAs(t, err, &invalidInputErr)
+
+	sanitizedContent, _, _, err := sanitizer.Sanitize("a: 1\n", "yaml", "a.yaml", "a_sanitized.yaml")
+	var partialSanitizationErr *PartialSanitizationError
+	require.ErrorAs(t, err, &partialSanitizationErr)
+	assert.Equal(t, "a: 1\n", sanitizedContent)
+
+	_, err = sanitizer.SanitizeStream(context.Background(), sanitizer.NewReplacementContext(), strings.NewReader("a: 1\n"), io.Discard, "yaml", "a.yaml", nil)
+	assert.ErrorContains(t, err, "streaming isn't supported for the yaml format")
+
+	_, err = CompileRuleSet(RuleSet{Format: "toml"}, sanitizer.config)
+	assert.ErrorContains(t, err, "unsupported format (toml)")
+}
@@ -0,0 +1,13 @@
+// Package script embeds the default config of the website, so the binaries built from the module can use it from any
+// directory.
+package script
+
+//goland:noinspection GoUnsortedImport
+import (
+	_ "embed"
+)
+
+// Config is the default config (config.json).
+//
+//go:embed config.json
+var Config []byte
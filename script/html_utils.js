@@ -29,6 +29,20 @@ function downloadContent(filename, text) {
     document.body.removeChild(element);
 }
 
+function downloadBlob(filename, blob) {
+    // Object URLs don't copy the blob, unlike data URLs, so large files can be downloaded.
+    const url = URL.createObjectURL(blob);
+    const element = document.createElement('a');
+    element.setAttribute('href', url);
+    element.setAttribute('download', filename);
+    element.style.display = 'none';
+    document.body.appendChild(element);
+    element.click();
+    document.body.removeChild(element);
+    // Revoking the URL right away can cancel the download in some browsers.
+    setTimeout(() => URL.revokeObjectURL(url), 1000);
+}
+
 function enableElement(id) {
     toggleElementEnableState(id, true);
 }
@@ -4,7 +4,7 @@ let diffDivElementsCount = 0;
 let ruleFiles = new Set()
 let sanitizedFileContents = {}
 
-function addOutput(unsanitized_file_name, unsanitized_content, sanitized_file_name, sanitized_content, diffPatchText, isDiffEmpty, ruleFilePath) {
+function addOutput(unsanitized_file_name, unsanitized_content, sanitized_file_name, sanitized_content, diffPatchText, isDiffEmpty, ruleFilePath, isDryRun=false, unmatchedRules=[]) {
     if(!isDiffEmpty) {
         // Only consider diff patches for files that have changed during sanitization.
         if (diff.length === 0) {
@@ -18,6 +18,27 @@ function addOutput(unsanitized_file_name, unsanitized_content, sanitized_file_na
         'content': sanitized_content,
         'isDiffEmpty': isDiffEmpty,
         'sanitizedFileName': sanitized_file_name,
+        'isDryRun': isDryRun,
+        'unmatchedRules': unmatchedRules,
+    };
+    const selectedFilesCount = document.getElementById("upload_button").files.length;
+    const sanitizedFilesCount = Object.keys(sanitizedFileContents).length;
+    if (sanitizedFilesCount === selectedFilesCount) {
+        displayOutput();
+    }
+}
+
+function addStreamedOutput(unsanitized_file_name, sanitized_file_name, sanitizedBlob, findingsCount, ruleFilePath) {
+    // Streamed files are too large to be previewed, so only the number of sanitized values is shown.
+    ruleFiles.add(ruleFilePath);
+    sanitizedFileContents[unsanitized_file_name] = {
+        'blob': sanitizedBlob,
+        'isDiffEmpty': findingsCount === 0,
+        'sanitizedFileName': sanitized_file_name,
+        'isDryRun': false,
+        'isStreamed': true,
+        'findingsCount': findingsCount,
+        'unmatchedRules': [],
     };
     const selectedFilesCount = document.getElementById("upload_button").files.length;
     const sanitizedFilesCount = Object.keys(sanitizedFileContents).length;
@@ -65,15 +86,39 @@ function displayOutput() {
             sanitizedFilesCount++;
         }
     }
-    if (sanitizedFilesCount === 0) {
+    // In a dry run, the files are not sanitized, only the proposed changes are shown.
+    const isDryRun = Object.values(sanitizedFileContents).some(fileContent => fileContent['isDryRun']);
+    if (sanitizedFilesCount === 0 || isDryRun) {
         disableElement("download_button");
     }
     setSanitizedFilesCount(sanitizedFilesCount)
 
-    if (sanitizedFilesCount > 0) {
+    if (isDryRun) {
+        createHTMLElement('br', null, null, 'output', null);
+        createHTMLElement('div', 'unmatched_rules_div', 'unmatched_rules_div', 'output', null);
+        createHTMLElement('h5', null, null, 'unmatched_rules_div', 'Rules that matched nothing');
+        for (const filePath in sanitizedFileContents) {
+            for (const unmatchedRule of sanitizedFileContents[filePath]['unmatchedRules']) {
+                createHTMLElement('p', null, 'unmatched_rule_p', 'unmatched_rules_div', filePath + ': ' + unmatchedRule);
+            }
+        }
+    }
+
+    const streamedFilePaths = Object.keys(sanitizedFileContents).filter(filePath =>
+        sanitizedFileContents[filePath]['isStreamed'] && !sanitizedFileContents[filePath]['isDiffEmpty']);
+    if (streamedFilePaths.length > 0) {
+        createHTMLElement('br', null, null, 'output', null);
+        createHTMLElement('div', 'streamed_files_div', 'streamed_files_div', 'output', null);
+        createHTMLElement('h5', null, null, 'streamed_files_div', 'Large files (sanitized without a preview)');
+        for (const filePath of streamedFilePaths) {
+            createHTMLElement('p', null, 'streamed_file_p', 'streamed_files_div', filePath + ': ' + sanitizedFileContents[filePath]['findingsCount'] + ' values sanitized');
+        }
+    }
+
+    if (diff.length > 0) {
         createHTMLElement('br', null, null, 'output', null);
         createHTMLElement('div', 'sanitized_files_div', 'sanitized_files_div', 'output', null);
-        createHTMLElement('h5', null, null, 'sanitized_files_div', 'Sanitized files');
+        createHTMLElement('h5', null, null, 'sanitized_files_div', isDryRun ? 'Proposed changes (dry run)' : 'Sanitized files');
         const sanitizedDiffDivElement = createHTMLElement('div', 'sanitized_diff_div', 'sanitized_diff_div', 'sanitized_files_div', null);
         console.log("diff: ", diff);
 
@@ -89,7 +134,12 @@ function displayOutput() {
 
 function downloadSanitizedContent() {
     for (const filePath in sanitizedFileContents) {
-        if (!sanitizedFileContents[filePath]['isDiffEmpty']) {
+        if (sanitizedFileContents[filePath]['isDiffEmpty']) {
+            continue;
+        }
+        if (sanitizedFileContents[filePath]['isStreamed']) {
+            downloadBlob(sanitizedFileContents[filePath]['sanitizedFileName'], sanitizedFileContents[filePath]['blob'])
+        } else {
             downloadContent(sanitizedFileContents[filePath]['sanitizedFileName'], sanitizedFileContents[filePath]['content'])
         }
     }
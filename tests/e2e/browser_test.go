@@ -88,6 +88,11 @@ func (suite *BrowserTestsSuite) TestValidFlow() {
 		suite.t.Fatalf("Error running test: %s", err)
 	}
 
+	err = SetSecretKey(suite.driver)
+	if err != nil {
+		suite.t.Fatalf("Error running test: %s", err)
+	}
+
 	t.Log("Uploading File")
 	filesToSanitizePath := append(fileNamesToSanitize, untouchedFileNames...)
 	err = UploadFiles(suite.driver, filesToSanitizePath)
@@ -222,6 +222,19 @@ func UploadFiles(webDriver selenium.WebDriver, filesToSanitize []string) error {
 	return nil
 }
 
+// SetSecretKey enters the test secret key (used to sanitize the expected sanitized files) in the secret key input.
+func SetSecretKey(webDriver selenium.WebDriver) error {
+	secretKey, err := os.ReadFile(filepath.Join(currentPath, "resources", "secret_key.txt"))
+	if err != nil {
+		return err
+	}
+	secretKeyElement, err := webDriver.FindElement(selenium.ByID, "secret_key_input")
+	if err != nil {
+		return err
+	}
+	return secretKeyElement.SendKeys(strings.TrimSpace(string(secretKey)))
+}
+
 /*
 Waiter methods
 */
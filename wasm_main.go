@@ -1,10 +1,14 @@
+//go:build js && wasm
+
 package main
 
 //goland:noinspection
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/padaiyal/sanitizer/sanitizer"
 	"gopkg.in/yaml.v3"
 	"io"
 	"net/http"
@@ -12,7 +16,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall/js"
 	"time"
 )
@@ -20,60 +23,9 @@ import (
 var jsGlobal = js.Global()
 var jsCall = jsGlobal.Call
 
-type RuleDetectionTaskInput struct {
-	Content      *string
-	RuleJsonPath string
-	RuleInfo     RuleInfo
-	Config       *Config
-}
-
-// Generic method to run tasks in parallel.
-func runTasks[I any, O any](task func(I, *chan O, *sync.WaitGroup), taskInputs *[]I) *[]O {
-	tasksCount := len(*taskInputs)
-	waitGroup := sync.WaitGroup{}
-	taskOutputs := make([]O, tasksCount)
-	channel := make(chan O, tasksCount)
-	for _, taskInput := range *taskInputs {
-		// We add 1 to the wait group. Each worker will decrease it by 1 once it's done.
-		waitGroup.Add(1)
-
-		// Spawn a goroutine
-		go task(taskInput, &channel, &waitGroup)
-	}
-	// Now we wait for all tasks to finish.
-	waitGroup.Wait()
-
-	// Close the channel or the following loop will get stuck.
-	close(channel)
-
-	for taskOutput := range channel {
-		taskOutputs = append(taskOutputs, taskOutput)
-	}
-	return &taskOutputs
-}
-
-type Config struct {
-	MaximumInputFileSizeThroughWebsiteInMB int      `json:"MaximumInputFileSizeThroughWebsiteInMB"`
-	MaximumInputFilesThroughWebsite        int      `json:"MaximumInputFilesThroughWebsite"`
-	RemovedSecretReplacement               string   `json:"RemovedSecretReplacement"`
-	SecretPrefix                           string   `json:"SecretPrefix"`
-	SupportedFileExtensions                []string `json:"SupportedFileExtensions"`
-	SupportedActions                       []string `json:"SupportedActions"`
-}
-
-type RuleInfo struct {
-	Description string `yaml:"description"`
-	Action      string `yaml:"action"`
-}
-
-type RuleSet struct {
-	Description string              `yaml:"description"`
-	Format      string              `yaml:"format"`
-	Rules       map[string]RuleInfo `yaml:"rules"`
-}
-
-var config = Config{}
-var ruleSets = map[string]RuleSet{}
+var config = sanitizer.Config{}
+var compiledRuleSets = map[string]*sanitizer.CompiledRuleSet{}
+var activeSanitizer *sanitizer.Sanitizer
 var document = jsGlobal.Get("document")
 
 func errorFollowUp(err error, exit bool) {
@@ -111,57 +63,158 @@ func getResponse[T interface{}](url string, responseStruct *T) ([]byte, error) {
 	return bodyBytes, err
 }
 
-func getRuleFilePath(fileExtension string) string {
-	return "rules/" + fileExtension + ".yaml"
+// handleSanitizeError alerts the error of sanitizing the file, and returns whether its output can still be shown.
+func handleSanitizeError(filePath string, err error) bool {
+	partialSanitizationErr := &sanitizer.PartialSanitizationError{}
+	invalidInputErr := &sanitizer.InvalidInputError{}
+	if errors.As(err, &invalidInputErr) {
+		jsCall("resetPageAfterAlert", "Error parsing '"+filePath+"' : "+invalidInputErr.Err.Error())
+		errorFollowUp(err, false)
+		return false
+	} else if errors.As(err, &partialSanitizationErr) {
+		// Show the partially sanitized content, along with the errors.
+		errorFollowUp(err, false)
+		jsCall("errorFollowUp", "'"+filePath+"' is partially sanitized, review it before sharing:\n"+err.Error())
+	} else if err != nil {
+		jsCall("resetPageAfterAlert", "Error sanitizing '"+filePath+"' : "+err.Error())
+		errorFollowUp(err, false)
+		return false
+	}
+	return true
 }
 
-func toPrettyJson(b []byte) ([]byte, error) {
-	var out bytes.Buffer
-	err := json.Indent(&out, b, "", "  ")
-	return out.Bytes(), err
+// sanitizeFileTask sanitizes a file in memory, and shows its output along with the diff.
+// It awaits the read of the file, so it must be run in its own goroutine rather than in a JS callback.
+func sanitizeFileTask(file js.Value, replacementContext *sanitizer.ReplacementContext, isDryRun bool) {
+	arrayBuffer, err := awaitPromise(file.Call("arrayBuffer"))
+	filePath := file.Get("name").String()
+	if err != nil {
+		jsCall("resetPageAfterAlert", "Error reading '"+filePath+"' : "+err.Error())
+		errorFollowUp(err, false)
+		return
+	}
+	data := jsGlobal.Get("Uint8Array").New(arrayBuffer)
+	dst := make([]byte, data.Get("length").Int())
+	js.CopyBytesToGo(dst, data)
+	fileExtension := filepath.Ext(filePath)[1:]
+	// The content is sanitized as is, so the diff is against the uploaded file. It's parsed once, while sanitizing.
+	unsanitizedContent := string(dst)
+	println("Rule sets available: ", len(compiledRuleSets))
+	sanitizedFileName := sanitizer.GenerateSanitizedFileName(filePath)
+	var result sanitizer.SanitizeResult
+	if isDryRun {
+		result, err = activeSanitizer.DryRun(replacementContext, unsanitizedContent, fileExtension, filePath, sanitizedFileName)
+	} else {
+		result, err = activeSanitizer.SanitizeWithReport(replacementContext, unsanitizedContent, fileExtension, filePath, sanitizedFileName)
+	}
+	if !handleSanitizeError(filePath, err) {
+		return
+	}
+	println("Showing output. filePath=", filePath, ", time=", time.Now().Unix())
+	unmatchedRules := make([]any, 0, len(result.Report.UnmatchedRules))
+	for _, unmatchedRule := range result.Report.UnmatchedRules {
+		unmatchedRules = append(unmatchedRules, unmatchedRule.RuleKey)
+	}
+	jsCall(
+		"addOutput",
+		filePath,
+		unsanitizedContent,
+		sanitizedFileName,
+		result.Content,
+		result.DiffPatchText,
+		result.IsDiffEmpty,
+		sanitizer.GetRuleFilePath(fileExtension),
+		result.IsDryRun,
+		unmatchedRules,
+	)
 }
 
-func generateSanitizedFileName(filePath string) string {
-	splitIndex := strings.LastIndex(filePath, ".")
-	return filePath[:splitIndex] + "_sanitized." + filePath[splitIndex+1:]
+// awaitPromise waits for the JS promise to settle, and returns its value or its error.
+// It must not be called from a JS callback, as the promise is settled by the event loop the callback blocks.
+func awaitPromise(promise js.Value) (js.Value, error) {
+	values := make(chan js.Value, 1)
+	errs := make(chan error, 1)
+	onFulfilled := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		values <- args[0]
+		return nil
+	})
+	defer onFulfilled.Release()
+	onRejected := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		errs <- errors.New(args[0].Call("toString").String())
+		return nil
+	})
+	defer onRejected.Release()
+	promise.Call("then", onFulfilled, onRejected)
+	select {
+	case value := <-values:
+		return value, nil
+	case err := <-errs:
+		return js.Undefined(), err
+	}
 }
 
-func sanitizeFileTask(file js.Value, errorsChannel *chan error, waitGroup *sync.WaitGroup) {
-	var err error = nil
-	file.Call("arrayBuffer").Call("then", js.FuncOf(func(v js.Value, x []js.Value) any {
-		data := jsGlobal.Get("Uint8Array").New(x[0])
-		dst := make([]byte, data.Get("length").Int())
-		js.CopyBytesToGo(dst, data)
-		filePath := file.Get("name").String()
-		fileExtension := filepath.Ext(filePath)[1:]
-		unsanitizedContentBytes, err := toPrettyJson(dst)
-		if err != nil {
-			jsCall("resetPageAfterAlert", "Error parsing '"+filePath+"' : "+err.Error())
-			errorFollowUp(err, false)
-			return nil
+// jsStreamReader reads the chunks of a JS ReadableStream's reader.
+type jsStreamReader struct {
+	reader js.Value
+	chunk  []byte
+	isDone bool
+}
+
+func (streamReader *jsStreamReader) Read(p []byte) (int, error) {
+	for len(streamReader.chunk) == 0 {
+		if streamReader.isDone {
+			return 0, io.EOF
 		}
-		unsanitizedContent := string(unsanitizedContentBytes)
-		println("Rule sets available: ", len(ruleSets))
-		sanitizedFileName := generateSanitizedFileName(filePath)
-		sanitizedContent, diffPatchText, isDiffEmpty, err := Sanitize(unsanitizedContent, fileExtension, filePath, sanitizedFileName, ruleSets, config)
+		result, err := awaitPromise(streamReader.reader.Call("read"))
 		if err != nil {
-			errorFollowUp(err, false)
+			return 0, err
 		}
-		println("Showing output. filePath=", filePath, ", time=", time.Now().Unix())
-		jsCall(
-			"addOutput",
-			filePath,
-			unsanitizedContent,
-			sanitizedFileName,
-			sanitizedContent,
-			diffPatchText,
-			isDiffEmpty,
-			getRuleFilePath(fileExtension),
-		)
-		return nil
-	}))
-	*errorsChannel <- err
-	waitGroup.Done()
+		if result.Get("done").Bool() {
+			streamReader.isDone = true
+			continue
+		}
+		value := result.Get("value")
+		streamReader.chunk = make([]byte, value.Get("length").Int())
+		js.CopyBytesToGo(streamReader.chunk, value)
+	}
+	n := copy(p, streamReader.chunk)
+	streamReader.chunk = streamReader.chunk[n:]
+	return n, nil
+}
+
+// jsBlobWriter collects the written bytes in JS, as the parts of a Blob.
+// The whole output is held in memory until it's downloaded, which is bounded by the
+// MaximumStreamedInputFileSizeThroughWebsiteInMB.
+type jsBlobWriter struct {
+	parts js.Value
+}
+
+func (blobWriter *jsBlobWriter) Write(p []byte) (int, error) {
+	part := jsGlobal.Get("Uint8Array").New(len(p))
+	js.CopyBytesToJS(part, p)
+	blobWriter.parts.Call("push", part)
+	return len(p), nil
+}
+
+// streamFileTask sanitizes a file too large to be sanitized in memory, streaming it into a Blob which is downloaded as
+// is. There's no diff, so only the number of sanitized values is shown.
+// It awaits the reads of the file, so it must be run in its own goroutine rather than in a JS callback.
+func streamFileTask(file js.Value, replacementContext *sanitizer.ReplacementContext) {
+	filePath := file.Get("name").String()
+	fileExtension := filepath.Ext(filePath)[1:]
+	sanitizedFileName := sanitizer.GenerateSanitizedFileName(filePath)
+	blobWriter := &jsBlobWriter{parts: jsGlobal.Get("Array").New()}
+	findingsCount := 0
+	println("Streaming file. filePath=", filePath, ", time=", time.Now().Unix())
+	_, err := activeSanitizer.SanitizeStream(context.Background(), replacementContext, &jsStreamReader{reader: file.Call("stream").Call("getReader")}, blobWriter, fileExtension, filePath, func(sanitizer.Finding) {
+		findingsCount++
+	})
+	if !handleSanitizeError(filePath, err) {
+		return
+	}
+	println("Showing streamed output. filePath=", filePath, ", time=", time.Now().Unix())
+	sanitizedBlob := jsGlobal.Get("Blob").New(blobWriter.parts, map[string]any{"type": file.Get("type").String()})
+	jsCall("addStreamedOutput", filePath, sanitizedFileName, sanitizedBlob, findingsCount, sanitizer.GetRuleFilePath(fileExtension))
 }
 
 func sanitizeCallbackFromJS(_ js.Value, _ []js.Value) any {
@@ -176,18 +229,41 @@ func sanitizeCallbackFromJS(_ js.Value, _ []js.Value) any {
 	} else if filesCount > config.MaximumInputFilesThroughWebsite {
 		jsCall("resetPageAfterAlert", "Cannot sanitize more than "+strconv.Itoa(config.MaximumInputFilesThroughWebsite)+" files at a time.\nSelect a lesser number of files.")
 	} else {
+		secretKey := document.Call("getElementById", "secret_key_input").Get("value").String()
+		if secretKey == "" && config.SecretKeyMode == sanitizer.SecretKeyModeUser {
+			jsCall("resetPageAfterAlert", "A secret key is required to sanitize files.")
+			return nil
+		}
+		// An empty key reverts to a random session key (if enabled).
+		activeSanitizer.SetSecretKey([]byte(secretKey))
+		// The selected files share a replacement context, so identical secrets across them get the same replacement.
+		replacementContext := activeSanitizer.NewReplacementContext()
+		isDryRun := document.Call("getElementById", "dry_run_checkbox").Get("checked").Bool()
 		jsCall("clearOutputs")
 		jsCall("showElement", "display_panel")
 		jsCall("showElement", "overlay-spinner", "flex")
 		files := make([]js.Value, filesCount)
 		filesIterated := map[string]int{}
+		// Files larger than the maximum file size are streamed, up to the maximum streamed file size.
+		streamedFilePaths := map[string]bool{}
 		for index := 0; index < filesCount; index++ {
 			file := filesElement.Call("item", index)
 			fileSizeInMB := file.Get("size").Int() / (1024 * 1024)
 			filePath := file.Get("name").String()
-			if fileSizeInMB > config.MaximumInputFileSizeThroughWebsiteInMB {
-				jsCall("resetPageAfterAlert", "Size of file "+filePath+" ("+strconv.Itoa(fileSizeInMB)+" MB) exceeds maximum supported file size of "+strconv.Itoa(config.MaximumInputFileSizeThroughWebsiteInMB)+" MB.")
+			// Only the files of the formats that can be streamed (i.e. JSON) can exceed the maximum file size.
+			maximumFileSizeInMB := config.MaximumInputFileSizeThroughWebsiteInMB
+			if activeSanitizer.CanStream(strings.TrimPrefix(filepath.Ext(filePath), ".")) {
+				maximumFileSizeInMB = max(maximumFileSizeInMB, config.MaximumStreamedInputFileSizeThroughWebsiteInMB)
+			}
+			if fileSizeInMB > maximumFileSizeInMB {
+				jsCall("resetPageAfterAlert", "Size of file "+filePath+" ("+strconv.Itoa(fileSizeInMB)+" MB) exceeds maximum supported file size of "+strconv.Itoa(maximumFileSizeInMB)+" MB for its type.")
 				return nil
+			} else if fileSizeInMB > config.MaximumInputFileSizeThroughWebsiteInMB {
+				if isDryRun {
+					jsCall("resetPageAfterAlert", "Size of file "+filePath+" ("+strconv.Itoa(fileSizeInMB)+" MB) exceeds maximum file size of "+strconv.Itoa(config.MaximumInputFileSizeThroughWebsiteInMB)+" MB supported by dry runs.")
+					return nil
+				}
+				streamedFilePaths[filePath] = true
 			}
 			if _, isPresent := filesIterated[filePath]; isPresent {
 				jsCall("resetPageAfterAlert", "Multiple files with the same name ("+filePath+") isn't supported. Please choose files with different names.")
@@ -196,7 +272,18 @@ func sanitizeCallbackFromJS(_ js.Value, _ []js.Value) any {
 			filesIterated[filePath] = 1
 			files[index] = file
 		}
-		_ = runTasks(sanitizeFileTask, &files)
+		// The tasks await the reads of the files, so they're run outside of this callback. Each task lasts until its file
+		// is sanitized, so no more than MaxConcurrency files are held in memory at once.
+		go func() {
+			_ = sanitizer.RunTasks(context.Background(), func(_ context.Context, file js.Value) (any, error) {
+				if streamedFilePaths[file.Get("name").String()] {
+					streamFileTask(file, replacementContext)
+				} else {
+					sanitizeFileTask(file, replacementContext, isDryRun)
+				}
+				return nil, nil
+			}, files, sanitizer.TaskOptions{Concurrency: config.MaxConcurrency})
+		}()
 	}
 	return nil
 }
@@ -215,14 +302,21 @@ func main() {
 	allowedFileFormats := ""
 	for _, supportedFileExtension := range config.SupportedFileExtensions {
 		println("Loading rule set for " + supportedFileExtension + " files.")
-		ruleSetStruct := RuleSet{}
-		_, err := getResponse(getRuleFilePath(supportedFileExtension), &ruleSetStruct)
+		ruleSetStruct := sanitizer.RuleSet{}
+		_, err := getResponse(sanitizer.GetRuleFilePath(supportedFileExtension), &ruleSetStruct)
 
 		if err != nil {
 			println("Error loading rule set for " + supportedFileExtension + " files.")
 			errorFollowUp(err, false)
 		} else {
-			ruleSets[supportedFileExtension] = ruleSetStruct
+			// The rule set is compiled once for all the files. The valid rules are still applied if some rules are
+			// invalid, and the files sanitized with it are reported as partially sanitized.
+			compiledRuleSet, err := sanitizer.CompileRuleSet(ruleSetStruct, config)
+			if err != nil {
+				println("Error compiling rule set for " + supportedFileExtension + " files.")
+				errorFollowUp(err, false)
+			}
+			compiledRuleSets[supportedFileExtension] = compiledRuleSet
 			if allowedFileFormats != "" {
 				allowedFileFormats += ","
 			}
@@ -230,6 +324,7 @@ func main() {
 		}
 	}
 	println("Allowed file formats: ", allowedFileFormats)
+	activeSanitizer = sanitizer.NewWithCompiledRuleSets(config, compiledRuleSets)
 	uploadButton := document.Call("getElementById", "upload_button")
 	// Set the callback to invoke when a file is selected.
 	uploadButton.Set("oninput", js.FuncOf(sanitizeCallbackFromJS))